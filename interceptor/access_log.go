@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/kedacore/http-add-on/interceptor/config"
+)
+
+// combinedTimeFormat is the time format of the Combined Log Format
+const combinedTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogEntry is the access log entry of a request
+type accessLogEntry struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remoteAddr"`
+	Method     string    `json:"method"`
+	Host       string    `json:"host"`
+	// Namespace is the namespace of the Target
+	// that the request was routed to, if any
+	Namespace string `json:"namespace,omitempty"`
+	Path      string `json:"path"`
+	Protocol  string `json:"protocol"`
+	Status    int    `json:"status"`
+	Bytes     int64  `json:"bytes"`
+	// DurationSeconds is the time from the arrival of
+	// the request until its response was written
+	DurationSeconds float64 `json:"durationSeconds"`
+	// UpstreamLatencySeconds is how long forwarding the request
+	// to its backend took. It is 0 if it wasn't forwarded
+	UpstreamLatencySeconds float64 `json:"upstreamLatencySeconds"`
+	ColdStart              bool    `json:"coldStart"`
+	Retries                int     `json:"retries"`
+	Referer                string  `json:"referer,omitempty"`
+	UserAgent              string  `json:"userAgent,omitempty"`
+
+	// requestURI is the request target of the request
+	// line of the Combined Log Format
+	requestURI string
+}
+
+// accessLogger writes the access log of the proxy server. Unlike
+// the logr.Logger of the interceptor, it writes an entry for every
+// request, unless it is configured to sample them
+type accessLogger struct {
+	mu              sync.Mutex
+	w               io.Writer
+	format          string
+	sampleRate      float64
+	hostSampleRates map[string]float64
+}
+
+// newAccessLoggerFromServing returns an accessLogger that writes
+// to w as configured by cfg, or nil if the access log is disabled
+func newAccessLoggerFromServing(w io.Writer, cfg *config.Serving) *accessLogger {
+	if cfg.AccessLogFormat == "" {
+		return nil
+	}
+	return &accessLogger{
+		w:               w,
+		format:          cfg.AccessLogFormat,
+		sampleRate:      cfg.AccessLogSampleRate,
+		hostSampleRates: cfg.AccessLogHostSampleRates,
+	}
+}
+
+// sampled returns whether a request is logged. The sample rate is
+// that of the first of keys, e.g. the routing table key of the request
+// and then its bare host, that has one, or the default sample rate
+func (a *accessLogger) sampled(keys ...string) bool {
+	rate := a.sampleRate
+	for _, key := range keys {
+		if r, ok := a.hostSampleRates[key]; ok {
+			rate = r
+			break
+		}
+	}
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	default:
+		return rand.Float64() < rate
+	}
+}
+
+// log writes entry to the access log. Entries are written whole,
+// so that those of concurrent requests don't interleave
+func (a *accessLogger) log(entry *accessLogEntry) error {
+	var buf bytes.Buffer
+	switch a.format {
+	case config.AccessLogFormatCombined:
+		writeCombined(&buf, entry)
+	default:
+		if err := json.NewEncoder(&buf).Encode(entry); err != nil {
+			return err
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err := a.w.Write(buf.Bytes())
+	return err
+}
+
+// writeCombined writes entry to buf in the Combined Log Format,
+// followed by the fields of entry that the format doesn't have
+func writeCombined(buf *bytes.Buffer, entry *accessLogEntry) {
+	remoteHost := entry.RemoteAddr
+	if h, _, err := net.SplitHostPort(remoteHost); err == nil {
+		remoteHost = h
+	}
+	size := "-"
+	if entry.Bytes > 0 {
+		size = strconv.FormatInt(entry.Bytes, 10)
+	}
+	fmt.Fprintf(
+		buf,
+		"%s - - [%s] %q %d %s %q %q host=%q namespace=%q upstream_latency=%.6f cold_start=%t retries=%d\n",
+		remoteHost,
+		entry.Time.Format(combinedTimeFormat),
+		fmt.Sprintf("%s %s %s", entry.Method, entry.requestURI, entry.Protocol),
+		entry.Status,
+		size,
+		entry.Referer,
+		entry.UserAgent,
+		entry.Host,
+		entry.Namespace,
+		entry.UpstreamLatencySeconds,
+		entry.ColdStart,
+		entry.Retries,
+	)
+}
+
+// accessLogMiddleware writes an entry to accessLog for each
+// request, once next has served it. It creates the requestStats
+// of the request, which the handlers in next fill in
+func accessLogMiddleware(
+	lggr logr.Logger,
+	accessLog *accessLogger,
+	next http.Handler,
+) http.Handler {
+	lggr = lggr.WithName("accessLogMiddleware")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		// the handlers in next may rewrite the URL of r,
+		// so its fields are read before they serve it
+		entry := &accessLogEntry{
+			Time:       time.Now(),
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			Host:       host,
+			Path:       r.URL.Path,
+			Protocol:   r.Proto,
+			Referer:    r.Referer(),
+			UserAgent:  r.UserAgent(),
+			requestURI: r.URL.RequestURI(),
+		}
+		ctx, stats := withRequestStats(r.Context())
+		stats.accessLogged = true
+		rec := newStatusRecorder(w)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		// the routing table key, e.g. host#rule, has
+		// precedence over the bare host of the request
+		if !accessLog.sampled(stats.key, host) {
+			return
+		}
+		entry.Status = rec.status
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+		entry.Bytes = rec.bytes
+		entry.DurationSeconds = time.Since(entry.Time).Seconds()
+		entry.Namespace = stats.namespace
+		entry.UpstreamLatencySeconds = stats.upstream.Seconds()
+		entry.ColdStart = stats.waited && stats.coldStart
+		entry.Retries = stats.retries
+		if err := accessLog.log(entry); err != nil {
+			lggr.Error(err, "writing access log entry")
+		}
+	})
+}
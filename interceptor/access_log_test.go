@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kedacore/http-add-on/interceptor/config"
+)
+
+// servedHandler is a handler that fills in the requestStats
+// of a request like the proxy handlers do for a request to
+// key that was forwarded after a cold start and a retry
+func servedHandler(key string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := requestStatsFrom(r.Context())
+		stats.routed(key, "testns")
+		stats.waitedFor(time.Second, true)
+		stats.retried()
+		stats.forwarded(250 * time.Millisecond)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("created"))
+	})
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	r := require.New(t)
+	var buf bytes.Buffer
+	accessLog := newAccessLoggerFromServing(&buf, &config.Serving{
+		AccessLogFormat:     config.AccessLogFormatJSON,
+		AccessLogSampleRate: 1,
+	})
+	r.NotNil(accessLog)
+	hdl := accessLogMiddleware(logr.Discard(), accessLog, servedHandler("testingkeda.com"))
+
+	req := httptest.NewRequest("POST", "/some/path?a=b", nil)
+	req.Host = "testingkeda.com:8080"
+	req.Header.Set("User-Agent", "test-agent")
+	hdl.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry := map[string]interface{}{}
+	r.NoError(json.Unmarshal(buf.Bytes(), &entry))
+	r.Equal("POST", entry["method"])
+	r.Equal("testingkeda.com", entry["host"])
+	r.Equal("testns", entry["namespace"])
+	r.Equal("/some/path", entry["path"])
+	r.Equal(201.0, entry["status"])
+	r.Equal(7.0, entry["bytes"])
+	r.Equal(0.25, entry["upstreamLatencySeconds"])
+	r.Equal(true, entry["coldStart"])
+	r.Equal(1.0, entry["retries"])
+	r.Equal("test-agent", entry["userAgent"])
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	r := require.New(t)
+	var buf bytes.Buffer
+	accessLog := newAccessLoggerFromServing(&buf, &config.Serving{
+		AccessLogFormat:     config.AccessLogFormatCombined,
+		AccessLogSampleRate: 1,
+	})
+	hdl := accessLogMiddleware(logr.Discard(), accessLog, servedHandler("testingkeda.com"))
+
+	req := httptest.NewRequest("GET", "/some/path?a=b", nil)
+	req.Host = "testingkeda.com"
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("Referer", "http://example.com")
+	req.Header.Set("User-Agent", "test-agent")
+	hdl.ServeHTTP(httptest.NewRecorder(), req)
+
+	r.Regexp(
+		regexp.MustCompile(
+			`^10\.0\.0\.1 - - \[[^\]]+\] "GET /some/path\?a=b HTTP/1\.1" 201 7 "http://example.com" "test-agent" `+
+				`host="testingkeda\.com" namespace="testns" upstream_latency=0\.250000 cold_start=true retries=1\n$`,
+		),
+		buf.String(),
+	)
+}
+
+// requests should be sampled by the sample rate of their
+// host if it has one, and the default sample rate otherwise
+func TestAccessLogSampling(t *testing.T) {
+	r := require.New(t)
+	var buf bytes.Buffer
+	accessLog := newAccessLoggerFromServing(&buf, &config.Serving{
+		AccessLogFormat:     config.AccessLogFormatJSON,
+		AccessLogSampleRate: 0,
+		AccessLogHostSampleRates: map[string]float64{
+			"loud.com": 1,
+		},
+	})
+	for _, host := range []string{"loud.com", "quiet.com", "loud.com"} {
+		hdl := accessLogMiddleware(logr.Discard(), accessLog, servedHandler(host))
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = host
+		hdl.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	r.Len(lines, 2)
+	for _, line := range lines {
+		r.Contains(line, `"host":"loud.com"`)
+	}
+}
+
+// requests routed by a routing table key that has no sample rate
+// of its own should be sampled by the sample rate of their host
+func TestAccessLogSamplingHostFallback(t *testing.T) {
+	r := require.New(t)
+	var buf bytes.Buffer
+	accessLog := newAccessLoggerFromServing(&buf, &config.Serving{
+		AccessLogFormat:     config.AccessLogFormatJSON,
+		AccessLogSampleRate: 1,
+		AccessLogHostSampleRates: map[string]float64{
+			"quiet.com":      0,
+			"quiet.com#loud": 1,
+		},
+	})
+	for _, key := range []string{"quiet.com@canary", "quiet.com#loud"} {
+		hdl := accessLogMiddleware(logr.Discard(), accessLog, servedHandler(key))
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = "quiet.com"
+		hdl.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	r.Len(lines, 1)
+}
+
+// the per-request lines of the proxy handlers should
+// only be demoted for requests that are access logged
+func TestRequestLogger(t *testing.T) {
+	r := require.New(t)
+	lggr := funcr.New(func(prefix, args string) {}, funcr.Options{})
+	r.True(requestLogger(context.Background(), lggr).Enabled())
+
+	var logged bool
+	hdl := accessLogMiddleware(
+		logr.Discard(),
+		newAccessLoggerFromServing(io.Discard, &config.Serving{
+			AccessLogFormat:     config.AccessLogFormatJSON,
+			AccessLogSampleRate: 1,
+		}),
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			logged = requestLogger(req.Context(), lggr).Enabled()
+		}),
+	)
+	hdl.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	r.False(logged)
+}
+
+// the access log should be disabled if it has no format
+func TestAccessLogDisabled(t *testing.T) {
+	r := require.New(t)
+	r.Nil(newAccessLoggerFromServing(&bytes.Buffer{}, &config.Serving{}))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+
+	"github.com/kedacore/http-add-on/pkg/policy"
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+// accessMiddleware rejects requests whose caller isn't allowed to reach
+// the service that routingTable routes them to by policies, with a 403.
+// Other requests are passed on to next, along with their resolved host
+// and routing table match in the request context. The match is recorded
+// in the requestStats of every request that has one.
+//
+// It must run before countMiddleware, so that rejected requests are
+// never counted and never scale their target up
+func accessMiddleware(
+	lggr logr.Logger,
+	routingTable *routing.Table,
+	hostResolver HostResolver,
+	policies *policy.Table,
+	next http.Handler,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, callerNs, err := resolveHost(hostResolver, r)
+		if err != nil {
+			lggr.Error(err, "not forwarding request")
+			w.WriteHeader(400)
+			if _, err := w.Write([]byte("Host not found, not forwarding request")); err != nil {
+				lggr.Error(err, "could not write error message to client")
+			}
+			return
+		}
+		r = r.WithContext(withHost(r.Context(), host, callerNs))
+		key, target, err := routeRequest(routingTable, host, r)
+		if err != nil {
+			// unknown hosts are rejected by the forwarding handler
+			next.ServeHTTP(w, r)
+			return
+		}
+		r = r.WithContext(withRoute(r.Context(), key, target))
+		// denied requests are reported under their route too
+		requestStatsFrom(r.Context()).routed(key, target.Namespace)
+
+		if err := policies.Check(callerNs, target.Namespace, target.Service); err != nil {
+			lggr.Info(
+				"denying request",
+				"host", host,
+				"callerNamespace", callerNs,
+				"reason", err.Error(),
+			)
+			w.WriteHeader(403)
+			if _, err := w.Write([]byte(fmt.Sprintf("Forbidden: %s", err))); err != nil {
+				lggr.Error(err, "could not write error message to client")
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kedacore/http-add-on/pkg/policy"
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+// callerResolver resolves every request to its Host header,
+// called from namespace callerNs
+type callerResolver struct {
+	callerNs string
+}
+
+func (c callerResolver) Resolve(r *http.Request) (string, string, error) {
+	return r.Host, c.callerNs, nil
+}
+
+func TestAccessMiddleware(t *testing.T) {
+	const host = "ledger.payments"
+	r := require.New(t)
+	routingTable := routing.NewTable()
+	target := routing.NewTarget("payments", "ledger", 8080, "ledger", 100)
+	r.NoError(routingTable.AddTarget(host, target))
+	policies := policy.NewTable()
+	policies.Set(policy.Key("payments", "ledger"), policy.Policy{
+		Namespace:         "payments",
+		AllowedNamespaces: []string{"billing"},
+	})
+
+	testCases := []struct {
+		callerNs string
+		host     string
+		code     int
+	}{
+		{"billing", host, 200},
+		{"shop", host, 403},
+		// requests without a caller namespace can't be allowed
+		{"", host, 403},
+		// requests to unknown hosts are left to the next handler
+		{"shop", "unknown.host", 200},
+	}
+	for _, tc := range testCases {
+		var (
+			nextCalled bool
+			nextTarget *routing.Target
+		)
+		hdl := accessMiddleware(
+			logr.Discard(),
+			routingTable,
+			callerResolver{callerNs: tc.callerNs},
+			policies,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nextCalled = true
+				_, nextTarget, _ = routeRequest(routingTable, tc.host, r)
+				w.WriteHeader(200)
+			}),
+		)
+		ctx, stats := withRequestStats(context.Background())
+		req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+		req.Host = tc.host
+		rec := httptest.NewRecorder()
+		hdl.ServeHTTP(rec, req)
+
+		r.Equal(tc.code, rec.Code, "%+v", tc)
+		r.Equal(tc.code == 200, nextCalled, "%+v", tc)
+		if tc.code == 200 && tc.host == host {
+			r.Equal(target, *nextTarget, "%+v", tc)
+		}
+		// denied requests are reported under their route
+		if tc.host == host {
+			r.Equal(host, stats.key, "%+v", tc)
+			r.Equal("payments", stats.namespace, "%+v", tc)
+		}
+	}
+}
+
+// the header host resolver doesn't know the namespace of the caller,
+// so the services that policies apply to deny every request with it,
+// even from their own namespace
+func TestAccessMiddlewareHeaderResolver(t *testing.T) {
+	r := require.New(t)
+	routingTable := routing.NewTable()
+	r.NoError(routingTable.AddTarget("ledger.payments", routing.NewTarget("payments", "ledger", 8080, "ledger", 100)))
+	r.NoError(routingTable.AddTarget("site.public", routing.NewTarget("public", "site", 8080, "site", 100)))
+	policies := policy.NewTable()
+	policies.Set(policy.Key("payments", "ledger"), policy.Policy{
+		Namespace:         "payments",
+		AllowedNamespaces: []string{"payments", "*"},
+	})
+	hdl := accessMiddleware(
+		logr.Discard(),
+		routingTable,
+		hostHeaderResolver{},
+		policies,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+		}),
+	)
+
+	for host, code := range map[string]int{
+		"ledger.payments": 403,
+		"site.public":     200,
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		hdl.ServeHTTP(rec, req)
+		r.Equal(code, rec.Code, host)
+	}
+}
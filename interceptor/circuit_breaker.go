@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kedacore/http-add-on/interceptor/config"
+)
+
+// breakerState is the state of a circuitBreaker
+type breakerState string
+
+const (
+	// breakerClosed lets all requests through
+	breakerClosed breakerState = "closed"
+	// breakerOpen rejects all requests until its cooldown is over
+	breakerOpen breakerState = "open"
+	// breakerHalfOpen lets a single probe request through. The
+	// breaker closes if it succeeds and opens again if it fails
+	breakerHalfOpen breakerState = "half-open"
+)
+
+// circuitBreakerConfig is when circuit breakers open and for how long
+type circuitBreakerConfig struct {
+	// consecutiveFailures opens a breaker after that many failed
+	// requests in a row. Zero disables this trigger
+	consecutiveFailures int
+	// errorRate opens a breaker once that ratio of the requests within
+	// window have failed, provided there were at least minRequests.
+	// Zero disables this trigger
+	errorRate   float64
+	minRequests int
+	window      time.Duration
+	// cooldown is how long a breaker stays open before
+	// it lets a probe request through
+	cooldown time.Duration
+}
+
+func newCircuitBreakerConfigFromServing(s *config.Serving) circuitBreakerConfig {
+	return circuitBreakerConfig{
+		consecutiveFailures: s.CircuitBreakerConsecutiveFailures,
+		errorRate:           s.CircuitBreakerErrorRate,
+		minRequests:         s.CircuitBreakerMinRequests,
+		window:              s.CircuitBreakerWindow,
+		cooldown:            s.CircuitBreakerCooldown,
+	}
+}
+
+// enabled returns true if c has a trigger that opens breakers
+func (c circuitBreakerConfig) enabled() bool {
+	return c.consecutiveFailures > 0 || c.errorRate > 0
+}
+
+// circuitBreaker stops forwarding requests to a target
+// whose requests keep failing
+type circuitBreaker struct {
+	cfg circuitBreakerConfig
+	now func() time.Time
+
+	l                   sync.Mutex
+	state               breakerState
+	consecutiveFailures int
+	windowStart         time.Time
+	requests            int
+	failures            int
+	openedAt            time.Time
+	probing             bool
+}
+
+// allow returns true if a request may be forwarded. Every allowed
+// request must be followed by a call to record, observe or release
+func (b *circuitBreaker) allow() bool {
+	b.l.Lock()
+	defer b.l.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cfg.cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// retryAfter returns how long it is until an open breaker
+// lets a probe request through
+func (b *circuitBreaker) retryAfter() time.Duration {
+	b.l.Lock()
+	defer b.l.Unlock()
+	if wait := b.cfg.cooldown - b.now().Sub(b.openedAt); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// record records the outcome of an allowed request
+func (b *circuitBreaker) record(success bool) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	now := b.now()
+	switch b.state {
+	case breakerHalfOpen:
+		b.probing = false
+		if success {
+			b.close(now)
+		} else {
+			b.open(now)
+		}
+	case breakerClosed:
+		if now.Sub(b.windowStart) >= b.cfg.window {
+			b.windowStart = now
+			b.requests, b.failures = 0, 0
+		}
+		b.requests++
+		if success {
+			b.consecutiveFailures = 0
+			return
+		}
+		b.failures++
+		b.consecutiveFailures++
+		if b.cfg.consecutiveFailures > 0 && b.consecutiveFailures >= b.cfg.consecutiveFailures {
+			b.open(now)
+			return
+		}
+		if b.cfg.errorRate > 0 && b.requests >= b.cfg.minRequests &&
+			float64(b.failures)/float64(b.requests) >= b.cfg.errorRate {
+			b.open(now)
+		}
+	}
+}
+
+// observe records the outcome of an allowed request that was answered
+// with status. Requests whose context is done are released instead
+func (b *circuitBreaker) observe(ctx context.Context, status int) {
+	if ctx.Err() != nil {
+		b.release()
+		return
+	}
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		b.record(false)
+	default:
+		b.record(true)
+	}
+}
+
+// release ends an allowed request whose outcome says nothing
+// about the target, e.g. because the client went away
+func (b *circuitBreaker) release() {
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.state == breakerHalfOpen {
+		b.probing = false
+	}
+}
+
+// open opens b. The caller must hold b.l
+func (b *circuitBreaker) open(now time.Time) {
+	b.state = breakerOpen
+	b.openedAt = now
+}
+
+// close closes b and resets its counts. The caller must hold b.l
+func (b *circuitBreaker) close(now time.Time) {
+	b.state = breakerClosed
+	b.consecutiveFailures = 0
+	b.windowStart = now
+	b.requests, b.failures = 0, 0
+}
+
+// breakerStatus is the JSON form of a circuitBreaker
+type breakerStatus struct {
+	State               breakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutiveFailures"`
+	Requests            int          `json:"requests"`
+	Failures            int          `json:"failures"`
+	OpenedAt            *time.Time   `json:"openedAt,omitempty"`
+}
+
+func (b *circuitBreaker) status() breakerStatus {
+	b.l.Lock()
+	defer b.l.Unlock()
+	ret := breakerStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.consecutiveFailures,
+		Requests:            b.requests,
+		Failures:            b.failures,
+	}
+	if b.state != breakerClosed {
+		openedAt := b.openedAt
+		ret.OpenedAt = &openedAt
+	}
+	return ret
+}
+
+// circuitBreakers holds a circuitBreaker for each routing table key.
+// A nil *circuitBreakers, or one whose config has no trigger, has
+// no breakers
+type circuitBreakers struct {
+	cfg circuitBreakerConfig
+	now func() time.Time
+
+	l sync.Mutex
+	m map[string]*circuitBreaker
+}
+
+func newCircuitBreakers(cfg circuitBreakerConfig) *circuitBreakers {
+	return &circuitBreakers{
+		cfg: cfg,
+		now: time.Now,
+		m:   map[string]*circuitBreaker{},
+	}
+}
+
+// get returns the circuitBreaker of the target stored under key,
+// or nil if circuit breakers are disabled
+func (c *circuitBreakers) get(key string) *circuitBreaker {
+	if c == nil || !c.cfg.enabled() {
+		return nil
+	}
+	c.l.Lock()
+	defer c.l.Unlock()
+	b, ok := c.m[key]
+	if !ok {
+		b = &circuitBreaker{
+			cfg:         c.cfg,
+			now:         c.now,
+			state:       breakerClosed,
+			windowStart: c.now(),
+		}
+		c.m[key] = b
+	}
+	return b
+}
+
+// prune forgets the breakers of the routing table keys
+// that keep returns false for, e.g. once they are removed
+func (c *circuitBreakers) prune(keep func(key string) bool) {
+	if c == nil {
+		return
+	}
+	c.l.Lock()
+	defer c.l.Unlock()
+	for key := range c.m {
+		if !keep(key) {
+			delete(c.m, key)
+		}
+	}
+}
+
+// MarshalJSON implements json.Marshaler. It returns the
+// status of every breaker by routing table key
+func (c *circuitBreakers) MarshalJSON() ([]byte, error) {
+	c.l.Lock()
+	breakers := make(map[string]*circuitBreaker, len(c.m))
+	for key, b := range c.m {
+		breakers[key] = b
+	}
+	c.l.Unlock()
+
+	ret := make(map[string]breakerStatus, len(breakers))
+	for key, b := range breakers {
+		ret[key] = b.status()
+	}
+	return json.Marshal(ret)
+}
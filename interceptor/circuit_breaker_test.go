@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+
+	kedanet "github.com/kedacore/http-add-on/pkg/net"
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+// fakeClock is a clock for circuit breakers that
+// only moves when the test moves it
+type fakeClock struct {
+	t time.Time
+}
+
+func (f *fakeClock) now() time.Time {
+	return f.t
+}
+
+func newTestCircuitBreakers(cfg circuitBreakerConfig) (*circuitBreakers, *fakeClock) {
+	clock := &fakeClock{t: time.Now()}
+	breakers := newCircuitBreakers(cfg)
+	breakers.now = clock.now
+	return breakers, clock
+}
+
+func TestCircuitBreakerConsecutiveFailures(t *testing.T) {
+	r := require.New(t)
+	breakers, clock := newTestCircuitBreakers(circuitBreakerConfig{
+		consecutiveFailures: 3,
+		window:              time.Minute,
+		cooldown:            10 * time.Second,
+	})
+	b := breakers.get("a.com")
+
+	// a success resets the consecutive failures
+	for _, success := range []bool{false, false, true, false, false} {
+		r.True(b.allow())
+		b.record(success)
+	}
+	r.Equal(breakerClosed, b.status().State)
+
+	r.True(b.allow())
+	b.record(false)
+	r.Equal(breakerOpen, b.status().State)
+	r.False(b.allow())
+	r.Equal(10*time.Second, b.retryAfter())
+
+	// after the cooldown, a single probe goes through
+	clock.t = clock.t.Add(10 * time.Second)
+	r.True(b.allow())
+	r.Equal(breakerHalfOpen, b.status().State)
+	r.False(b.allow(), "second request while probing")
+
+	// a failed probe opens the breaker again
+	b.record(false)
+	r.Equal(breakerOpen, b.status().State)
+	r.False(b.allow())
+
+	// a released probe lets the next request probe
+	clock.t = clock.t.Add(10 * time.Second)
+	r.True(b.allow())
+	b.release()
+	r.True(b.allow())
+
+	// a successful probe closes the breaker
+	b.record(true)
+	r.Equal(breakerClosed, b.status().State)
+	r.True(b.allow())
+
+	// other keys have their own breaker
+	r.Equal(breakerClosed, breakers.get("b.com").status().State)
+}
+
+func TestCircuitBreakerErrorRate(t *testing.T) {
+	r := require.New(t)
+	breakers, clock := newTestCircuitBreakers(circuitBreakerConfig{
+		errorRate:   0.5,
+		minRequests: 4,
+		window:      time.Minute,
+		cooldown:    10 * time.Second,
+	})
+	b := breakers.get("a.com")
+
+	// too few requests to check the error rate
+	for _, success := range []bool{false, true, false} {
+		b.record(success)
+	}
+	r.Equal(breakerClosed, b.status().State)
+
+	// the window restarts, so the old failures don't count
+	clock.t = clock.t.Add(time.Minute)
+	for _, success := range []bool{true, true, false} {
+		b.record(success)
+	}
+	r.Equal(breakerClosed, b.status().State)
+
+	b.record(false)
+	r.Equal(breakerOpen, b.status().State)
+}
+
+func TestCircuitBreakersDisabled(t *testing.T) {
+	r := require.New(t)
+	var nilBreakers *circuitBreakers
+	r.Nil(nilBreakers.get("a.com"))
+	r.Nil(newCircuitBreakers(circuitBreakerConfig{cooldown: time.Second}).get("a.com"))
+}
+
+func TestCircuitBreakersJSON(t *testing.T) {
+	r := require.New(t)
+	breakers, _ := newTestCircuitBreakers(circuitBreakerConfig{
+		consecutiveFailures: 1,
+		cooldown:            time.Second,
+	})
+	breakers.get("a.com").record(false)
+	breakers.get("b.com").record(true)
+
+	b, err := json.Marshal(breakers)
+	r.NoError(err)
+	statuses := map[string]breakerStatus{}
+	r.NoError(json.Unmarshal(b, &statuses))
+	r.Len(statuses, 2)
+	r.Equal(breakerOpen, statuses["a.com"].State)
+	r.NotNil(statuses["a.com"].OpenedAt)
+	r.Equal(breakerClosed, statuses["b.com"].State)
+	r.Equal(1, statuses["b.com"].Requests)
+}
+
+// the forwarding handler should fail fast once the
+// circuit breaker of a failing target is open
+func TestForwardingHandlerCircuitBreaker(t *testing.T) {
+	const host = "TestForwardingHandlerCircuitBreaker.testing"
+	r := require.New(t)
+
+	originHdl := kedanet.NewTestHTTPHandlerWrapper(
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}),
+	)
+	srv, originURL, err := kedanet.StartTestServer(originHdl)
+	r.NoError(err)
+	defer srv.Close()
+	originPort, err := strconv.Atoi(originURL.Port())
+	r.NoError(err)
+	routingTable := routing.NewTable()
+	r.NoError(routingTable.AddTarget(host, targetFromURL(originURL, originPort, "testdepl")))
+
+	breakers, _ := newTestCircuitBreakers(circuitBreakerConfig{
+		consecutiveFailures: 2,
+		cooldown:            time.Minute,
+	})
+	timeouts := defaultTimeouts()
+	hdl := newForwardingHandler(
+		logr.Discard(),
+		routingTable,
+		hostHeaderResolver{},
+		breakers,
+		nil,
+		retryDialContextFunc(timeouts, timeouts.DefaultBackoff()),
+		func(context.Context, routing.Target) (int, error) {
+			return 1, nil
+		},
+		func(routing.Target) (*url.URL, error) {
+			return originURL, nil
+		},
+		forwardingConfig{
+			waitTimeout:       timeouts.DeploymentReplicas,
+			respHeaderTimeout: timeouts.ResponseHeader,
+		},
+	)
+
+	for _, code := range []int{502, 502, 503, 503} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = host
+		res := httptest.NewRecorder()
+		hdl.ServeHTTP(res, req)
+		r.Equal(code, res.Code)
+	}
+	r.Len(originHdl.IncomingRequests(), 2)
+	r.Equal(breakerOpen, breakers.get(host).status().State)
+}
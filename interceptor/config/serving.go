@@ -6,6 +6,41 @@ import (
 	"github.com/kelseyhightower/envconfig"
 )
 
+const (
+	// HostResolverHeader routes requests by their Host header. It
+	// doesn't know the namespace of the caller, so the services
+	// that access policies apply to deny every request with it
+	HostResolverHeader = "header"
+	// HostResolverReverseDNS routes requests by the service in their
+	// Host header, in the namespace of the caller found by reverse DNS
+	HostResolverReverseDNS = "reverse-dns"
+	// HostResolverPodIP routes requests like HostResolverReverseDNS,
+	// but finds the namespace of the caller from its pod IP
+	HostResolverPodIP = "pod-ip"
+)
+
+const (
+	// LoadBalancingService forwards requests to the Service of their
+	// target, leaving the load balancing to kube-proxy
+	LoadBalancingService = "service"
+	// LoadBalancingRoundRobin forwards requests to the ready endpoints
+	// of the Service of their target in turn
+	LoadBalancingRoundRobin = "round-robin"
+	// LoadBalancingLeastOutstanding forwards requests to the ready
+	// endpoint of the Service of their target with the fewest
+	// requests in flight
+	LoadBalancingLeastOutstanding = "least-outstanding"
+)
+
+const (
+	// AccessLogFormatJSON writes each access log entry as a JSON object
+	AccessLogFormatJSON = "json"
+	// AccessLogFormatCombined writes each access log entry in the
+	// Combined Log Format, followed by the fields that it doesn't
+	// have as key=value pairs
+	AccessLogFormatCombined = "combined"
+)
+
 // Serving is configuration for how the interceptor serves the proxy
 // and admin server
 type Serving struct {
@@ -21,6 +56,109 @@ type Serving struct {
 	// ConfigMapCacheRsyncPeriod is the time interval
 	// for the configmap informer to rsync the local cache.
 	ConfigMapCacheRsyncPeriod time.Duration `envconfig:"KEDA_HTTP_SCALER_CONFIG_MAP_INFORMER_RSYNC_PERIOD" default:"60m"`
+	// PodCacheRsyncPeriod is the time interval for the pod informer
+	// to rsync the local cache. It is only used by the pod-ip host resolver
+	PodCacheRsyncPeriod time.Duration `envconfig:"KEDA_HTTP_POD_CACHE_INFORMER_RSYNC_PERIOD" default:"60m"`
+	// HostResolver selects how the host that requests are routed by is
+	// resolved. One of "header", "reverse-dns" or "pod-ip"
+	HostResolver string `envconfig:"KEDA_HTTP_HOST_RESOLVER" default:"reverse-dns"`
+	// MaxPendingRequestsStatus is the status code that requests are
+	// rejected with while their host is at its maximum number of
+	// pending requests. Either 429 or 503
+	MaxPendingRequestsStatus int `envconfig:"KEDA_HTTP_MAX_PENDING_REQUESTS_STATUS" default:"503"`
+	// MaxPendingRequestsRetryAfter is sent in the Retry-After header
+	// of requests rejected because of a maximum number of pending requests
+	MaxPendingRequestsRetryAfter time.Duration `envconfig:"KEDA_HTTP_MAX_PENDING_REQUESTS_RETRY_AFTER" default:"1s"`
+	// RateLimitInterceptorService, if set, is the name of the Service of
+	// the interceptors in CurrentNamespace. The rate limits of hosts are then
+	// divided by the number of endpoints of this Service, so that the
+	// limits hold across all interceptor replicas
+	RateLimitInterceptorService string `envconfig:"KEDA_HTTP_RATE_LIMIT_INTERCEPTOR_SERVICE"`
+	// RateLimitEndpointsPollInterval is how often the endpoints of
+	// RateLimitInterceptorService are counted
+	RateLimitEndpointsPollInterval time.Duration `envconfig:"KEDA_HTTP_RATE_LIMIT_ENDPOINTS_POLL_INTERVAL" default:"10s"`
+	// CircuitBreakerConsecutiveFailures is the number of requests to a
+	// target that must fail in a row for its circuit breaker to open.
+	// Zero disables this trigger
+	CircuitBreakerConsecutiveFailures int `envconfig:"KEDA_HTTP_CIRCUIT_BREAKER_CONSECUTIVE_FAILURES" default:"0"`
+	// CircuitBreakerErrorRate is the ratio, between 0 and 1, of the requests
+	// to a target within CircuitBreakerWindow that must fail for its circuit
+	// breaker to open. Zero disables this trigger
+	CircuitBreakerErrorRate float64 `envconfig:"KEDA_HTTP_CIRCUIT_BREAKER_ERROR_RATE" default:"0"`
+	// CircuitBreakerMinRequests is the number of requests to a target within
+	// CircuitBreakerWindow below which CircuitBreakerErrorRate is not checked
+	CircuitBreakerMinRequests int `envconfig:"KEDA_HTTP_CIRCUIT_BREAKER_MIN_REQUESTS" default:"20"`
+	// CircuitBreakerWindow is the interval that the error rate is computed over
+	CircuitBreakerWindow time.Duration `envconfig:"KEDA_HTTP_CIRCUIT_BREAKER_WINDOW" default:"30s"`
+	// CircuitBreakerCooldown is how long an open circuit breaker rejects
+	// requests before it lets a probe request through
+	CircuitBreakerCooldown time.Duration `envconfig:"KEDA_HTTP_CIRCUIT_BREAKER_COOLDOWN" default:"30s"`
+	// LoadBalancing selects how requests are spread across the pods of
+	// their target. One of "service", "round-robin" or "least-outstanding"
+	LoadBalancing string `envconfig:"KEDA_HTTP_LOAD_BALANCING" default:"service"`
+	// EndpointsCacheRsyncPeriod is the time interval for the Service and
+	// EndpointSlice informers to rsync the local cache
+	EndpointsCacheRsyncPeriod time.Duration `envconfig:"KEDA_HTTP_ENDPOINTS_CACHE_INFORMER_RSYNC_PERIOD" default:"60m"`
+	// ScaleTargetCacheRsyncPeriod is the time interval for the informers of
+	// scale targets that aren't Deployments, e.g. StatefulSets, to rsync the
+	// local cache
+	ScaleTargetCacheRsyncPeriod time.Duration `envconfig:"KEDA_HTTP_SCALE_TARGET_CACHE_INFORMER_RSYNC_PERIOD" default:"60m"`
+	// ScaleFromZero makes the interceptor scale the workload of a target
+	// without replicas to one replica through its scale subresource as soon
+	// as a request for the target comes in, rather than waiting for KEDA to
+	// poll the external scaler and scale the workload
+	ScaleFromZero bool `envconfig:"KEDA_HTTP_SCALE_FROM_ZERO" default:"false"`
+	// ScaleFromZeroTimeout is the timeout of the Kubernetes API
+	// requests that scale a workload from zero
+	ScaleFromZeroTimeout time.Duration `envconfig:"KEDA_HTTP_SCALE_FROM_ZERO_TIMEOUT" default:"5s"`
+	// ScalerQueueStreamAddress, if set, is the address of the gRPC server of
+	// the external scaler. The interceptor then streams its pending request
+	// counts to the scaler as they change, on top of the scaler polling them
+	ScalerQueueStreamAddress string `envconfig:"KEDA_HTTP_SCALER_QUEUE_STREAM_ADDRESS"`
+	// ScalerQueueStreamRetryInterval is how long the interceptor waits
+	// before it reopens a failed stream to ScalerQueueStreamAddress
+	ScalerQueueStreamRetryInterval time.Duration `envconfig:"KEDA_HTTP_SCALER_QUEUE_STREAM_RETRY_INTERVAL" default:"5s"`
+	// RequestRateWindow is the sliding window that the request rate of
+	// each host is averaged over, in whole seconds. The rate is reported
+	// to the scaler along with the pending requests, and so is the 95th
+	// percentile of the wait times of the requests over the same window
+	RequestRateWindow time.Duration `envconfig:"KEDA_HTTP_REQUEST_RATE_WINDOW" default:"1m"`
+	// TracingOTLPEndpoint, if set, is the host:port of the OTLP gRPC
+	// receiver that the interceptor exports the spans of the requests
+	// that it proxies to. Tracing is disabled if it is empty
+	TracingOTLPEndpoint string `envconfig:"KEDA_HTTP_TRACING_OTLP_ENDPOINT"`
+	// TracingOTLPInsecure disables TLS on the connection to TracingOTLPEndpoint
+	TracingOTLPInsecure bool `envconfig:"KEDA_HTTP_TRACING_OTLP_INSECURE" default:"false"`
+	// TracingSampleRatio is the ratio of the requests without a sampled
+	// parent span that are traced. Requests whose parent span was
+	// sampled by the caller are always traced
+	TracingSampleRatio float64 `envconfig:"KEDA_HTTP_TRACING_SAMPLE_RATIO" default:"1"`
+	// TracingServiceName is the service name of the spans of the interceptor
+	TracingServiceName string `envconfig:"KEDA_HTTP_TRACING_SERVICE_NAME" default:"keda-http-interceptor"`
+	// AccessLogFormat is the format of the access log that the proxy
+	// server writes to stdout, with a line for each request that it
+	// served. It is one of the AccessLogFormat constants, or empty to
+	// disable the access log
+	AccessLogFormat string `envconfig:"KEDA_HTTP_ACCESS_LOG_FORMAT"`
+	// AccessLogSampleRate is the ratio of the requests that are
+	// written to the access log, between 0 and 1
+	AccessLogSampleRate float64 `envconfig:"KEDA_HTTP_ACCESS_LOG_SAMPLE_RATE" default:"1"`
+	// AccessLogHostSampleRates overrides AccessLogSampleRate for the
+	// requests of some hosts, by their routing table key, e.g. host#rule,
+	// or else their bare host. It is a comma separated list of host:rate
+	// pairs, e.g. "a.example.com:0.1,b:0"
+	AccessLogHostSampleRates map[string]float64 `envconfig:"KEDA_HTTP_ACCESS_LOG_HOST_SAMPLE_RATES"`
+	// DrainReadinessDelay is how long the interceptor fails its readiness
+	// check before the proxy server stops accepting new connections, when
+	// it is shut down. It should be long enough for the interceptor to be
+	// taken out of the endpoints of the proxy Service
+	DrainReadinessDelay time.Duration `envconfig:"KEDA_HTTP_DRAIN_READINESS_DELAY" default:"5s"`
+	// DrainGracePeriod is how long the proxy server waits for the requests
+	// in flight to finish, once it stopped accepting new connections, before
+	// it closes the connections that are left. DrainReadinessDelay and
+	// DrainGracePeriod should add up to less than the termination grace
+	// period of the interceptor pods
+	DrainGracePeriod time.Duration `envconfig:"KEDA_HTTP_DRAIN_GRACE_PERIOD" default:"20s"`
 	// The interceptor has an internal process that periodically fetches the state
 	// of deployment that is running the servers it forwards to.
 	//
@@ -37,6 +37,13 @@ type Timeouts struct {
 	// ServiceUnavailableRetry is the number of times to retry a request if the
 	// backing service returns a 503 Service Unavailable
 	ServiceUnavailableRetry int `envconfig:"KEDA_SERVICE_UNAVAILABLE_RETRY" default:"3"`
+	// ServiceUnavailableRetryWait is how long to wait before the first
+	// retry of a request. The wait doubles with every retry
+	ServiceUnavailableRetryWait time.Duration `envconfig:"KEDA_SERVICE_UNAVAILABLE_RETRY_WAIT" default:"2s"`
+	// RetryMaxBodyBytes is the largest request body that is buffered
+	// so that the request can be retried. Requests with larger bodies
+	// are not retried
+	RetryMaxBodyBytes int64 `envconfig:"KEDA_HTTP_RETRY_MAX_BODY_BYTES" default:"1048576"`
 }
 
 // Backoff returns a wait.Backoff based on the timeouts in t
@@ -55,6 +62,17 @@ func (t Timeouts) DefaultBackoff() wait.Backoff {
 	return t.Backoff(2, 0.5, 5)
 }
 
+// ServiceUnavailableRetryBackoff returns the wait.Backoff
+// between the retries of a request
+func (t Timeouts) ServiceUnavailableRetryBackoff() wait.Backoff {
+	return wait.Backoff{
+		Duration: t.ServiceUnavailableRetryWait,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    t.ServiceUnavailableRetry,
+	}
+}
+
 // Parse parses standard configs using envconfig and returns a pointer to the
 // newly created config. Returns nil and a non-nil error if parsing failed
 func MustParseTimeouts() *Timeouts {
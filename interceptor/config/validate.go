@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"net/http"
 	"time"
 )
 
@@ -14,5 +15,98 @@ func Validate(srvCfg Serving, timeoutsCfg Timeouts) error {
 			deplCachePollInterval,
 		)
 	}
+	switch srvCfg.HostResolver {
+	case HostResolverHeader, HostResolverReverseDNS, HostResolverPodIP:
+	default:
+		return fmt.Errorf(
+			"host resolver must be one of %q, %q or %q, got %q",
+			HostResolverHeader,
+			HostResolverReverseDNS,
+			HostResolverPodIP,
+			srvCfg.HostResolver,
+		)
+	}
+	switch srvCfg.LoadBalancing {
+	case LoadBalancingService, LoadBalancingRoundRobin, LoadBalancingLeastOutstanding:
+	default:
+		return fmt.Errorf(
+			"load balancing must be one of %q, %q or %q, got %q",
+			LoadBalancingService,
+			LoadBalancingRoundRobin,
+			LoadBalancingLeastOutstanding,
+			srvCfg.LoadBalancing,
+		)
+	}
+	switch srvCfg.MaxPendingRequestsStatus {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+	default:
+		return fmt.Errorf(
+			"max pending requests status must be %d or %d, got %d",
+			http.StatusTooManyRequests,
+			http.StatusServiceUnavailable,
+			srvCfg.MaxPendingRequestsStatus,
+		)
+	}
+	if srvCfg.MaxPendingRequestsRetryAfter < 0 {
+		return fmt.Errorf(
+			"max pending requests retry after (%s) must not be negative",
+			srvCfg.MaxPendingRequestsRetryAfter,
+		)
+	}
+	if srvCfg.RateLimitInterceptorService != "" && srvCfg.RateLimitEndpointsPollInterval <= 0 {
+		return fmt.Errorf(
+			"rate limit endpoints poll interval (%s) must be positive",
+			srvCfg.RateLimitEndpointsPollInterval,
+		)
+	}
+	if srvCfg.CircuitBreakerErrorRate < 0 || srvCfg.CircuitBreakerErrorRate > 1 {
+		return fmt.Errorf(
+			"circuit breaker error rate must be between 0 and 1, got %v",
+			srvCfg.CircuitBreakerErrorRate,
+		)
+	}
+	if srvCfg.CircuitBreakerErrorRate > 0 && srvCfg.CircuitBreakerWindow <= 0 {
+		return fmt.Errorf(
+			"circuit breaker window (%s) must be positive",
+			srvCfg.CircuitBreakerWindow,
+		)
+	}
+	switch srvCfg.AccessLogFormat {
+	case "", AccessLogFormatJSON, AccessLogFormatCombined:
+	default:
+		return fmt.Errorf(
+			"access log format must be empty, %q or %q, got %q",
+			AccessLogFormatJSON,
+			AccessLogFormatCombined,
+			srvCfg.AccessLogFormat,
+		)
+	}
+	if srvCfg.AccessLogSampleRate < 0 || srvCfg.AccessLogSampleRate > 1 {
+		return fmt.Errorf(
+			"access log sample rate must be between 0 and 1, got %v",
+			srvCfg.AccessLogSampleRate,
+		)
+	}
+	for host, rate := range srvCfg.AccessLogHostSampleRates {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf(
+				"access log sample rate of host %q must be between 0 and 1, got %v",
+				host,
+				rate,
+			)
+		}
+	}
+	if srvCfg.DrainReadinessDelay < 0 {
+		return fmt.Errorf(
+			"drain readiness delay (%s) must not be negative",
+			srvCfg.DrainReadinessDelay,
+		)
+	}
+	if srvCfg.DrainGracePeriod < 0 {
+		return fmt.Errorf(
+			"drain grace period (%s) must not be negative",
+			srvCfg.DrainGracePeriod,
+		)
+	}
 	return nil
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	kedahttp "github.com/kedacore/http-add-on/pkg/http"
+	"github.com/kedacore/http-add-on/pkg/queue"
+)
+
+// drainProgressInterval is how often the progress
+// of draining the proxy server is logged
+const drainProgressInterval = time.Second
+
+// readiness is the readiness of the interceptor, which the
+// admin server reports on /readyz. It fails once the interceptor
+// starts draining, so that it is taken out of the endpoints of
+// the proxy Service before it stops accepting new connections
+type readiness struct {
+	draining atomic.Bool
+}
+
+// startDraining makes the readiness check fail from now on
+func (rd *readiness) startDraining() {
+	rd.draining.Store(true)
+}
+
+// ServeHTTP implements http.Handler
+func (rd *readiness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if rd.draining.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("draining"))
+		return
+	}
+	_, _ = w.Write([]byte("OK"))
+}
+
+// pendingRequests returns the number of requests
+// that are pending in q, across all hosts
+func pendingRequests(q queue.CountReader) (int, error) {
+	cur, err := q.Current()
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, count := range cur.Counts {
+		total += count
+	}
+	return total, nil
+}
+
+// newQueueDrainFunc returns a kedahttp.DrainFunc that waits for
+// the pending requests of q to finish, logging how many are left
+// every interval.
+//
+// Hosts whose resize is postponed keep a pending request after
+// their last one finished, so the wait also ends once the proxy
+// server has no connections left, which ends the drain context
+func newQueueDrainFunc(
+	lggr logr.Logger,
+	q queue.CountReader,
+	interval time.Duration,
+) kedahttp.DrainFunc {
+	lggr = lggr.WithName("drain")
+	return func(ctx context.Context) {
+		start := time.Now()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			pending, err := pendingRequests(q)
+			if err != nil {
+				lggr.Error(err, "getting pending requests")
+			} else if pending == 0 {
+				lggr.Info("drained all requests", "elapsed", time.Since(start))
+				return
+			} else {
+				lggr.Info("draining requests", "pending", pending, "elapsed", time.Since(start))
+			}
+
+			select {
+			case <-ctx.Done():
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					lggr.Info(
+						"drain grace period is over, closing the connections left",
+						"pending", pending,
+						"elapsed", time.Since(start),
+					)
+				} else {
+					lggr.Info("proxy server has no connections left", "elapsed", time.Since(start))
+				}
+				return
+			case <-ticker.C:
+			}
+		}
+	}
+}
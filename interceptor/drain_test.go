@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kedacore/http-add-on/pkg/queue"
+)
+
+func TestReadiness(t *testing.T) {
+	r := require.New(t)
+	ready := new(readiness)
+
+	res := httptest.NewRecorder()
+	ready.ServeHTTP(res, httptest.NewRequest("GET", "/readyz", nil))
+	r.Equal(http.StatusOK, res.Code)
+
+	ready.startDraining()
+	res = httptest.NewRecorder()
+	ready.ServeHTTP(res, httptest.NewRequest("GET", "/readyz", nil))
+	r.Equal(http.StatusServiceUnavailable, res.Code)
+}
+
+// the drain should wait until there are no pending requests left
+func TestQueueDrainFunc(t *testing.T) {
+	r := require.New(t)
+	q := queue.NewMemory(time.Second, false, time.Minute, logr.Discard())
+	r.NoError(q.Resize("host1", 2))
+	r.NoError(q.Resize("host2", 1))
+	drain := newQueueDrainFunc(logr.Discard(), q, 10*time.Millisecond)
+
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		drain(context.Background())
+	}()
+
+	for _, host := range []string{"host1", "host2", "host1"} {
+		select {
+		case <-drained:
+			t.Fatal("drain finished with pending requests")
+		case <-time.After(50 * time.Millisecond):
+		}
+		r.NoError(q.Resize(host, -1))
+	}
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("drain didn't finish without pending requests")
+	}
+}
+
+// the drain should stop waiting once its context is done,
+// e.g. because the grace period is over
+func TestQueueDrainFuncDone(t *testing.T) {
+	r := require.New(t)
+	q := queue.NewMemory(time.Second, false, time.Minute, logr.Discard())
+	r.NoError(q.Resize("host1", 1))
+	drain := newQueueDrainFunc(logr.Discard(), q, 10*time.Millisecond)
+
+	ctx, done := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer done()
+	start := time.Now()
+	drain(ctx)
+	r.Less(time.Since(start), time.Second)
+	r.Equal(1, q.Count("host1"))
+}
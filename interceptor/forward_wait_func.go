@@ -3,16 +3,41 @@ package main
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
 
 	"github.com/kedacore/http-add-on/pkg/k8s"
+	"github.com/kedacore/http-add-on/pkg/queue"
+	"github.com/kedacore/http-add-on/pkg/routing"
 )
 
-// forwardWaitFunc is a function that waits for a condition.
-// before proceeding to serve the request.
-type forwardWaitFunc func(context.Context, string, string) (int, error)
+// forwardWaitFunc is a function that waits for a condition
+// of the routing target before proceeding to serve the request.
+type forwardWaitFunc func(context.Context, routing.Target) (int, error)
+
+// newTargetForwardWaitFunc returns a forwardWaitFunc that waits with
+// endpointsWait for the targets whose ReadinessCheck is
+// routing.ReadinessCheckEndpoints. Otherwise, it waits with deployWait
+// for the targets served by a Deployment, and with scaleTargetWait for
+// the targets served by other kinds of workloads
+func newTargetForwardWaitFunc(
+	deployWait,
+	scaleTargetWait,
+	endpointsWait forwardWaitFunc,
+) forwardWaitFunc {
+	return func(ctx context.Context, target routing.Target) (int, error) {
+		switch {
+		case target.ReadinessCheck == routing.ReadinessCheckEndpoints:
+			return endpointsWait(ctx, target)
+		case target.Kind != "":
+			return scaleTargetWait(ctx, target)
+		default:
+			return deployWait(ctx, target)
+		}
+	}
+}
 
 func deploymentCanServe(depl appsv1.Deployment) bool {
 	return depl.Status.ReadyReplicas > 0
@@ -22,7 +47,8 @@ func newDeployReplicasForwardWaitFunc(
 	lggr logr.Logger,
 	deployCache k8s.DeploymentCache,
 ) forwardWaitFunc {
-	return func(ctx context.Context, deployNS, deployName string) (int, error) {
+	return func(ctx context.Context, target routing.Target) (int, error) {
+		deployNS, deployName := target.Namespace, target.Deployment
 		// get a watcher & its result channel before querying the
 		// deployment cache, to ensure we don't miss events
 		watcher, err := deployCache.Watch(deployNS, deployName)
@@ -70,3 +96,137 @@ func newDeployReplicasForwardWaitFunc(
 		}
 	}
 }
+
+// newScaleTargetReplicasForwardWaitFunc returns a forwardWaitFunc that
+// waits until the workload of the target, which isn't a Deployment,
+// has a ready replica
+func newScaleTargetReplicasForwardWaitFunc(
+	lggr logr.Logger,
+	scaleTargetCache k8s.ScaleTargetCache,
+) forwardWaitFunc {
+	return func(ctx context.Context, target routing.Target) (int, error) {
+		apiVersion, kind := target.APIVersion, target.Kind
+		ns, name := target.Namespace, target.Deployment
+		// get a watcher & its result channel before querying the
+		// scale target cache, to ensure we don't miss events
+		watcher, err := scaleTargetCache.Watch(apiVersion, kind, ns, name)
+		if err != nil {
+			return 0, err
+		}
+		eventCh := watcher.ResultChan()
+		defer watcher.Stop()
+
+		replicas, err := scaleTargetCache.ReadyReplicas(ctx, apiVersion, kind, ns, name)
+		if err != nil {
+			// if we didn't get the initial workload state, bail out
+			return 0, fmt.Errorf(
+				"error getting state for %s %s/%s (%s)",
+				kind,
+				ns,
+				name,
+				err,
+			)
+		}
+		// if there is 1 or more replica, we're done waiting
+		if replicas > 0 {
+			return int(replicas), nil
+		}
+
+		for {
+			select {
+			case <-eventCh:
+				replicas, err := scaleTargetCache.ReadyReplicas(ctx, apiVersion, kind, ns, name)
+				if err != nil {
+					lggr.Error(err, "getting ready replicas", "kind", kind, "namespace", ns, "name", name)
+				} else if replicas > 0 {
+					return 0, nil
+				}
+			case <-ctx.Done():
+				// otherwise, if the context is marked done before
+				// we're done waiting, fail.
+				return 0, fmt.Errorf(
+					"context marked done while waiting for %s %s to reach > 0 replicas (%w)",
+					kind,
+					name,
+					ctx.Err(),
+				)
+			}
+		}
+	}
+}
+
+// newEndpointsForwardWaitFunc returns a forwardWaitFunc that waits
+// until the Service of the target has a ready endpoint behind the
+// port of the target. Unlike waiting for ready replicas, this doesn't
+// let requests through before the Service routes to the new pods
+func newEndpointsForwardWaitFunc(
+	lggr logr.Logger,
+	endpointsCache k8s.EndpointsCache,
+) forwardWaitFunc {
+	return func(ctx context.Context, target routing.Target) (int, error) {
+		svcNS, svcName, port := target.Namespace, target.Service, int32(target.Port)
+		// get a watcher & its result channel before querying the
+		// endpoints cache, to ensure we don't miss events
+		watcher, err := endpointsCache.Watch(svcNS, svcName)
+		if err != nil {
+			return 0, err
+		}
+		eventCh := watcher.ResultChan()
+		defer watcher.Stop()
+
+		// the cache may still be syncing, e.g. on the first cold
+		// start, which ctx bounds like the rest of the wait
+		addrs, err := endpointsCache.ReadyAddresses(ctx, svcNS, svcName, port)
+		if err != nil {
+			// if we didn't get the initial endpoints, bail out
+			return 0, fmt.Errorf(
+				"error getting endpoints for service %s/%s (%w)",
+				svcNS,
+				svcName,
+				err,
+			)
+		}
+		// if there is 1 or more ready endpoint, we're done waiting
+		if len(addrs) > 0 {
+			return len(addrs), nil
+		}
+
+		for {
+			select {
+			case <-eventCh:
+				addrs, err := endpointsCache.ReadyAddresses(ctx, svcNS, svcName, port)
+				if err != nil {
+					lggr.Error(err, "getting endpoints", "namespace", svcNS, "service", svcName)
+				} else if len(addrs) > 0 {
+					return 0, nil
+				}
+			case <-ctx.Done():
+				// otherwise, if the context is marked done before
+				// we're done waiting, fail.
+				return 0, fmt.Errorf(
+					"context marked done while waiting for service %s to have a ready endpoint (%w)",
+					svcName,
+					ctx.Err(),
+				)
+			}
+		}
+	}
+}
+
+// newWaitRecordingForwardWaitFunc returns a forwardWaitFunc that
+// records how long waitFunc waited with recorder, under the routing
+// table key of the request. Waits of requests that countMiddleware
+// didn't route are not recorded, as they aren't counted either
+func newWaitRecordingForwardWaitFunc(
+	recorder queue.WaitRecorder,
+	waitFunc forwardWaitFunc,
+) forwardWaitFunc {
+	return func(ctx context.Context, target routing.Target) (int, error) {
+		start := time.Now()
+		replicas, err := waitFunc(ctx, target)
+		if key, ok := routeKey(ctx); ok {
+			recorder.RecordWait(key, time.Since(start))
+		}
+		return replicas, err
+	}
+}
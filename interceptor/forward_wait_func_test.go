@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"k8s.io/apimachinery/pkg/watch"
 
 	"github.com/kedacore/http-add-on/pkg/k8s"
+	"github.com/kedacore/http-add-on/pkg/routing"
 )
 
 // Test to make sure the wait function returns a nil error if there is immediately
@@ -44,7 +46,7 @@ func TestForwardWaitFuncOneReplica(t *testing.T) {
 	)
 
 	group.Go(func() error {
-		_, err := waitFunc(ctx, ns, deployName)
+		_, err := waitFunc(ctx, routing.Target{Namespace: ns, Deployment: deployName})
 		return err
 	})
 	r.NoError(group.Wait(), "wait function failed, but it shouldn't have")
@@ -78,7 +80,7 @@ func TestForwardWaitFuncNoReplicas(t *testing.T) {
 		cache,
 	)
 
-	_, err := waitFunc(ctx, ns, deployName)
+	_, err := waitFunc(ctx, routing.Target{Namespace: ns, Deployment: deployName})
 	r.Error(err)
 }
 
@@ -123,7 +125,174 @@ func TestWaitFuncWaitsUntilReplicas(t *testing.T) {
 		watcher.Action(watch.Modified, modifiedDeployment)
 		close(replicasIncreasedCh)
 	}()
-	_, err = waitFunc(ctx, ns, deployName)
+	_, err = waitFunc(ctx, routing.Target{Namespace: ns, Deployment: deployName})
 	r.NoError(err)
 	done()
 }
+
+// Test to make sure the endpoints wait function returns as soon as the
+// Service has a ready endpoint, and fails if it never gets one
+func TestEndpointsForwardWaitFunc(t *testing.T) {
+	r := require.New(t)
+	const ns = "testNS"
+	target := routing.NewTarget(ns, "testsvc", 8080, "testdepl", 123)
+	cache := k8s.NewFakeEndpointsCache()
+	waitFunc := newEndpointsForwardWaitFunc(logr.Discard(), cache)
+
+	// the service isn't in the cache at all
+	_, err := waitFunc(context.Background(), target)
+	r.Error(err)
+
+	cache.SetReadyAddresses(ns, "testsvc", 8080, []string{"10.0.0.1:8080"})
+	replicas, err := waitFunc(context.Background(), target)
+	r.NoError(err)
+	r.Equal(1, replicas)
+
+	// no ready endpoints within the timeout
+	cache.SetReadyAddresses(ns, "testsvc", 8080, nil)
+	ctx, done := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer done()
+	_, err = waitFunc(ctx, target)
+	r.ErrorIs(err, context.DeadlineExceeded)
+
+	// the cache doesn't sync within the timeout
+	cache.SetReadyAddresses(ns, "testsvc", 8080, []string{"10.0.0.1:8080"})
+	cache.SetSynced(false)
+	ctx, done = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer done()
+	_, err = waitFunc(ctx, target)
+	r.ErrorIs(err, context.DeadlineExceeded)
+	cache.SetSynced(true)
+	cache.SetReadyAddresses(ns, "testsvc", 8080, nil)
+
+	// endpoints of other services and ports don't end the wait,
+	// the first ready endpoint of the target does
+	ctx, done = context.WithTimeout(context.Background(), 5*time.Second)
+	defer done()
+	group, ctx := errgroup.WithContext(ctx)
+	group.Go(func() error {
+		replicas, err := waitFunc(ctx, target)
+		if err == nil && replicas != 0 {
+			return fmt.Errorf("expected a cold start, got %d replicas", replicas)
+		}
+		return err
+	})
+	time.Sleep(50 * time.Millisecond)
+	cache.SetReadyAddresses(ns, "othersvc", 8080, []string{"10.0.0.2:8080"})
+	cache.SetReadyAddresses(ns, "testsvc", 9090, []string{"10.0.0.3:9090"})
+	cache.SetReadyAddresses(ns, "testsvc", 8080, []string{"10.0.0.4:8080"})
+	r.NoError(group.Wait())
+}
+
+// Test to make sure the scale target wait function returns as soon as
+// the workload has a ready replica, and fails if it never gets one
+func TestScaleTargetForwardWaitFunc(t *testing.T) {
+	r := require.New(t)
+	const ns = "testNS"
+	target := routing.NewTarget(ns, "testsvc", 8080, "teststs", 123)
+	target.APIVersion = "apps/v1"
+	target.Kind = "StatefulSet"
+	cache := k8s.NewFakeScaleTargetCache()
+	waitFunc := newScaleTargetReplicasForwardWaitFunc(logr.Discard(), cache)
+
+	// the workload isn't in the cache at all
+	_, err := waitFunc(context.Background(), target)
+	r.Error(err)
+
+	cache.SetReadyReplicas("apps/v1", "StatefulSet", ns, "teststs", 2)
+	replicas, err := waitFunc(context.Background(), target)
+	r.NoError(err)
+	r.Equal(2, replicas)
+
+	// no ready replicas within the timeout
+	cache.SetReadyReplicas("apps/v1", "StatefulSet", ns, "teststs", 0)
+	ctx, done := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer done()
+	_, err = waitFunc(ctx, target)
+	r.ErrorIs(err, context.DeadlineExceeded)
+
+	// other workloads don't end the wait, the
+	// first ready replica of the target does
+	ctx, done = context.WithTimeout(context.Background(), 5*time.Second)
+	defer done()
+	group, ctx := errgroup.WithContext(ctx)
+	group.Go(func() error {
+		replicas, err := waitFunc(ctx, target)
+		if err == nil && replicas != 0 {
+			return fmt.Errorf("expected a cold start, got %d replicas", replicas)
+		}
+		return err
+	})
+	time.Sleep(50 * time.Millisecond)
+	cache.SetReadyReplicas("apps/v1", "StatefulSet", ns, "otherssts", 1)
+	cache.SetReadyReplicas("argoproj.io/v1alpha1", "Rollout", ns, "teststs", 1)
+	cache.SetReadyReplicas("apps/v1", "StatefulSet", ns, "teststs", 1)
+	r.NoError(group.Wait())
+}
+
+func TestTargetForwardWaitFunc(t *testing.T) {
+	r := require.New(t)
+	waitedFor := ""
+	waitFunc := newTargetForwardWaitFunc(
+		func(context.Context, routing.Target) (int, error) {
+			waitedFor = "deployment"
+			return 1, nil
+		},
+		func(context.Context, routing.Target) (int, error) {
+			waitedFor = "scale target"
+			return 1, nil
+		},
+		func(context.Context, routing.Target) (int, error) {
+			waitedFor = "endpoints"
+			return 1, nil
+		},
+	)
+
+	target := routing.NewTarget("testns", "testsvc", 8080, "testdepl", 123)
+	_, err := waitFunc(context.Background(), target)
+	r.NoError(err)
+	r.Equal("deployment", waitedFor)
+
+	target.APIVersion = "apps/v1"
+	target.Kind = "StatefulSet"
+	_, err = waitFunc(context.Background(), target)
+	r.NoError(err)
+	r.Equal("scale target", waitedFor)
+
+	target.ReadinessCheck = routing.ReadinessCheckEndpoints
+	_, err = waitFunc(context.Background(), target)
+	r.NoError(err)
+	r.Equal("endpoints", waitedFor)
+}
+
+type fakeWaitRecorder struct {
+	waits map[string]time.Duration
+}
+
+func (f *fakeWaitRecorder) RecordWait(host string, d time.Duration) {
+	f.waits[host] = d
+}
+
+func TestWaitRecordingForwardWaitFunc(t *testing.T) {
+	r := require.New(t)
+	recorder := &fakeWaitRecorder{waits: map[string]time.Duration{}}
+	waitFunc := newWaitRecordingForwardWaitFunc(
+		recorder,
+		func(context.Context, routing.Target) (int, error) {
+			time.Sleep(10 * time.Millisecond)
+			return 0, fmt.Errorf("timed out")
+		},
+	)
+
+	// requests without a route aren't recorded
+	target := routing.NewTarget("testns", "testsvc", 8080, "testdepl", 123)
+	_, err := waitFunc(context.Background(), target)
+	r.Error(err)
+	r.Empty(recorder.waits)
+
+	// failed waits are recorded under the key of the route
+	ctx := withRoute(context.Background(), "testkey", &target)
+	_, err = waitFunc(ctx, target)
+	r.Error(err)
+	r.GreaterOrEqual(recorder.waits["testkey"], 10*time.Millisecond)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	"github.com/kedacore/http-add-on/interceptor/config"
+	"github.com/kedacore/http-add-on/pkg/k8s"
+)
+
+// HostResolver resolves the host that a request is routed by, and
+// the namespace of its caller. Resolvers that don't identify callers
+// return an empty caller namespace
+type HostResolver interface {
+	Resolve(r *http.Request) (host string, callerNamespace string, err error)
+}
+
+// newHostResolver returns the HostResolver that servingCfg selects.
+// podCache is only used by the pod IP resolver
+func newHostResolver(
+	lggr logr.Logger,
+	servingCfg *config.Serving,
+	podCache k8s.PodCache,
+) (HostResolver, error) {
+	switch servingCfg.HostResolver {
+	case config.HostResolverHeader:
+		return hostHeaderResolver{}, nil
+	case config.HostResolverReverseDNS:
+		return newReverseDNSResolver(), nil
+	case config.HostResolverPodIP:
+		return newPodIPResolver(lggr, podCache), nil
+	default:
+		return nil, fmt.Errorf("unknown host resolver %q", servingCfg.HostResolver)
+	}
+}
+
+// hostHeaderResolver routes requests by their Host header
+type hostHeaderResolver struct{}
+
+func (hostHeaderResolver) Resolve(r *http.Request) (string, string, error) {
+	if r.Host == "" {
+		return "", "", fmt.Errorf("host not found")
+	}
+	return r.Host, "", nil
+}
+
+// reverseDNSResolver routes requests to $SVC.$NAMESPACE, where $SVC
+// is taken from the Host header and $NAMESPACE is found with a reverse
+// DNS lookup of the caller (see callerServiceHost)
+type reverseDNSResolver struct {
+	lookupAddr func(ctx context.Context, addr string) ([]string, error)
+}
+
+func newReverseDNSResolver() *reverseDNSResolver {
+	return &reverseDNSResolver{
+		lookupAddr: net.DefaultResolver.LookupAddr,
+	}
+}
+
+func (d *reverseDNSResolver) Resolve(r *http.Request) (string, string, error) {
+	remoteIP, err := remoteIP(r)
+	if err != nil {
+		return "", "", err
+	}
+
+	// ReverseDNS lookup on the remote IP
+	names, err := d.lookupAddr(r.Context(), remoteIP)
+	if err != nil {
+		return "", "", fmt.Errorf("error looking up address %q: %s", remoteIP, err)
+	}
+	if len(names) == 0 {
+		return "", "", fmt.Errorf("no names found for address %q", remoteIP)
+	}
+	remoteDNS := names[0]
+	_, _, remoteNs := extractPodInfo(remoteDNS)
+	if remoteNs == "" {
+		return "", "", fmt.Errorf("namespace not found in %q", remoteDNS)
+	}
+
+	host, err := callerServiceHost(r.Host, remoteNs)
+	return host, remoteNs, err
+}
+
+// podIPResolver routes requests like reverseDNSResolver does, but finds
+// the namespace of the caller by looking up its pod in a PodCache
+type podIPResolver struct {
+	lggr     logr.Logger
+	podCache k8s.PodCache
+}
+
+func newPodIPResolver(lggr logr.Logger, podCache k8s.PodCache) *podIPResolver {
+	return &podIPResolver{
+		lggr:     lggr.WithName("podIPResolver"),
+		podCache: podCache,
+	}
+}
+
+func (p *podIPResolver) Resolve(r *http.Request) (string, string, error) {
+	remoteIP, err := remoteIP(r)
+	if err != nil {
+		return "", "", err
+	}
+	pod, err := p.podCache.GetByIP(remoteIP)
+	if err != nil {
+		return "", "", fmt.Errorf("error looking up pod of address %q: %s", remoteIP, err)
+	}
+	p.lggr.V(1).Info(
+		"resolved caller",
+		"remoteIP", remoteIP,
+		"pod", pod.Name,
+		"namespace", pod.Namespace,
+		"workload", pod.Workload,
+	)
+
+	host, err := callerServiceHost(r.Host, pod.Namespace)
+	return host, pod.Namespace, err
+}
+
+// remoteIP returns the IP address that r was sent from
+func remoteIP(r *http.Request) (string, error) {
+	if r.RemoteAddr == "" {
+		return "", fmt.Errorf("remote address not found")
+	}
+	// removing port if exists
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr, nil
+	}
+	return ip, nil
+}
+
+// callerServiceHost returns the $SVC.$NAMESPACE host for a request with
+// the given Host header, sent from the callerNs namespace
+func callerServiceHost(host, callerNs string) (string, error) {
+	if host == "" {
+		return "", fmt.Errorf("host not found")
+	}
+	// removing port if exists
+	if i := strings.Index(host, ":"); i != -1 {
+		host = host[:i]
+	}
+
+	// Extracting service name and namespace from the host header
+	destService, destNs := extractServiceInfo(host)
+
+	// If the caller is from user namespace or
+	// the destination namespace is not provided in the host header
+	// then the destination namespace is the same as the caller namespace
+	if strings.HasPrefix(callerNs, "dp-") || destNs == "" {
+		return fmt.Sprintf("%s.%s", destService, callerNs), nil
+	}
+	return fmt.Sprintf("%s.%s", destService, destNs), nil
+}
+
+// $SVC.$NAMESPACE.svc.cluster.local
+func extractServiceInfo(serviceURL string) (string, string) {
+	parts := strings.Split(serviceURL, ".")
+
+	if len(parts) >= 2 {
+		serviceName := parts[0]
+		namespace := parts[1]
+		return serviceName, namespace
+	}
+
+	if len(parts) == 1 {
+		serviceName := parts[0]
+		return serviceName, ""
+	}
+
+	return "", ""
+}
+
+// $POD_IP.$DEPLOYMENT_NAME.$NAMESPACE.svc.cluster.local
+func extractPodInfo(podURL string) (string, string, string) {
+	parts := strings.Split(podURL, ".")
+
+	if len(parts) >= 3 {
+		podIP := parts[0]
+		deploymentName := parts[1]
+		namespace := parts[2]
+		return podIP, deploymentName, namespace
+	}
+
+	if len(parts) == 2 {
+		podIP := parts[0]
+		deploymentName := parts[1]
+		return podIP, deploymentName, ""
+	}
+
+	return "", "", ""
+}
+
+// hostCtxKey is the context key under which the resolved
+// host of a request is stored
+type hostCtxKey struct{}
+
+// resolvedHost is a host and caller namespace that a
+// HostResolver resolved
+type resolvedHost struct {
+	host     string
+	callerNs string
+}
+
+// withHost returns a copy of ctx that carries the given
+// host and caller namespace
+func withHost(ctx context.Context, host, callerNs string) context.Context {
+	return context.WithValue(ctx, hostCtxKey{}, resolvedHost{host: host, callerNs: callerNs})
+}
+
+// resolveHost returns the host and caller namespace of r according to
+// hostResolver. If r has already been resolved, e.g. by countMiddleware,
+// the earlier result is returned instead, so that hosts are resolved
+// once per request
+func resolveHost(hostResolver HostResolver, r *http.Request) (string, string, error) {
+	if resolved, ok := r.Context().Value(hostCtxKey{}).(resolvedHost); ok {
+		return resolved.host, resolved.callerNs, nil
+	}
+	return hostResolver.Resolve(r)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kedacore/http-add-on/interceptor/config"
+	"github.com/kedacore/http-add-on/pkg/k8s"
+)
+
+type countingResolver struct {
+	calls int
+}
+
+func (c *countingResolver) Resolve(r *http.Request) (string, string, error) {
+	c.calls++
+	return r.Host, "callerns", nil
+}
+
+func newResolverTestRequest(host, remoteAddr string) *http.Request {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = host
+	req.RemoteAddr = remoteAddr
+	return req
+}
+
+func TestHostHeaderResolver(t *testing.T) {
+	r := require.New(t)
+
+	host, callerNs, err := hostHeaderResolver{}.Resolve(newResolverTestRequest("myhost.com:8080", "1.2.3.4:5678"))
+	r.NoError(err)
+	r.Equal("myhost.com:8080", host)
+	r.Empty(callerNs)
+
+	_, _, err = hostHeaderResolver{}.Resolve(newResolverTestRequest("", "1.2.3.4:5678"))
+	r.Error(err)
+}
+
+func TestReverseDNSResolver(t *testing.T) {
+	r := require.New(t)
+	names := map[string][]string{
+		"1.2.3.4": {"1-2-3-4.caller.dp-user.svc.cluster.local."},
+		"1.2.3.5": {"1-2-3-5.caller.system.svc.cluster.local."},
+		"1.2.3.6": {"1-2-3-6.caller"},
+	}
+	resolver := &reverseDNSResolver{
+		lookupAddr: func(_ context.Context, addr string) ([]string, error) {
+			return names[addr], nil
+		},
+	}
+
+	testCases := []struct {
+		host       string
+		remoteAddr string
+		expected   string
+		callerNs   string
+	}{
+		// callers in user namespaces always stay in their namespace
+		{"mysvc", "1.2.3.4:5678", "mysvc.dp-user", "dp-user"},
+		{"mysvc.otherns:8080", "1.2.3.4:5678", "mysvc.dp-user", "dp-user"},
+		{"mysvc", "1.2.3.5:5678", "mysvc.system", "system"},
+		{"mysvc.otherns.svc.cluster.local", "1.2.3.5:5678", "mysvc.otherns", "system"},
+	}
+	for _, tc := range testCases {
+		host, callerNs, err := resolver.Resolve(newResolverTestRequest(tc.host, tc.remoteAddr))
+		r.NoError(err, "host %s, remote %s", tc.host, tc.remoteAddr)
+		r.Equal(tc.expected, host, "host %s, remote %s", tc.host, tc.remoteAddr)
+		r.Equal(tc.callerNs, callerNs, "host %s, remote %s", tc.host, tc.remoteAddr)
+	}
+
+	// no namespace in the name of the caller
+	_, _, err := resolver.Resolve(newResolverTestRequest("mysvc", "1.2.3.6:5678"))
+	r.Error(err)
+	// no name for the caller
+	_, _, err = resolver.Resolve(newResolverTestRequest("mysvc", "4.3.2.1:5678"))
+	r.Error(err)
+}
+
+func TestPodIPResolver(t *testing.T) {
+	r := require.New(t)
+	podCache := k8s.FakePodCache{
+		"1.2.3.4": {Name: "caller", Namespace: "dp-user"},
+		"fd00::1": {Name: "caller", Namespace: "system"},
+	}
+	resolver := newPodIPResolver(logr.Discard(), podCache)
+
+	host, callerNs, err := resolver.Resolve(newResolverTestRequest("mysvc.otherns", "1.2.3.4:5678"))
+	r.NoError(err)
+	r.Equal("mysvc.dp-user", host)
+	r.Equal("dp-user", callerNs)
+
+	host, callerNs, err = resolver.Resolve(newResolverTestRequest("mysvc.otherns", "[fd00::1]:5678"))
+	r.NoError(err)
+	r.Equal("mysvc.otherns", host)
+	r.Equal("system", callerNs)
+
+	_, _, err = resolver.Resolve(newResolverTestRequest("mysvc", "4.3.2.1:5678"))
+	r.Error(err)
+}
+
+func TestNewHostResolver(t *testing.T) {
+	r := require.New(t)
+	for name, expected := range map[string]HostResolver{
+		config.HostResolverHeader:     hostHeaderResolver{},
+		config.HostResolverReverseDNS: &reverseDNSResolver{},
+		config.HostResolverPodIP:      &podIPResolver{},
+	} {
+		resolver, err := newHostResolver(logr.Discard(), &config.Serving{HostResolver: name}, k8s.FakePodCache{})
+		r.NoError(err, name)
+		r.IsType(expected, resolver, name)
+	}
+
+	_, err := newHostResolver(logr.Discard(), &config.Serving{HostResolver: "nope"}, nil)
+	r.Error(err)
+}
+
+func TestResolveHostOncePerRequest(t *testing.T) {
+	r := require.New(t)
+	resolver := &countingResolver{}
+	req := newResolverTestRequest("myhost.com", "1.2.3.4:5678")
+
+	host, callerNs, err := resolveHost(resolver, req)
+	r.NoError(err)
+	r.Equal("myhost.com", host)
+	r.Equal("callerns", callerNs)
+	r.Equal(1, resolver.calls)
+
+	req = req.WithContext(withHost(req.Context(), host, callerNs))
+	host, callerNs, err = resolveHost(resolver, req)
+	r.NoError(err)
+	r.Equal("myhost.com", host)
+	r.Equal("callerns", callerNs)
+	r.Equal(1, resolver.calls)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-logr/logr"
+
+	"github.com/kedacore/http-add-on/interceptor/config"
+	"github.com/kedacore/http-add-on/pkg/k8s"
+)
+
+// endpointBalancer spreads the requests to a Service across the
+// ready endpoints behind it, instead of leaving that to kube-proxy
+type endpointBalancer struct {
+	lggr  logr.Logger
+	cache k8s.EndpointsCache
+	// leastOutstanding picks the endpoint with the fewest requests
+	// in flight. Endpoints are picked round-robin otherwise
+	leastOutstanding bool
+
+	l           sync.Mutex
+	next        map[string]int
+	outstanding map[string]int
+}
+
+// newEndpointBalancer returns the endpointBalancer for the given
+// load balancing strategy, or nil if requests should be forwarded
+// to the Service of their target
+func newEndpointBalancer(
+	lggr logr.Logger,
+	cache k8s.EndpointsCache,
+	strategy string,
+) *endpointBalancer {
+	if strategy == config.LoadBalancingService {
+		return nil
+	}
+	return &endpointBalancer{
+		lggr:             lggr.WithName("endpointBalancer"),
+		cache:            cache,
+		leastOutstanding: strategy == config.LoadBalancingLeastOutstanding,
+		next:             map[string]int{},
+		outstanding:      map[string]int{},
+	}
+}
+
+// pick returns the host:port address of a ready endpoint behind port
+// of the Service, and a function to call once the request to it is
+// done. It returns false if the Service has no ready endpoints that
+// the cache knows of, or if the cache hasn't synced yet, so that
+// requests never wait for the cache
+func (b *endpointBalancer) pick(ctx context.Context, namespace, service string, port int32) (string, func(), bool) {
+	if !b.cache.HasSynced() {
+		return "", nil, false
+	}
+	addrs, err := b.cache.ReadyAddresses(ctx, namespace, service, port)
+	if err != nil {
+		b.lggr.Error(err, "getting ready endpoints", "namespace", namespace, "service", service)
+		return "", nil, false
+	}
+	if len(addrs) == 0 {
+		return "", nil, false
+	}
+
+	b.l.Lock()
+	defer b.l.Unlock()
+	// start from the next endpoint in turn, so that round-robin
+	// moves along and least-outstanding spreads out its ties
+	svcKey := fmt.Sprintf("%s/%s:%d", namespace, service, port)
+	start := b.next[svcKey] % len(addrs)
+	b.next[svcKey] = start + 1
+	picked := addrs[start]
+	if b.leastOutstanding {
+		for i := 1; i < len(addrs); i++ {
+			addr := addrs[(start+i)%len(addrs)]
+			if b.outstanding[addr] < b.outstanding[picked] {
+				picked = addr
+			}
+		}
+	}
+	b.outstanding[picked]++
+
+	var once sync.Once
+	done := func() {
+		once.Do(func() {
+			b.l.Lock()
+			defer b.l.Unlock()
+			if b.outstanding[picked]--; b.outstanding[picked] <= 0 {
+				delete(b.outstanding, picked)
+			}
+		})
+	}
+	return picked, done, true
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kedacore/http-add-on/interceptor/config"
+	"github.com/kedacore/http-add-on/pkg/k8s"
+	kedanet "github.com/kedacore/http-add-on/pkg/net"
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+func TestNewEndpointBalancer(t *testing.T) {
+	r := require.New(t)
+	cache := k8s.NewFakeEndpointsCache()
+	r.Nil(newEndpointBalancer(logr.Discard(), cache, config.LoadBalancingService))
+	r.False(newEndpointBalancer(logr.Discard(), cache, config.LoadBalancingRoundRobin).leastOutstanding)
+	r.True(newEndpointBalancer(logr.Discard(), cache, config.LoadBalancingLeastOutstanding).leastOutstanding)
+}
+
+func TestEndpointBalancerRoundRobin(t *testing.T) {
+	r := require.New(t)
+	cache := k8s.NewFakeEndpointsCache()
+	cache.SetReadyAddresses("testns", "testsvc", 8080, []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80"})
+	b := newEndpointBalancer(logr.Discard(), cache, config.LoadBalancingRoundRobin)
+
+	var picked []string
+	for i := 0; i < 4; i++ {
+		addr, done, ok := b.pick(context.Background(), "testns", "testsvc", 8080)
+		r.True(ok)
+		// requests in flight don't matter to round-robin
+		picked = append(picked, addr)
+		if i%2 == 0 {
+			done()
+		}
+	}
+	r.Equal([]string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80", "10.0.0.1:80"}, picked)
+}
+
+func TestEndpointBalancerLeastOutstanding(t *testing.T) {
+	r := require.New(t)
+	cache := k8s.NewFakeEndpointsCache()
+	cache.SetReadyAddresses("testns", "testsvc", 8080, []string{"10.0.0.1:80", "10.0.0.2:80"})
+	b := newEndpointBalancer(logr.Discard(), cache, config.LoadBalancingLeastOutstanding)
+
+	first, doneFirst, ok := b.pick(context.Background(), "testns", "testsvc", 8080)
+	r.True(ok)
+	second, doneSecond, ok := b.pick(context.Background(), "testns", "testsvc", 8080)
+	r.True(ok)
+	r.NotEqual(first, second)
+
+	// the first endpoint is busier than the second from now on
+	_, _, ok = b.pick(context.Background(), "testns", "testsvc", 8080)
+	r.True(ok)
+	doneSecond()
+	for i := 0; i < 3; i++ {
+		addr, done, ok := b.pick(context.Background(), "testns", "testsvc", 8080)
+		r.True(ok)
+		r.Equal(second, addr)
+		done()
+	}
+
+	// done may be called more than once
+	doneFirst()
+	doneFirst()
+	r.Equal(1, b.outstanding[first])
+	r.NotContains(b.outstanding, second)
+}
+
+func TestEndpointBalancerNoEndpoints(t *testing.T) {
+	r := require.New(t)
+	cache := k8s.NewFakeEndpointsCache()
+	cache.SetReadyAddresses("testns", "scaledtozero", 8080, nil)
+	b := newEndpointBalancer(logr.Discard(), cache, config.LoadBalancingRoundRobin)
+
+	_, _, ok := b.pick(context.Background(), "testns", "scaledtozero", 8080)
+	r.False(ok)
+	_, _, ok = b.pick(context.Background(), "testns", "unknown", 8080)
+	r.False(ok)
+}
+
+// requests shouldn't wait for the cache to sync, but
+// be forwarded to the Service of their target instead
+func TestEndpointBalancerNotSynced(t *testing.T) {
+	r := require.New(t)
+	cache := k8s.NewFakeEndpointsCache()
+	cache.SetReadyAddresses("testns", "testsvc", 8080, []string{"10.0.0.1:80"})
+	cache.SetSynced(false)
+	b := newEndpointBalancer(logr.Discard(), cache, config.LoadBalancingRoundRobin)
+
+	_, _, ok := b.pick(context.Background(), "testns", "testsvc", 8080)
+	r.False(ok)
+
+	cache.SetSynced(true)
+	addr, _, ok := b.pick(context.Background(), "testns", "testsvc", 8080)
+	r.True(ok)
+	r.Equal("10.0.0.1:80", addr)
+}
+
+func TestForwardingHandlerEndpointBalancer(t *testing.T) {
+	const host = "TestForwardingHandlerEndpointBalancer.testing"
+	r := require.New(t)
+
+	var origins []*kedanet.TestHTTPHandlerWrapper
+	var addrs []string
+	for i := 0; i < 2; i++ {
+		originHdl := kedanet.NewTestHTTPHandlerWrapper(
+			http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}),
+		)
+		srv, originURL, err := kedanet.StartTestServer(originHdl)
+		r.NoError(err)
+		defer srv.Close()
+		origins = append(origins, originHdl)
+		addrs = append(addrs, originURL.Host)
+	}
+	// the Service itself can't be reached, so every request
+	// must have been sent straight to one of the origins
+	svcURL, err := url.Parse("http://testsvc.testns.invalid:8080")
+	r.NoError(err)
+	routingTable := routing.NewTable()
+	r.NoError(routingTable.AddTarget(host, routing.NewTarget("testns", "testsvc", 8080, "testdepl", 123)))
+	cache := k8s.NewFakeEndpointsCache()
+	cache.SetReadyAddresses("testns", "testsvc", 8080, addrs)
+
+	timeouts := defaultTimeouts()
+	hdl := newForwardingHandler(
+		logr.Discard(),
+		routingTable,
+		hostHeaderResolver{},
+		nil,
+		newEndpointBalancer(logr.Discard(), cache, config.LoadBalancingRoundRobin),
+		retryDialContextFunc(timeouts, timeouts.DefaultBackoff()),
+		func(context.Context, routing.Target) (int, error) {
+			return 1, nil
+		},
+		func(routing.Target) (*url.URL, error) {
+			return svcURL, nil
+		},
+		forwardingConfig{
+			waitTimeout:       timeouts.DeploymentReplicas,
+			respHeaderTimeout: timeouts.ResponseHeader,
+		},
+	)
+
+	for i := 0; i < 4; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = host
+		res := httptest.NewRecorder()
+		hdl.ServeHTTP(res, req)
+		r.Equal(http.StatusOK, res.Code)
+	}
+	for _, origin := range origins {
+		r.Len(origin.IncomingRequests(), 2)
+	}
+	r.Equal("http://testsvc.testns.invalid:8080", svcURL.String(), "the Service URL was modified")
+}
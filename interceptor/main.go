@@ -7,12 +7,24 @@ import (
 	"math/rand"
 	nethttp "net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"golang.org/x/sync/errgroup"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/scale"
 
 	"github.com/kedacore/http-add-on/interceptor/config"
 	"github.com/kedacore/http-add-on/pkg/build"
@@ -20,6 +32,7 @@ import (
 	"github.com/kedacore/http-add-on/pkg/k8s"
 	pkglog "github.com/kedacore/http-add-on/pkg/log"
 	kedanet "github.com/kedacore/http-add-on/pkg/net"
+	"github.com/kedacore/http-add-on/pkg/policy"
 	"github.com/kedacore/http-add-on/pkg/queue"
 	"github.com/kedacore/http-add-on/pkg/routing"
 )
@@ -30,6 +43,15 @@ func init() {
 
 // +kubebuilder:rbac:groups="",namespace=keda,resources=configmaps,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=list;watch
+// +kubebuilder:rbac:groups=argoproj.io,resources=rollouts,verbs=list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",namespace=keda,resources=endpoints,verbs=get
+// +kubebuilder:rbac:groups="",resources=services,verbs=list;watch
+// +kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=list;watch
+//
+// The rules to scale workloads from zero are opt-in, in the
+// kustomize component at config/interceptor/scale-from-zero
 
 func main() {
 	lggr, err := pkglog.NewZapr()
@@ -77,16 +99,117 @@ func main() {
 		os.Exit(1)
 	}
 
+	// the scale target cache serves the targets that aren't Deployments.
+	// it only watches the kinds of workloads that it is asked about
+	dynamicCl, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		lggr.Error(err, "creating new Kubernetes dynamic client")
+		os.Exit(1)
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(cl.Discovery()))
+	scaleTargetCache := k8s.NewInformerBackedScaleTargetCache(
+		lggr,
+		dynamicCl,
+		mapper,
+		servingCfg.ScaleTargetCacheRsyncPeriod,
+	)
+
+	// the pod cache is only needed, and only started, to resolve
+	// hosts by the pod IP of the caller. podCache stays nil otherwise
+	var podCache k8s.PodCache
+	var podInformerCache *k8s.InformerBackedPodCache
+	if servingCfg.HostResolver == config.HostResolverPodIP {
+		podInformerCache = k8s.NewInformerBackedPodCache(
+			lggr,
+			cl,
+			servingCfg.PodCacheRsyncPeriod,
+		)
+		podCache = podInformerCache
+	}
+	// the endpoints cache serves the targets that wait for ready
+	// endpoints, and the load balancing across the pods of targets.
+	// balancer is nil unless that load balancing is enabled. the
+	// informers of the cache only list and watch the cluster once
+	// either of them first uses it
+	endpointsCache := k8s.NewInformerBackedEndpointsCache(
+		lggr,
+		cl,
+		servingCfg.EndpointsCacheRsyncPeriod,
+	)
+	balancer := newEndpointBalancer(lggr, endpointsCache, servingCfg.LoadBalancing)
+	hostResolver, err := newHostResolver(lggr, servingCfg, podCache)
+	if err != nil {
+		lggr.Error(err, "creating host resolver")
+		os.Exit(1)
+	}
+
 	configMapsInterface := cl.CoreV1().ConfigMaps(servingCfg.CurrentNamespace)
 
-	waitFunc := newDeployReplicasForwardWaitFunc(lggr, deployCache)
+	waitFunc := newTargetForwardWaitFunc(
+		newDeployReplicasForwardWaitFunc(lggr, deployCache),
+		newScaleTargetReplicasForwardWaitFunc(lggr, scaleTargetCache),
+		newEndpointsForwardWaitFunc(lggr, endpointsCache),
+	)
+	// with scale from zero, the interceptor scales targets up itself
+	// before it waits for them
+	if servingCfg.ScaleFromZero {
+		scales, err := scale.NewForConfig(
+			cfg,
+			mapper,
+			dynamic.LegacyAPIPathResolverFunc,
+			scale.NewDiscoveryScaleKindResolver(cl.Discovery()),
+		)
+		if err != nil {
+			lggr.Error(err, "creating new Kubernetes scale client")
+			os.Exit(1)
+		}
+		waitFunc = newScaleFromZeroForwardWaitFunc(
+			newScaleFromZero(
+				lggr,
+				newCachedTargetReplicasFunc(deployCache, scaleTargetCache),
+				newScaleSubresourceScaleTargetFunc(scales, mapper),
+				servingCfg.ScaleFromZeroTimeout,
+			),
+			waitFunc,
+		)
+	}
 
 	lggr.Info("Interceptor starting")
 
-	q := queue.NewMemory(servingCfg.RequestQueueCooldown, servingCfg.EnableRequestQueueCooldown, lggr)
+	q := queue.NewMemory(
+		servingCfg.RequestQueueCooldown,
+		servingCfg.EnableRequestQueueCooldown,
+		servingCfg.RequestRateWindow,
+		lggr,
+	)
+	waitFunc = newWaitRecordingForwardWaitFunc(q, waitFunc)
 	routingTable := routing.NewTable()
+	accessPolicies := policy.NewTable()
 	go q.ProcessPostponedResizes(servingCfg.RequestQueueCooldownEnforcerInterval)
 
+	metricsRegistry := prometheus.NewRegistry()
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	metrics := newInterceptorMetrics(lggr, metricsRegistry, q, routingTable)
+
+	// tracing is only set up if there is a receiver to export
+	// spans to. the proxy handlers don't record spans otherwise
+	var tracerProvider *sdktrace.TracerProvider
+	if servingCfg.TracingOTLPEndpoint != "" {
+		tracerProvider, err = newTracerProvider(ctx, servingCfg)
+		if err != nil {
+			lggr.Error(err, "creating the tracer provider")
+			os.Exit(1)
+		}
+		otel.SetTracerProvider(tracerProvider)
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{},
+			propagation.Baggage{},
+		))
+	}
+
 	// Create the informer of ConfigMap resource,
 	// the resynchronization period of the informer should be not less than 1s,
 	// refer to: https://github.com/kubernetes/client-go/blob/v0.22.2/tools/cache/shared_informer.go#L475
@@ -111,8 +234,57 @@ func main() {
 		os.Exit(1)
 	}
 
+	lggr.Info(
+		"Fetching initial access policies",
+	)
+	if err := policy.GetTable(
+		ctx,
+		lggr,
+		configMapsInterface,
+		accessPolicies,
+	); err != nil {
+		lggr.Error(err, "fetching access policies")
+		os.Exit(1)
+	}
+
+	// rate limits are shared by all interceptor replicas if the
+	// interceptor Service is configured, and enforced by each
+	// replica on its own otherwise
+	replicas := new(interceptorReplicas)
+	rateLimiters := newRateLimiters(replicas.count)
+	breakers := newCircuitBreakers(newCircuitBreakerConfigFromServing(servingCfg))
+
 	errGrp, ctx := errgroup.WithContext(ctx)
 
+	// on SIGTERM, the interceptor fails its readiness check, waits for
+	// it to be taken out of the endpoints of the proxy Service, and then
+	// drains the proxy server. the other components keep running until
+	// the proxy server is drained, since the requests in flight need them
+	ready := new(readiness)
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stopSignals()
+	proxyCtx, proxyDone := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signalCtx.Done():
+		}
+		lggr.Info(
+			"shutting down, failing readiness before draining the proxy server",
+			"readinessDelay",
+			servingCfg.DrainReadinessDelay,
+			"gracePeriod",
+			servingCfg.DrainGracePeriod,
+		)
+		ready.startDraining()
+		select {
+		case <-ctx.Done():
+		case <-time.After(servingCfg.DrainReadinessDelay):
+		}
+		proxyDone()
+	}()
+
 	// start the deployment cache updater
 	errGrp.Go(func() error {
 		defer ctxDone()
@@ -121,6 +293,72 @@ func main() {
 		return err
 	})
 
+	// start the scale target cache updater
+	errGrp.Go(func() error {
+		defer ctxDone()
+		err := scaleTargetCache.Start(ctx)
+		lggr.Error(err, "scale target cache watcher failed")
+		return err
+	})
+
+	// start the pod cache updater
+	if podInformerCache != nil {
+		errGrp.Go(func() error {
+			defer ctxDone()
+			err := podInformerCache.Start(ctx)
+			lggr.Error(err, "pod cache watcher failed")
+			return err
+		})
+	}
+
+	// start the endpoints cache updater
+	errGrp.Go(func() error {
+		defer ctxDone()
+		err := endpointsCache.Start(ctx)
+		lggr.Error(err, "endpoints cache watcher failed")
+		return err
+	})
+
+	// start counting the interceptor replicas that share the rate limits
+	if svcName := servingCfg.RateLimitInterceptorService; svcName != "" {
+		errGrp.Go(func() error {
+			defer ctxDone()
+			err := replicas.run(
+				ctx,
+				lggr,
+				k8s.EndpointsFuncForK8sClientset(cl),
+				servingCfg.CurrentNamespace,
+				svcName,
+				proxyPort,
+				servingCfg.RateLimitEndpointsPollInterval,
+			)
+			lggr.Error(err, "interceptor replica counter failed")
+			return err
+		})
+	}
+
+	// start streaming the queue counts to the scaler as they change
+	if addr := servingCfg.ScalerQueueStreamAddress; addr != "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			lggr.Error(err, "getting the hostname")
+			os.Exit(1)
+		}
+		errGrp.Go(func() error {
+			defer ctxDone()
+			err := runQueueStreamer(
+				ctx,
+				lggr,
+				addr,
+				hostname,
+				q,
+				servingCfg.ScalerQueueStreamRetryInterval,
+			)
+			lggr.Error(err, "queue counts streamer failed")
+			return err
+		})
+	}
+
 	// start the update loop that updates the routing table from
 	// the ConfigMap that the operator updates as HTTPScaledObjects
 	// enter and exit the system
@@ -132,12 +370,29 @@ func main() {
 			configMapInformer,
 			servingCfg.CurrentNamespace,
 			routingTable,
-			nil,
+			newRoutingStatePruner(routingTable, rateLimiters, breakers, metrics),
 		)
 		lggr.Error(err, "config map routing table updater failed")
 		return err
 	})
 
+	// start the update loop that updates the access policies from
+	// the ConfigMap that the operator updates as HTTPAccessPolicies
+	// enter and exit the system. configMapInformer is started by the
+	// routing table updater
+	errGrp.Go(func() error {
+		defer ctxDone()
+		err := policy.StartConfigMapPolicyUpdater(
+			ctx,
+			lggr,
+			configMapInformer,
+			servingCfg.CurrentNamespace,
+			accessPolicies,
+		)
+		lggr.Error(err, "config map access policy updater failed")
+		return err
+	})
+
 	// start the administrative server. this is the server
 	// that serves the queue size API
 	errGrp.Go(func() error {
@@ -154,6 +409,11 @@ func main() {
 			q,
 			routingTable,
 			deployCache,
+			scaleTargetCache,
+			podCache,
+			breakers,
+			metricsRegistry,
+			ready,
 			adminPort,
 			servingCfg,
 			timeoutCfg,
@@ -172,15 +432,28 @@ func main() {
 			proxyPort,
 		)
 		err := runProxyServer(
-			ctx,
+			proxyCtx,
 			lggr,
 			q,
 			waitFunc,
 			routingTable,
+			hostResolver,
+			accessPolicies,
+			rateLimiters,
+			breakers,
+			balancer,
 			timeoutCfg,
+			newPendingLimitConfigFromServing(servingCfg),
+			metrics,
+			newAccessLoggerFromServing(os.Stdout, servingCfg),
+			servingCfg.DrainGracePeriod,
 			proxyPort,
 		)
-		lggr.Error(err, "proxy server failed")
+		if signalCtx.Err() != nil {
+			lggr.Info("proxy server drained")
+		} else {
+			lggr.Error(err, "proxy server failed")
+		}
 		return err
 	})
 	build.PrintComponentInfo(lggr, "Interceptor")
@@ -188,8 +461,22 @@ func main() {
 	// errGrp.Wait() should hang forever for healthy admin and proxy servers.
 	// if it returns an error, log and exit immediately.
 	waitErr := errGrp.Wait()
-	lggr.Error(waitErr, "error with interceptor")
-	os.Exit(1)
+	exitCode := 1
+	if signalCtx.Err() != nil {
+		lggr.Info("interceptor shut down")
+		exitCode = 0
+	} else {
+		lggr.Error(waitErr, "error with interceptor")
+	}
+	if tracerProvider != nil {
+		// flush the spans that are not exported yet
+		shutdownCtx, shutdownDone := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := tracerProvider.Shutdown(shutdownCtx); err != nil {
+			lggr.Error(err, "shutting down the tracer provider")
+		}
+		shutdownDone()
+	}
+	os.Exit(exitCode)
 }
 
 func runAdminServer(
@@ -199,6 +486,11 @@ func runAdminServer(
 	q queue.Counter,
 	routingTable *routing.Table,
 	deployCache k8s.DeploymentCache,
+	scaleTargetCache k8s.ScaleTargetCache,
+	podCache k8s.PodCache,
+	breakers *circuitBreakers,
+	metricsGatherer prometheus.Gatherer,
+	ready *readiness,
 	port int,
 	servingConfig *config.Serving,
 	timeoutConfig *config.Timeouts,
@@ -209,6 +501,7 @@ func runAdminServer(
 		lggr,
 		adminServer,
 		q,
+		routingTable.MaxPendingRequests,
 	)
 	routing.AddFetchRoute(
 		lggr,
@@ -230,6 +523,38 @@ func runAdminServer(
 			}
 		},
 	)
+	adminServer.HandleFunc(
+		"/scale-targets",
+		func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			if err := json.NewEncoder(w).Encode(scaleTargetCache); err != nil {
+				lggr.Error(err, "encoding scale target cache")
+			}
+		},
+	)
+	// the pod cache only runs with the pod-ip host resolver
+	if podCache != nil {
+		adminServer.HandleFunc(
+			"/pods",
+			func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				if err := json.NewEncoder(w).Encode(podCache); err != nil {
+					lggr.Error(err, "encoding pod cache")
+				}
+			},
+		)
+	}
+	adminServer.HandleFunc(
+		"/circuit_breakers",
+		func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			if err := json.NewEncoder(w).Encode(breakers); err != nil {
+				lggr.Error(err, "encoding circuit breakers")
+			}
+		},
+	)
+	adminServer.Handle("/readyz", ready)
+	adminServer.Handle(
+		"/metrics",
+		promhttp.HandlerFor(metricsGatherer, promhttp.HandlerOpts{}),
+	)
 	kedahttp.AddConfigEndpoint(lggr, adminServer, servingConfig, timeoutConfig)
 	kedahttp.AddVersionEndpoint(lggr.WithName("interceptorAdmin"), adminServer)
 
@@ -244,26 +569,130 @@ func runProxyServer(
 	q queue.Counter,
 	waitFunc forwardWaitFunc,
 	routingTable *routing.Table,
+	hostResolver HostResolver,
+	accessPolicies *policy.Table,
+	rateLimiters *rateLimiters,
+	breakers *circuitBreakers,
+	balancer *endpointBalancer,
 	timeouts *config.Timeouts,
+	pendingLimitCfg pendingLimitConfig,
+	metrics *interceptorMetrics,
+	accessLog *accessLogger,
+	drainGracePeriod time.Duration,
 	port int,
 ) error {
 	lggr = lggr.WithName("runProxyServer")
 	dialer := kedanet.NewNetDialer(timeouts.Connect, timeouts.KeepAlive)
 	dialContextFunc := kedanet.DialContextWithRetry(dialer, timeouts.DefaultBackoff())
-	proxyHdl := countMiddleware(
+	proxyHdl := newProxyHandler(
 		lggr,
 		q,
-		newForwardingHandler(
+		waitFunc,
+		routingTable,
+		hostResolver,
+		accessPolicies,
+		rateLimiters,
+		breakers,
+		balancer,
+		dialContextFunc,
+		routing.ServiceURL,
+		timeouts,
+		pendingLimitCfg,
+		metrics,
+		accessLog,
+	)
+
+	addr := fmt.Sprintf("0.0.0.0:%d", port)
+	lggr.Info("proxy server starting", "address", addr)
+	return kedahttp.ServeContextWithDrain(
+		ctx,
+		addr,
+		proxyHdl,
+		drainGracePeriod,
+		newQueueDrainFunc(lggr, q, drainProgressInterval),
+	)
+}
+
+// newProxyHandler returns the handler of the proxy server, which
+// runs requests through all the middlewares before it forwards
+// them to the services that targetSvcURL returns
+func newProxyHandler(
+	lggr logr.Logger,
+	q queue.Counter,
+	waitFunc forwardWaitFunc,
+	routingTable *routing.Table,
+	hostResolver HostResolver,
+	accessPolicies *policy.Table,
+	rateLimiters *rateLimiters,
+	breakers *circuitBreakers,
+	balancer *endpointBalancer,
+	dialContextFunc kedanet.DialContextFunc,
+	targetSvcURL routing.ServiceURLFunc,
+	timeouts *config.Timeouts,
+	pendingLimitCfg pendingLimitConfig,
+	metrics *interceptorMetrics,
+	accessLog *accessLogger,
+) nethttp.Handler {
+	proxyHdl := metricsMiddleware(
+		metrics,
+		accessMiddleware(
 			lggr,
 			routingTable,
-			dialContextFunc,
-			waitFunc,
-			routing.ServiceURL,
-			newForwardingConfigFromTimeouts(timeouts),
+			hostResolver,
+			accessPolicies,
+			rateLimitMiddleware(
+				lggr,
+				routingTable,
+				hostResolver,
+				rateLimiters,
+				countMiddleware(
+					lggr,
+					q,
+					routingTable,
+					hostResolver,
+					pendingLimitCfg,
+					newForwardingHandler(
+						lggr,
+						routingTable,
+						hostResolver,
+						breakers,
+						balancer,
+						dialContextFunc,
+						waitFunc,
+						targetSvcURL,
+						newForwardingConfigFromTimeouts(timeouts),
+					),
+				),
+			),
 		),
 	)
 
-	addr := fmt.Sprintf("0.0.0.0:%d", port)
-	lggr.Info("proxy server starting", "address", addr)
-	return kedahttp.ServeContext(ctx, addr, proxyHdl)
+	if accessLog != nil {
+		proxyHdl = accessLogMiddleware(lggr, accessLog, proxyHdl)
+	}
+	return proxyHdl
+}
+
+// newRoutingStatePruner returns a callback for routing table updates
+// that forgets the rate limiters, circuit breakers and metrics of the
+// keys that are no longer in routingTable
+func newRoutingStatePruner(
+	routingTable routing.TableReader,
+	rateLimiters *rateLimiters,
+	breakers *circuitBreakers,
+	metrics *interceptorMetrics,
+) func() error {
+	return func() error {
+		keys := map[string]bool{}
+		for _, key := range routingTable.Hosts() {
+			keys[key] = true
+		}
+		keep := func(key string) bool {
+			return keys[key]
+		}
+		rateLimiters.prune(keep)
+		breakers.prune(keep)
+		metrics.prune(keep)
+		return nil
+	}
 }
@@ -1,16 +1,21 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
 	appsv1 "k8s.io/api/apps/v1"
@@ -20,6 +25,7 @@ import (
 	"github.com/kedacore/http-add-on/interceptor/config"
 	"github.com/kedacore/http-add-on/pkg/k8s"
 	kedanet "github.com/kedacore/http-add-on/pkg/net"
+	"github.com/kedacore/http-add-on/pkg/policy"
 	"github.com/kedacore/http-add-on/pkg/queue"
 	"github.com/kedacore/http-add-on/pkg/routing"
 	"github.com/kedacore/http-add-on/pkg/test"
@@ -65,7 +71,7 @@ func TestRunProxyServerCountMiddleware(t *testing.T) {
 	))
 	timeouts := &config.Timeouts{}
 	waiterCh := make(chan struct{})
-	waitFunc := func(_ context.Context, _, _ string) (int, error) {
+	waitFunc := func(context.Context, routing.Target) (int, error) {
 		<-waiterCh
 		return 1, nil
 	}
@@ -76,7 +82,16 @@ func TestRunProxyServerCountMiddleware(t *testing.T) {
 			q,
 			waitFunc,
 			routingTable,
+			hostHeaderResolver{},
+			policy.NewTable(),
+			newRateLimiters(nil),
+			nil,
+			nil,
 			timeouts,
+			pendingLimitConfig{},
+			newInterceptorMetrics(logr.Discard(), prometheus.NewRegistry(), q, routingTable),
+			nil,
+			0,
 			port,
 		)
 	})
@@ -148,6 +163,131 @@ func TestRunProxyServerCountMiddleware(t *testing.T) {
 	r.Error(g.Wait())
 }
 
+// Upgrade requests, like WebSocket ones, should
+// make it through all the middlewares of the proxy
+func TestProxyHandlerUpgrade(t *testing.T) {
+	const host = "samplehost"
+	r := require.New(t)
+
+	// the origin switches to a protocol that echoes what it reads
+	originHdl := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+		_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+			"Connection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		_ = brw.Flush()
+		_, _ = io.Copy(conn, brw)
+	})
+	originSrv, originURL, err := kedanet.StartTestServer(originHdl)
+	r.NoError(err)
+	defer originSrv.Close()
+	originPort, err := strconv.Atoi(originURL.Port())
+	r.NoError(err)
+	q := queue.NewMemory(time.Second, false, time.Minute, logr.Discard())
+	routingTable := routing.NewTable()
+	r.NoError(routingTable.AddTarget(
+		host,
+		targetFromURL(
+			originURL,
+			originPort,
+			"testdepl",
+		),
+	))
+	waitFunc := func(context.Context, routing.Target) (int, error) {
+		return 1, nil
+	}
+	accessLog := newAccessLoggerFromServing(io.Discard, &config.Serving{
+		AccessLogFormat:     config.AccessLogFormatJSON,
+		AccessLogSampleRate: 1,
+	})
+	timeouts := &config.Timeouts{}
+	proxyHdl := newProxyHandler(
+		logr.Discard(),
+		q,
+		waitFunc,
+		routingTable,
+		hostHeaderResolver{},
+		policy.NewTable(),
+		newRateLimiters(nil),
+		nil,
+		nil,
+		kedanet.DialContextWithRetry(&net.Dialer{}, timeouts.DefaultBackoff()),
+		func(routing.Target) (*url.URL, error) {
+			return originURL, nil
+		},
+		timeouts,
+		pendingLimitConfig{},
+		newInterceptorMetrics(logr.Discard(), prometheus.NewRegistry(), q, routingTable),
+		accessLog,
+	)
+	proxySrv, proxyURL, err := kedanet.StartTestServer(proxyHdl)
+	r.NoError(err)
+	defer proxySrv.Close()
+
+	conn, err := net.Dial("tcp", proxyURL.Host)
+	r.NoError(err)
+	defer conn.Close()
+	r.NoError(conn.SetDeadline(time.Now().Add(5 * time.Second)))
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: " + host + "\r\n" +
+		"Connection: Upgrade\r\nUpgrade: echo\r\n\r\n"))
+	r.NoError(err)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	r.NoError(err)
+	r.Equal(http.StatusSwitchingProtocols, resp.StatusCode)
+	r.Equal("echo", resp.Header.Get("Upgrade"))
+
+	_, err = conn.Write([]byte("ping"))
+	r.NoError(err)
+	echoed := make([]byte, len("ping"))
+	_, err = io.ReadFull(br, echoed)
+	r.NoError(err)
+	r.Equal("ping", string(echoed))
+}
+
+// the rate limiters and circuit breakers of keys that
+// left the routing table should be forgotten
+func TestRoutingStatePruner(t *testing.T) {
+	r := require.New(t)
+	routingTable := routing.NewTable()
+	r.NoError(routingTable.AddTarget("a.com", routing.NewTarget("testns", "a", 8080, "a", 123)))
+	limit := routing.RateLimit{RequestsPerSecond: 1, Burst: 1}
+	limiters := newRateLimiters(nil)
+	breakers := newCircuitBreakers(circuitBreakerConfig{
+		consecutiveFailures: 1,
+		cooldown:            time.Second,
+	})
+	metrics := newInterceptorMetrics(
+		logr.Discard(),
+		prometheus.NewRegistry(),
+		queue.NewMemory(time.Second, false, time.Minute, logr.Discard()),
+		routingTable,
+	)
+	for _, key := range []string{"a.com", "b.com", ""} {
+		limiters.reserve(key, limit)
+		breakers.get(key).record(false)
+		stats := &requestStats{key: key, namespace: "testns"}
+		stats.waitedFor(time.Second, true)
+		stats.retried()
+		metrics.observe(stats, http.StatusOK, time.Second)
+	}
+
+	r.NoError(newRoutingStatePruner(routingTable, limiters, breakers, metrics)())
+	r.Len(limiters.m, 1)
+	r.Contains(limiters.m, "a.com")
+	r.Len(breakers.m, 1)
+	r.Contains(breakers.m, "a.com")
+	// the series of the requests that weren't routed are kept
+	r.Equal(2, testutil.CollectAndCount(metrics.requests))
+	r.Equal(2, testutil.CollectAndCount(metrics.latency))
+	r.Equal(2, testutil.CollectAndCount(metrics.coldStartWait))
+	r.Equal(2, testutil.CollectAndCount(metrics.retries))
+}
+
 func TestRunAdminServerDeploymentsEndpoint(t *testing.T) {
 	const (
 		ns = "testns"
@@ -174,6 +314,11 @@ func TestRunAdminServerDeploymentsEndpoint(t *testing.T) {
 			queue.NewFakeCounter(),
 			routing.NewTable(),
 			deplCache,
+			k8s.NewFakeScaleTargetCache(),
+			nil,
+			nil,
+			prometheus.NewRegistry(),
+			new(readiness),
 			port,
 			srvCfg,
 			timeoutCfg,
@@ -213,6 +358,58 @@ func TestRunAdminServerDeploymentsEndpoint(t *testing.T) {
 	r.Error(g.Wait())
 }
 
+func TestRunAdminServerPodsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	ctx, done := context.WithCancel(ctx)
+	defer done()
+	lggr := logr.Discard()
+	r := require.New(t)
+	port := rand.Intn(100) + 8100
+	srvCfg := &config.Serving{}
+	timeoutCfg := &config.Timeouts{}
+
+	podCache := k8s.FakePodCache{
+		"1.2.3.4": {
+			Namespace: "testns",
+			Name:      "testpod",
+			Workload:  &k8s.Workload{Kind: "Deployment", Name: "testdepl"},
+		},
+	}
+	g, ctx := errgroup.WithContext(ctx)
+
+	g.Go(func() error {
+		return runAdminServer(
+			ctx,
+			lggr,
+			k8s.FakeConfigMapGetter{},
+			queue.NewFakeCounter(),
+			routing.NewTable(),
+			k8s.NewFakeDeploymentCache(),
+			k8s.NewFakeScaleTargetCache(),
+			podCache,
+			nil,
+			prometheus.NewRegistry(),
+			new(readiness),
+			port,
+			srvCfg,
+			timeoutCfg,
+		)
+	})
+	time.Sleep(500 * time.Millisecond)
+
+	res, err := http.Get(fmt.Sprintf("http://0.0.0.0:%d/pods", port))
+	r.NoError(err)
+	defer res.Body.Close()
+	r.Equal(200, res.StatusCode)
+
+	actual := map[string]k8s.PodInfo{}
+	r.NoError(json.NewDecoder(res.Body).Decode(&actual))
+	r.Equal(map[string]k8s.PodInfo(podCache), actual)
+
+	done()
+	r.Error(g.Wait())
+}
+
 func TestRunAdminServerConfig(t *testing.T) {
 	ctx := context.Background()
 	ctx, done := context.WithCancel(ctx)
@@ -233,6 +430,11 @@ func TestRunAdminServerConfig(t *testing.T) {
 			queue.NewFakeCounter(),
 			routing.NewTable(),
 			k8s.NewFakeDeploymentCache(),
+			k8s.NewFakeScaleTargetCache(),
+			nil,
+			nil,
+			prometheus.NewRegistry(),
+			new(readiness),
 			port,
 			srvCfg,
 			timeoutCfg,
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kedacore/http-add-on/pkg/queue"
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+const (
+	metricsNamespace = "interceptor"
+	hostLabel        = "host"
+	namespaceLabel   = "namespace"
+	codeLabel        = "code"
+)
+
+// interceptorMetrics are the Prometheus metrics of the proxy server.
+// The metrics of a request are labelled by the routing table key that
+// it was routed by, as its host, and the namespace of its Target. The
+// requests that were not routed have neither
+type interceptorMetrics struct {
+	requests      *prometheus.CounterVec
+	latency       *prometheus.HistogramVec
+	coldStartWait *prometheus.HistogramVec
+	retries       *prometheus.CounterVec
+	routingTable  routing.TableReader
+
+	// observedKeys are the routing table keys that the metrics
+	// have series for, so that the series of removed keys can
+	// be deleted
+	observedMut  sync.Mutex
+	observedKeys map[string]struct{}
+}
+
+// newInterceptorMetrics creates the metrics of the proxy server and
+// registers them with reg, along with the pending requests of q and
+// the size of routingTable, which are read when reg is gathered
+func newInterceptorMetrics(
+	lggr logr.Logger,
+	reg prometheus.Registerer,
+	q queue.CountReader,
+	routingTable routing.TableReader,
+) *interceptorMetrics {
+	labels := []string{hostLabel, namespaceLabel}
+	m := &interceptorMetrics{
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "requests_total",
+				Help:      "Number of requests served by the proxy, by status code",
+			},
+			append([]string{codeLabel}, labels...),
+		),
+		latency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: metricsNamespace,
+				Name:      "proxy_latency_seconds",
+				Help:      "Time from the arrival of requests until their response was written",
+				Buckets:   prometheus.DefBuckets,
+			},
+			labels,
+		),
+		coldStartWait: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: metricsNamespace,
+				Name:      "cold_start_wait_seconds",
+				Help:      "Time that requests waited for their target to have ready replicas on cold starts",
+				Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+			},
+			labels,
+		),
+		retries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "retries_total",
+				Help:      "Number of times that requests were retried",
+			},
+			labels,
+		),
+		routingTable: routingTable,
+		observedKeys: map[string]struct{}{},
+	}
+	reg.MustRegister(
+		m.requests,
+		m.latency,
+		m.coldStartWait,
+		m.retries,
+		&stateCollector{
+			lggr:         lggr.WithName("stateCollector"),
+			q:            q,
+			routingTable: routingTable,
+		},
+	)
+	return m
+}
+
+// observe records the metrics of a request that was served
+// with the given status in d, according to its stats
+func (m *interceptorMetrics) observe(stats *requestStats, status int, d time.Duration) {
+	host, ns := stats.key, stats.namespace
+	m.observedMut.Lock()
+	defer m.observedMut.Unlock()
+	m.observedKeys[host] = struct{}{}
+	m.requests.WithLabelValues(strconv.Itoa(status), host, ns).Inc()
+	m.latency.WithLabelValues(host, ns).Observe(d.Seconds())
+	if stats.waited && stats.coldStart {
+		m.coldStartWait.WithLabelValues(host, ns).Observe(stats.wait.Seconds())
+	}
+	if stats.retries > 0 {
+		m.retries.WithLabelValues(host, ns).Add(float64(stats.retries))
+	}
+}
+
+// prune deletes the series of the routing table keys that keep
+// returns false for. The series of the requests that were not
+// routed are kept. It does nothing on a nil m
+func (m *interceptorMetrics) prune(keep func(key string) bool) {
+	if m == nil {
+		return
+	}
+	m.observedMut.Lock()
+	defer m.observedMut.Unlock()
+	for key := range m.observedKeys {
+		if key == "" || keep(key) {
+			continue
+		}
+		labels := prometheus.Labels{hostLabel: key}
+		m.requests.DeletePartialMatch(labels)
+		m.latency.DeletePartialMatch(labels)
+		m.coldStartWait.DeletePartialMatch(labels)
+		m.retries.DeletePartialMatch(labels)
+		delete(m.observedKeys, key)
+	}
+}
+
+// metricsMiddleware records the metrics of each request
+// with metrics, once next has served it. It shares the
+// requestStats of the request with outer middleware, if
+// they created them.
+//
+// The middlewares that reject requests record the route of the
+// requests that they reject in their requestStats, so that e.g.
+// rate limited requests count towards their routing table key
+func metricsMiddleware(
+	metrics *interceptorMetrics,
+	next http.Handler,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx, stats := r.Context(), requestStatsFrom(r.Context())
+		if stats == nil {
+			ctx, stats = withRequestStats(ctx)
+		}
+		rec := newStatusRecorder(w)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		metrics.observe(stats, status, time.Since(start))
+	})
+}
+
+var (
+	pendingRequestsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "pending_requests"),
+		"Number of requests that are pending in the interceptor",
+		[]string{hostLabel, namespaceLabel},
+		nil,
+	)
+	routingTableHostsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "routing_table_hosts"),
+		"Number of entries in the routing table, by the namespace of their target",
+		[]string{namespaceLabel},
+		nil,
+	)
+)
+
+// stateCollector is a prometheus.Collector that reads the pending
+// requests of its queue and the size of its routing table when
+// it is collected, so that they are never out of date
+type stateCollector struct {
+	lggr         logr.Logger
+	q            queue.CountReader
+	routingTable routing.TableReader
+}
+
+// Describe implements prometheus.Collector
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pendingRequestsDesc
+	ch <- routingTableHostsDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	hostsByNs := map[string]int{}
+	for _, key := range c.routingTable.Hosts() {
+		target, err := c.routingTable.Lookup(key)
+		if err != nil {
+			continue
+		}
+		hostsByNs[target.Namespace]++
+	}
+	for ns, n := range hostsByNs {
+		ch <- prometheus.MustNewConstMetric(
+			routingTableHostsDesc,
+			prometheus.GaugeValue,
+			float64(n),
+			ns,
+		)
+	}
+
+	cur, err := c.q.Current()
+	if err != nil {
+		c.lggr.Error(err, "getting queue counts")
+		return
+	}
+	for key, count := range cur.Counts {
+		ns := ""
+		if target, err := c.routingTable.Lookup(key); err == nil {
+			ns = target.Namespace
+		}
+		ch <- prometheus.MustNewConstMetric(
+			pendingRequestsDesc,
+			prometheus.GaugeValue,
+			float64(count),
+			key,
+			ns,
+		)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kedacore/http-add-on/pkg/queue"
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	r := require.New(t)
+	const host = "testingkeda.com"
+	routingTable := routing.NewTable()
+	target := routing.NewTarget("testns", "testsvc", 8080, "testdepl", 100)
+	target.RateLimit = &routing.RateLimit{RequestsPerSecond: 1, Burst: 1}
+	r.NoError(routingTable.AddTarget(host, target))
+	q := queue.NewMemory(time.Second, false, time.Minute, logr.Discard())
+	metrics := newInterceptorMetrics(logr.Discard(), prometheus.NewRegistry(), q, routingTable)
+
+	// the second request is over the rate limit, so
+	// it is rejected before countMiddleware routes it
+	hdl := metricsMiddleware(
+		metrics,
+		rateLimitMiddleware(
+			logr.Discard(),
+			routingTable,
+			hostHeaderResolver{},
+			newRateLimiters(nil),
+			countMiddleware(
+				logr.Discard(),
+				q,
+				routingTable,
+				hostHeaderResolver{},
+				pendingLimitConfig{},
+				http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+					stats := requestStatsFrom(req.Context())
+					stats.waitedFor(2*time.Second, true)
+					stats.retried()
+					stats.retried()
+					w.WriteHeader(http.StatusCreated)
+				}),
+			),
+		),
+	)
+
+	for _, code := range []int{http.StatusCreated, http.StatusTooManyRequests} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		hdl.ServeHTTP(rec, req)
+		r.Equal(code, rec.Code)
+	}
+
+	r.Equal(1.0, testutil.ToFloat64(metrics.requests.WithLabelValues("201", host, "testns")))
+	r.Equal(1.0, testutil.ToFloat64(metrics.requests.WithLabelValues("429", host, "testns")))
+	r.Equal(2.0, testutil.ToFloat64(metrics.retries.WithLabelValues(host, "testns")))
+	r.Equal(2, testutil.CollectAndCount(metrics.requests))
+	r.Equal(1, testutil.CollectAndCount(metrics.latency))
+	r.Equal(1, testutil.CollectAndCount(metrics.coldStartWait))
+}
+
+func TestStateCollector(t *testing.T) {
+	r := require.New(t)
+	routingTable := routing.NewTable()
+	r.NoError(routingTable.AddTarget("host1", routing.NewTarget("ns1", "svc1", 8080, "depl1", 100)))
+	r.NoError(routingTable.AddTarget("host2", routing.NewTarget("ns1", "svc2", 8080, "depl2", 100)))
+	r.NoError(routingTable.AddTarget("host3", routing.NewTarget("ns2", "svc3", 8080, "depl3", 100)))
+	q := queue.NewMemory(time.Second, false, time.Minute, logr.Discard())
+	r.NoError(q.Resize("host1", 3))
+	r.NoError(q.Resize("host3", 1))
+
+	collector := &stateCollector{
+		lggr:         logr.Discard(),
+		q:            q,
+		routingTable: routingTable,
+	}
+	expected := `
+# HELP interceptor_pending_requests Number of requests that are pending in the interceptor
+# TYPE interceptor_pending_requests gauge
+interceptor_pending_requests{host="host1",namespace="ns1"} 3
+interceptor_pending_requests{host="host3",namespace="ns2"} 1
+# HELP interceptor_routing_table_hosts Number of entries in the routing table, by the namespace of their target
+# TYPE interceptor_routing_table_hosts gauge
+interceptor_routing_table_hosts{namespace="ns1"} 2
+interceptor_routing_table_hosts{namespace="ns2"} 1
+`
+	r.NoError(testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+}
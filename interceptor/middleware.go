@@ -1,113 +1,80 @@
 package main
 
 import (
-	"fmt"
 	"log"
-	"net"
 	"net/http"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/go-logr/logr"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/kedacore/http-add-on/interceptor/config"
 	"github.com/kedacore/http-add-on/pkg/queue"
+	"github.com/kedacore/http-add-on/pkg/routing"
 )
 
-func getHost(r *http.Request) (string, error) {
-	remoteIP := r.RemoteAddr
-	if remoteIP == "" {
-		return "", fmt.Errorf("remote address not found")
-	}
-	// removing port if exists
-	if i := strings.Index(remoteIP, ":"); i != -1 {
-		remoteIP = remoteIP[:i]
-	}
-
-	host := r.Host
-	if host == "" {
-		return "", fmt.Errorf("host not found")
-	}
-	// removing port if exists
-	if i := strings.Index(host, ":"); i != -1 {
-		host = host[:i]
-	}
-
-	// ReverseDNS lookup on the remote IP
-	names, err := net.LookupAddr(remoteIP)
-	if err != nil {
-		return "", fmt.Errorf("error looking up address %q: %s", remoteIP, err)
-	}
-	if len(names) == 0 {
-		return "", fmt.Errorf("no names found for address %q", remoteIP)
-	}
-	remoteDNS := names[0]
-	_, _, remoteNs := extractPodInfo(remoteDNS)
-	if remoteNs == "" {
-		return "", fmt.Errorf("namespace not found in %q", remoteDNS)
-	}
-
-	// Extracting service name and namespace from the host header
-	destService, destNs := extractServiceInfo(host)
-
-	// If the caller is from user namespace or
-	// the destination namespace is not provided in the host header
-	// then the destination namespace is the same as the caller namespace
-	if strings.HasPrefix(remoteNs, "dp-") || destNs == "" {
-		host = fmt.Sprintf("%s.%s", destService, remoteNs)
-	} else {
-		host = fmt.Sprintf("%s.%s", destService, destNs)
-	}
-
-	return host, nil
+// pendingLimitConfig is how countMiddleware rejects requests to
+// targets that are at their maximum number of pending requests
+type pendingLimitConfig struct {
+	status     int
+	retryAfter time.Duration
 }
 
-// $SVC.$NAMESPACE.svc.cluster.local
-func extractServiceInfo(serviceURL string) (string, string) {
-	parts := strings.Split(serviceURL, ".")
-
-	if len(parts) >= 2 {
-		serviceName := parts[0]
-		namespace := parts[1]
-		return serviceName, namespace
-	}
-
-	if len(parts) == 1 {
-		serviceName := parts[0]
-		return serviceName, ""
+func newPendingLimitConfigFromServing(s *config.Serving) pendingLimitConfig {
+	return pendingLimitConfig{
+		status:     s.MaxPendingRequestsStatus,
+		retryAfter: s.MaxPendingRequestsRetryAfter,
 	}
-
-	return "", ""
 }
 
-// $POD_IP.$DEPLOYMENT_NAME.$NAMESPACE.svc.cluster.local
-func extractPodInfo(podURL string) (string, string, string) {
-	parts := strings.Split(podURL, ".")
-
-	if len(parts) >= 3 {
-		podIP := parts[0]
-		deploymentName := parts[1]
-		namespace := parts[2]
-		return podIP, deploymentName, namespace
-	}
-
-	if len(parts) == 2 {
-		podIP := parts[0]
-		deploymentName := parts[1]
-		return podIP, deploymentName, ""
-	}
-
-	return "", "", ""
+// retryAfterHeader returns the Retry-After header value for d,
+// in whole seconds rounded up
+func retryAfterHeader(d time.Duration) string {
+	secs := (d + time.Second - 1) / time.Second
+	return strconv.Itoa(int(secs))
 }
 
 // countMiddleware adds 1 to the given queue counter, executes next
-// (by calling ServeHTTP on it), then decrements the queue counter
+// (by calling ServeHTTP on it), then decrements the queue counter.
+//
+// The request is traced with a server span, which continues the trace
+// context of the request headers if they carry one.
+//
+// The host of a request is resolved with hostResolver, and requests are
+// counted under the routing table key that routingTable
+// matches them to, so that targets sharing a host but serving different
+// path prefixes or weighted backends are counted separately. The
+// host and the match are passed on to next with the request context.
+//
+// If the matched target has a MaxPendingRequests and counting the
+// request would take the count over it, the request is neither counted
+// nor passed to next. It is rejected with the status and Retry-After
+// header of pendingLimitCfg instead
 func countMiddleware(
 	lggr logr.Logger,
 	q queue.Counter,
+	routingTable *routing.Table,
+	hostResolver HostResolver,
+	pendingLimitCfg pendingLimitConfig,
 	next http.Handler,
 ) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		host, err := getHost(r)
+		ctx, span := startSpan(
+			extractTraceContext(r.Context(), r),
+			"interceptor.count",
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPMethod(r.Method)),
+		)
+		r = r.WithContext(ctx)
+		rec := newStatusRecorder(w)
+		w = rec
+		defer func() {
+			endSpanWithStatus(span, rec.status)
+		}()
+
+		host, callerNs, err := resolveHost(hostResolver, r)
 		if err != nil {
 			lggr.Error(err, "not forwarding request")
 			w.WriteHeader(400)
@@ -116,10 +83,33 @@ func countMiddleware(
 			}
 			return
 		}
-		lggr.Info("request received.", "host", host)
-		if err := q.Resize(host, +1); err != nil {
+		r = r.WithContext(withHost(r.Context(), host, callerNs))
+		var maxPending int
+		if key, target, err := routeRequest(routingTable, host, r); err == nil {
+			r = r.WithContext(withRoute(r.Context(), key, target))
+			requestStatsFrom(r.Context()).routed(key, target.Namespace)
+			span.SetAttributes(routeAttributes(key, target.Namespace)...)
+			host = key
+			maxPending = int(target.MaxPendingRequests)
+		}
+		requestLogger(ctx, lggr).Info("request received.", "host", host)
+		admitted, err := q.ResizeWithin(host, +1, maxPending)
+		if err != nil {
 			log.Printf("Error incrementing queue for %q (%s)", r.RequestURI, err)
 		}
+		if !admitted {
+			lggr.Info(
+				"rejecting request over max pending requests",
+				"host", host,
+				"maxPendingRequests", maxPending,
+			)
+			w.Header().Set("Retry-After", retryAfterHeader(pendingLimitCfg.retryAfter))
+			w.WriteHeader(pendingLimitCfg.status)
+			if _, err := w.Write([]byte("Too many pending requests, try again later")); err != nil {
+				lggr.Error(err, "could not write error message to client")
+			}
+			return
+		}
 		defer func() {
 			if q.ShouldPostponeResize() && q.Count(host) == 1 {
 				lggr.Info("postponing resize", "host", host)
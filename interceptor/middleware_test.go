@@ -14,6 +14,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/kedacore/http-add-on/pkg/queue"
+	"github.com/kedacore/http-add-on/pkg/routing"
 )
 
 func TestCountMiddleware(t *testing.T) {
@@ -24,6 +25,9 @@ func TestCountMiddleware(t *testing.T) {
 	middleware := countMiddleware(
 		logr.Discard(),
 		queueCounter,
+		routing.NewTable(),
+		hostHeaderResolver{},
+		pendingLimitConfig{},
 		http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
 			wr.WriteHeader(200)
 			_, err := wr.Write([]byte("OK"))
@@ -74,6 +78,105 @@ func TestCountMiddleware(t *testing.T) {
 	r.Equal(0, agg)
 }
 
+func TestCountMiddlewareMaxPendingRequests(t *testing.T) {
+	ctx := context.Background()
+	const host = "testingkeda.com"
+	r := require.New(t)
+	queueCounter := queue.NewFakeCounter()
+	routingTable := routing.NewTable()
+	target := routing.NewTarget("testns", "testsvc", 8080, "testdepl", 100)
+	target.MaxPendingRequests = 1
+	r.NoError(routingTable.AddTarget(host, target))
+	nextCalled := false
+	middleware := countMiddleware(
+		logr.Discard(),
+		queueCounter,
+		routingTable,
+		hostHeaderResolver{},
+		pendingLimitConfig{
+			status:     http.StatusTooManyRequests,
+			retryAfter: 1500 * time.Millisecond,
+		},
+		http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+			nextCalled = true
+			wr.WriteHeader(200)
+		}),
+	)
+
+	// one request is already pending, so the next one would go
+	// over the limit and is rejected without being counted
+	queueCounter.RetMap[host] = 1
+	req, err := http.NewRequest("GET", "/something", nil)
+	r.NoError(err)
+	req.Host = host
+	agg, respRecorder := expectResizes(
+		ctx,
+		t,
+		0,
+		middleware,
+		req,
+		queueCounter,
+		func(t *testing.T, hostAndCount queue.HostAndCount) {},
+	)
+	r.Equal(http.StatusTooManyRequests, respRecorder.Code)
+	r.Equal("2", respRecorder.Header().Get("Retry-After"))
+	r.False(nextCalled)
+	r.Equal(0, agg)
+	r.Equal(1, queueCounter.Count(host))
+
+	// once the pending request is done, requests are forwarded again
+	queueCounter.RetMap[host] = 0
+	req, err = http.NewRequest("GET", "/something", nil)
+	r.NoError(err)
+	req.Host = host
+	_, respRecorder = expectResizes(
+		ctx,
+		t,
+		2,
+		middleware,
+		req,
+		queueCounter,
+		func(t *testing.T, hostAndCount queue.HostAndCount) {},
+	)
+	r.Equal(200, respRecorder.Code)
+	r.True(nextCalled)
+}
+
+// the request that the queue cooldown keeps counted after it
+// finished shouldn't take up a slot of the max pending requests
+func TestCountMiddlewareMaxPendingRequestsCooldown(t *testing.T) {
+	const host = "testingkeda.com"
+	r := require.New(t)
+	q := queue.NewMemory(time.Minute, true, time.Minute, logr.Discard())
+	routingTable := routing.NewTable()
+	target := routing.NewTarget("testns", "testsvc", 8080, "testdepl", 100)
+	target.MaxPendingRequests = 1
+	r.NoError(routingTable.AddTarget(host, target))
+	middleware := countMiddleware(
+		logr.Discard(),
+		q,
+		routingTable,
+		hostHeaderResolver{},
+		pendingLimitConfig{
+			status:     http.StatusTooManyRequests,
+			retryAfter: time.Second,
+		},
+		http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+			wr.WriteHeader(200)
+		}),
+	)
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/something", nil)
+		req.Host = host
+		res := httptest.NewRecorder()
+		middleware.ServeHTTP(res, req)
+		r.Equal(200, res.Code)
+		// the finished request is kept counted for the cooldown
+		r.Equal(1, q.Count(host))
+	}
+}
+
 // expectResizes creates a new httptest.ResponseRecorder, then passes req through
 // the middleware. every time the middleware calls fakeCounter.Resize(), it calls
 // resizeCheckFn with t and the queue.HostCount that represents the resize call
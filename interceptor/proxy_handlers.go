@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 
 	"github.com/kedacore/http-add-on/interceptor/config"
 	kedanet "github.com/kedacore/http-add-on/pkg/net"
@@ -16,26 +19,30 @@ import (
 )
 
 type forwardingConfig struct {
-	waitTimeout             time.Duration
-	respHeaderTimeout       time.Duration
-	forceAttemptHTTP2       bool
-	maxIdleConns            int
-	idleConnTimeout         time.Duration
-	tlsHandshakeTimeout     time.Duration
-	expectContinueTimeout   time.Duration
-	serviceUnavailableRetry int
+	waitTimeout           time.Duration
+	respHeaderTimeout     time.Duration
+	forceAttemptHTTP2     bool
+	maxIdleConns          int
+	idleConnTimeout       time.Duration
+	tlsHandshakeTimeout   time.Duration
+	expectContinueTimeout time.Duration
+	retries               retryPolicy
 }
 
 func newForwardingConfigFromTimeouts(t *config.Timeouts) forwardingConfig {
 	return forwardingConfig{
-		waitTimeout:             t.DeploymentReplicas,
-		respHeaderTimeout:       t.ResponseHeader,
-		forceAttemptHTTP2:       t.ForceHTTP2,
-		maxIdleConns:            t.MaxIdleConns,
-		idleConnTimeout:         t.IdleConnTimeout,
-		tlsHandshakeTimeout:     t.TLSHandshakeTimeout,
-		expectContinueTimeout:   t.ExpectContinueTimeout,
-		serviceUnavailableRetry: t.ServiceUnavailableRetry,
+		waitTimeout:           t.DeploymentReplicas,
+		respHeaderTimeout:     t.ResponseHeader,
+		forceAttemptHTTP2:     t.ForceHTTP2,
+		maxIdleConns:          t.MaxIdleConns,
+		idleConnTimeout:       t.IdleConnTimeout,
+		tlsHandshakeTimeout:   t.TLSHandshakeTimeout,
+		expectContinueTimeout: t.ExpectContinueTimeout,
+		retries: defaultRetryPolicy(
+			t.ServiceUnavailableRetry,
+			t.ServiceUnavailableRetryBackoff(),
+			t.RetryMaxBodyBytes,
+		),
 	}
 }
 
@@ -48,6 +55,9 @@ func newForwardingConfigFromTimeouts(t *config.Timeouts) forwardingConfig {
 func newForwardingHandler(
 	lggr logr.Logger,
 	routingTable *routing.Table,
+	hostResolver HostResolver,
+	breakers *circuitBreakers,
+	balancer *endpointBalancer,
 	dialCtxFunc kedanet.DialContextFunc,
 	waitFunc forwardWaitFunc,
 	targetSvcURL routing.ServiceURLFunc,
@@ -64,7 +74,7 @@ func newForwardingHandler(
 		ResponseHeaderTimeout: fwdCfg.respHeaderTimeout,
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		host, err := getHost(r)
+		host, _, err := resolveHost(hostResolver, r)
 		if err != nil {
 			w.WriteHeader(400)
 			if _, err := w.Write([]byte("Host not found in request")); err != nil {
@@ -73,7 +83,7 @@ func newForwardingHandler(
 			return
 		}
 		lggr := lggr.WithValues("host", host)
-		routingTarget, err := routingTable.Lookup(host)
+		key, routingTarget, err := routeRequest(routingTable, host, r)
 		if err != nil {
 			w.WriteHeader(404)
 			if _, err := w.Write([]byte(fmt.Sprintf("Host %s not found", r.Host))); err != nil {
@@ -82,13 +92,36 @@ func newForwardingHandler(
 			return
 		}
 
+		// fail fast while the circuit breaker of the target is open,
+		// and feed the outcome of the request back to it otherwise
+		if breaker := breakers.get(key); breaker != nil {
+			if !breaker.allow() {
+				lggr.Info("circuit breaker open, not forwarding request", "key", key)
+				w.Header().Set("Retry-After", retryAfterHeader(breaker.retryAfter()))
+				w.WriteHeader(503)
+				if _, err := w.Write([]byte("Circuit breaker open, try again later")); err != nil {
+					lggr.Error(err, "could not write error response to client")
+				}
+				return
+			}
+			rec := newStatusRecorder(w)
+			w = rec
+			defer func() {
+				breaker.observe(r.Context(), rec.status)
+			}()
+		}
+
 		waitFuncCtx, done := context.WithTimeout(r.Context(), fwdCfg.waitTimeout)
 		defer done()
-		replicas, err := waitFunc(
-			waitFuncCtx,
-			routingTarget.Namespace,
-			routingTarget.Deployment,
-		)
+		waitFuncCtx, waitSpan := startSpan(waitFuncCtx, "interceptor.wait")
+		waitStart := time.Now()
+		replicas, err := waitFunc(waitFuncCtx, *routingTarget)
+		requestStatsFrom(r.Context()).waitedFor(time.Since(waitStart), replicas == 0)
+		waitSpan.SetAttributes(attribute.Bool("keda.http.cold_start", replicas == 0))
+		if err != nil {
+			waitSpan.SetStatus(codes.Error, err.Error())
+		}
+		waitSpan.End()
 		if err != nil {
 			lggr.Error(err, "wait function failed, not forwarding request")
 			w.WriteHeader(502)
@@ -123,12 +156,30 @@ func newForwardingHandler(
 				return
 			}
 		}
+		// send the request straight to a ready pod of the Service,
+		// if there is one that the endpoints cache knows of
+		if balancer != nil {
+			port, err := strconv.Atoi(targetURL.Port())
+			if err != nil {
+				lggr.Error(err, "parsing target port, forwarding to the service", "target_url", targetURL)
+			} else if addr, done, ok := balancer.pick(r.Context(), routingTarget.Namespace, routingTarget.Service, int32(port)); ok {
+				defer done()
+				podURL := *targetURL
+				podURL.Host = addr
+				targetURL = &podURL
+			}
+		}
 		isColdStart := "false"
 		if replicas == 0 {
 			isColdStart = "true"
 		}
+		if fwdPath := routingTarget.ForwardPath(r.URL.Path); fwdPath != r.URL.Path {
+			r.URL.Path = fwdPath
+			r.URL.RawPath = ""
+		}
 		w.Header().Add("X-KEDA-HTTP-Cold-Start", isColdStart)
-		lggr.Info("dispatching request.", "host", host, "target_url", targetURL, "isColdStart", isColdStart)
-		forwardRequest(lggr, w, r, roundTripper, targetURL, fwdCfg.serviceUnavailableRetry)
+		requestLogger(r.Context(), lggr).Info("dispatching request.", "host", host, "target_url", targetURL, "isColdStart", isColdStart)
+		retries := fwdCfg.retries.withTarget(lggr, routingTarget.RetryPolicy)
+		forwardRequest(lggr, w, r, roundTripper, targetURL, retries)
 	})
 }
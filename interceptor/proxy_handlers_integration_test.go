@@ -309,6 +309,9 @@ func newHarness(
 	proxyHdl := newForwardingHandler(
 		lggr,
 		routingTable,
+		hostHeaderResolver{},
+		nil,
+		nil,
 		dialContextFunc,
 		waitFunc,
 		func(routing.Target) (*url.URL, error) {
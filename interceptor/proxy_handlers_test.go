@@ -44,12 +44,15 @@ func TestImmediatelySuccessfulProxy(t *testing.T) {
 
 	timeouts := defaultTimeouts()
 	dialCtxFunc := retryDialContextFunc(timeouts, timeouts.DefaultBackoff())
-	waitFunc := func(context.Context, string, string) (int, error) {
+	waitFunc := func(context.Context, routing.Target) (int, error) {
 		return 1, nil
 	}
 	hdl := newForwardingHandler(
 		logr.Discard(),
 		routingTable,
+		hostHeaderResolver{},
+		nil,
+		nil,
 		dialCtxFunc,
 		waitFunc,
 		func(routing.Target) (*url.URL, error) {
@@ -85,7 +88,7 @@ func TestWaitFailedConnection(t *testing.T) {
 		timeouts,
 		backoff,
 	)
-	waitFunc := func(context.Context, string, string) (int, error) {
+	waitFunc := func(context.Context, routing.Target) (int, error) {
 		return 1, nil
 	}
 	routingTable := routing.NewTable()
@@ -100,6 +103,9 @@ func TestWaitFailedConnection(t *testing.T) {
 	hdl := newForwardingHandler(
 		logr.Discard(),
 		routingTable,
+		hostHeaderResolver{},
+		nil,
+		nil,
 		dialCtxFunc,
 		waitFunc,
 		func(routing.Target) (*url.URL, error) {
@@ -146,6 +152,9 @@ func TestTimesOutOnWaitFunc(t *testing.T) {
 	hdl := newForwardingHandler(
 		logr.Discard(),
 		routingTable,
+		hostHeaderResolver{},
+		nil,
+		nil,
 		dialCtxFunc,
 		waitFunc,
 		func(routing.Target) (*url.URL, error) {
@@ -225,6 +234,9 @@ func TestWaitsForWaitFunc(t *testing.T) {
 	hdl := newForwardingHandler(
 		logr.Discard(),
 		routingTable,
+		hostHeaderResolver{},
+		nil,
+		nil,
 		dialCtxFunc,
 		waitFunc,
 		func(routing.Target) (*url.URL, error) {
@@ -285,7 +297,7 @@ func TestWaitHeaderTimeout(t *testing.T) {
 
 	timeouts := defaultTimeouts()
 	dialCtxFunc := retryDialContextFunc(timeouts, timeouts.DefaultBackoff())
-	waitFunc := func(context.Context, string, string) (int, error) {
+	waitFunc := func(context.Context, routing.Target) (int, error) {
 		return 1, nil
 	}
 	routingTable := routing.NewTable()
@@ -300,6 +312,9 @@ func TestWaitHeaderTimeout(t *testing.T) {
 	hdl := newForwardingHandler(
 		logr.Discard(),
 		routingTable,
+		hostHeaderResolver{},
+		nil,
+		nil,
 		dialCtxFunc,
 		waitFunc,
 		func(routing.Target) (*url.URL, error) {
@@ -361,7 +376,7 @@ func notifyingFunc() (forwardWaitFunc, <-chan struct{}, func()) {
 	finishFunc := func() {
 		close(finishCh)
 	}
-	return func(ctx context.Context, _, _ string) (int, error) {
+	return func(ctx context.Context, _ routing.Target) (int, error) {
 		close(calledCh)
 		select {
 		case <-finishCh:
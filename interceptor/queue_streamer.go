@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/kedacore/http-add-on/pkg/queue"
+	externalscaler "github.com/kedacore/http-add-on/proto"
+)
+
+// runQueueStreamer streams the counts of q to the scaler at addr until
+// ctx is done. Failed streams are reopened after retryInterval, each
+// starting with all counts of q. The scaler polls the counts either
+// way, so the counts are never lost while there is no stream
+func runQueueStreamer(
+	ctx context.Context,
+	lggr logr.Logger,
+	addr,
+	interceptor string,
+	q queue.ChangeNotifier,
+	retryInterval time.Duration,
+) error {
+	lggr = lggr.WithName("runQueueStreamer")
+	conn, err := grpc.DialContext(
+		ctx,
+		addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	client := externalscaler.NewQueueCountsClient(conn)
+	for {
+		err := queue.StreamCounts(ctx, lggr, client, interceptor, q)
+		if err != nil {
+			lggr.Error(err, "streaming queue counts to the scaler", "address", addr)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryInterval):
+		}
+	}
+}
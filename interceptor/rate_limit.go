@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/time/rate"
+
+	"github.com/kedacore/http-add-on/pkg/k8s"
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+// rateLimiters holds a token bucket for each routing table key
+// that has a rate limit. The limits are divided by the number of
+// interceptor replicas that replicas returns
+type rateLimiters struct {
+	l        sync.Mutex
+	m        map[string]*rate.Limiter
+	replicas func() int
+}
+
+func newRateLimiters(replicas func() int) *rateLimiters {
+	return &rateLimiters{
+		m:        map[string]*rate.Limiter{},
+		replicas: replicas,
+	}
+}
+
+// reserve takes a token for a request to the target stored under key,
+// which is limited by limit. It returns how long the request has to
+// wait for a token, or zero if it may be forwarded now
+func (r *rateLimiters) reserve(key string, limit routing.RateLimit) time.Duration {
+	replicas := 1
+	if r.replicas != nil {
+		replicas = r.replicas()
+	}
+	rps := rate.Limit(float64(limit.RequestsPerSecond) / float64(replicas))
+	burst := int(limit.Burst) / replicas
+	if burst < 1 {
+		burst = 1
+	}
+
+	r.l.Lock()
+	lim, ok := r.m[key]
+	if !ok {
+		lim = rate.NewLimiter(rps, burst)
+		r.m[key] = lim
+	}
+	r.l.Unlock()
+	// the limit of a target can change with the routing table
+	// or with the number of interceptor replicas
+	if lim.Limit() != rps {
+		lim.SetLimit(rps)
+	}
+	if lim.Burst() != burst {
+		lim.SetBurst(burst)
+	}
+
+	res := lim.Reserve()
+	if !res.OK() {
+		return time.Duration(float64(time.Second) / float64(rps))
+	}
+	delay := res.Delay()
+	if delay > 0 {
+		// the request is rejected rather than delayed, so
+		// it should not hold on to the token
+		res.Cancel()
+	}
+	return delay
+}
+
+// prune forgets the limiters of the routing table keys
+// that keep returns false for, e.g. once they are removed
+func (r *rateLimiters) prune(keep func(key string) bool) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	for key := range r.m {
+		if !keep(key) {
+			delete(r.m, key)
+		}
+	}
+}
+
+// rateLimitMiddleware rejects requests to targets with a RateLimit
+// with 429 Too Many Requests while the target is over its limit.
+// Other requests, including the ones that don't match a target, are
+// passed on to next, with their host and route in the request context
+func rateLimitMiddleware(
+	lggr logr.Logger,
+	routingTable *routing.Table,
+	hostResolver HostResolver,
+	limiters *rateLimiters,
+	next http.Handler,
+) http.Handler {
+	lggr = lggr.WithName("rateLimitMiddleware")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, callerNs, err := resolveHost(hostResolver, r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r = r.WithContext(withHost(r.Context(), host, callerNs))
+		key, target, err := routeRequest(routingTable, host, r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r = r.WithContext(withRoute(r.Context(), key, target))
+		// rejected requests are reported under their route too
+		requestStatsFrom(r.Context()).routed(key, target.Namespace)
+		if target.RateLimit == nil || target.RateLimit.RequestsPerSecond <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if wait := limiters.reserve(key, *target.RateLimit); wait > 0 {
+			lggr.Info("rejecting request over rate limit", "host", key, "retryAfter", wait)
+			w.Header().Set("Retry-After", retryAfterHeader(wait))
+			w.WriteHeader(http.StatusTooManyRequests)
+			if _, err := w.Write([]byte("Rate limit exceeded, try again later")); err != nil {
+				lggr.Error(err, "could not write error message to client")
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// interceptorReplicas keeps count of the endpoints of
+// the Service of the interceptors
+type interceptorReplicas struct {
+	n atomic.Int32
+}
+
+// count returns the last known number of interceptor
+// replicas, and never less than 1
+func (i *interceptorReplicas) count() int {
+	if n := int(i.n.Load()); n > 1 {
+		return n
+	}
+	return 1
+}
+
+// update counts the endpoints of svcName in ns
+func (i *interceptorReplicas) update(
+	ctx context.Context,
+	endpointsFn k8s.GetEndpointsFunc,
+	ns,
+	svcName string,
+	port int,
+) error {
+	endpoints, err := k8s.EndpointsForService(
+		ctx,
+		ns,
+		svcName,
+		strconv.Itoa(port),
+		endpointsFn,
+	)
+	if err != nil {
+		return err
+	}
+	i.n.Store(int32(len(endpoints)))
+	return nil
+}
+
+// run updates the count every interval until ctx is done.
+// Failed updates keep the last known count
+func (i *interceptorReplicas) run(
+	ctx context.Context,
+	lggr logr.Logger,
+	endpointsFn k8s.GetEndpointsFunc,
+	ns,
+	svcName string,
+	port int,
+	interval time.Duration,
+) error {
+	lggr = lggr.WithName("interceptorReplicas.run")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := i.update(ctx, endpointsFn, ns, svcName, port); err != nil {
+			lggr.Error(err, "counting interceptor endpoints", "service", svcName)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
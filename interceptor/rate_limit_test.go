@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+func TestRateLimitersReserve(t *testing.T) {
+	r := require.New(t)
+	limit := routing.RateLimit{RequestsPerSecond: 1, Burst: 2}
+
+	limiters := newRateLimiters(nil)
+	r.Zero(limiters.reserve("a.com", limit))
+	r.Zero(limiters.reserve("a.com", limit))
+	r.Positive(limiters.reserve("a.com", limit), "burst used up")
+	// rejected requests don't hold on to tokens, so the
+	// wait doesn't grow with every rejected request
+	r.LessOrEqual(limiters.reserve("a.com", limit).Seconds(), 1.0)
+	// every key has its own bucket
+	r.Zero(limiters.reserve("b.com", limit))
+
+	// the burst is divided between the replicas
+	limiters = newRateLimiters(func() int { return 2 })
+	r.Zero(limiters.reserve("a.com", limit))
+	r.Positive(limiters.reserve("a.com", limit), "burst used up")
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	const (
+		limitedHost   = "limited.com"
+		unlimitedHost = "unlimited.com"
+	)
+	r := require.New(t)
+	routingTable := routing.NewTable()
+	limitedTgt := routing.NewTarget("testns", "limited", 8080, "limited", 100)
+	limitedTgt.RateLimit = &routing.RateLimit{RequestsPerSecond: 1, Burst: 1}
+	r.NoError(routingTable.AddTarget(limitedHost, limitedTgt))
+	r.NoError(routingTable.AddTarget(
+		unlimitedHost,
+		routing.NewTarget("testns", "unlimited", 8080, "unlimited", 100),
+	))
+	hdl := rateLimitMiddleware(
+		logr.Discard(),
+		routingTable,
+		hostHeaderResolver{},
+		newRateLimiters(nil),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+		}),
+	)
+
+	testCases := []struct {
+		host       string
+		code       int
+		retryAfter string
+	}{
+		{limitedHost, 200, ""},
+		{limitedHost, 429, "1"},
+		{unlimitedHost, 200, ""},
+		{unlimitedHost, 200, ""},
+		// requests to unknown hosts are left to the next handler
+		{"unknown.com", 200, ""},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = tc.host
+		rec := httptest.NewRecorder()
+		hdl.ServeHTTP(rec, req)
+		r.Equal(tc.code, rec.Code, "%+v", tc)
+		r.Equal(tc.retryAfter, rec.Header().Get("Retry-After"), "%+v", tc)
+	}
+}
+
+func TestInterceptorReplicas(t *testing.T) {
+	ctx := context.Background()
+	r := require.New(t)
+	replicas := new(interceptorReplicas)
+	r.Equal(1, replicas.count(), "count before the first update")
+
+	endpoints := &v1.Endpoints{
+		Subsets: []v1.EndpointSubset{
+			{
+				Addresses: []v1.EndpointAddress{
+					{IP: "1.2.3.4"},
+					{IP: "2.3.4.5"},
+					{IP: "3.4.5.6"},
+				},
+			},
+		},
+	}
+	endpointsFn := func(context.Context, string, string) (*v1.Endpoints, error) {
+		return endpoints, nil
+	}
+	r.NoError(replicas.update(ctx, endpointsFn, "keda", "interceptor", 8080))
+	r.Equal(3, replicas.count())
+
+	// failed updates keep the last count
+	failingFn := func(context.Context, string, string) (*v1.Endpoints, error) {
+		return nil, errors.New("test error")
+	}
+	r.Error(replicas.update(ctx, failingFn, "keda", "interceptor", 8080))
+	r.Equal(3, replicas.count())
+
+	// no endpoints still counts this replica
+	endpoints.Subsets = nil
+	r.NoError(replicas.update(ctx, endpointsFn, "keda", "interceptor", 8080))
+	r.Equal(1, replicas.count())
+}
@@ -90,7 +90,7 @@ func TestForwarderSuccess(t *testing.T) {
 		req,
 		newRoundTripper(dialCtxFunc, timeouts.ResponseHeader),
 		forwardURL,
-		2,
+		retryPolicy{},
 	)
 
 	r.True(
@@ -139,7 +139,7 @@ func TestForwarderHeaderTimeout(t *testing.T) {
 		req,
 		newRoundTripper(dialCtxFunc, timeouts.ResponseHeader),
 		originURL,
-		2,
+		retryPolicy{},
 	)
 
 	forwardedRequests := hdl.IncomingRequests()
@@ -193,7 +193,7 @@ func TestForwarderWaitsForSlowOrigin(t *testing.T) {
 		req,
 		newRoundTripper(dialCtxFunc, timeouts.ResponseHeader),
 		originURL,
-		2,
+		retryPolicy{},
 	)
 	// wait for the goroutine above to finish, with a little cusion
 	ensureSignalBeforeTimeout(originWaitCh, originDelay*2)
@@ -222,7 +222,7 @@ func TestForwarderConnectionRetryAndTimeout(t *testing.T) {
 		req,
 		newRoundTripper(dialCtxFunc, timeouts.ResponseHeader),
 		noSuchURL,
-		2,
+		retryPolicy{},
 	)
 	elapsed := time.Since(start)
 	log.Printf("forwardRequest took %s", elapsed)
@@ -280,7 +280,7 @@ func TestForwardRequestRedirectAndHeaders(t *testing.T) {
 		req,
 		newRoundTripper(dialCtxFunc, timeouts.ResponseHeader),
 		srvURL,
-		2,
+		retryPolicy{},
 	)
 	r.Equal(301, res.Code)
 	r.Equal("abc123.com", res.Header().Get("Location"))
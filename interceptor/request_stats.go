@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// statsCtxKey is the context key under which
+// the requestStats of a request are stored
+type statsCtxKey struct{}
+
+// requestStats collects what the handlers of a request learn about it
+// while they serve it, so that the outermost handler can report on it
+// once it is done. They are only used by the goroutine that serves the
+// request. All methods are no-ops on a nil *requestStats, which is what
+// requestStatsFrom returns for requests that don't carry any
+type requestStats struct {
+	// key and namespace are the routing table key and the namespace
+	// of the Target that the request was routed to
+	key       string
+	namespace string
+	// waited is true once the request waited for its Target,
+	// and coldStart if the Target had no replicas then
+	waited    bool
+	coldStart bool
+	wait      time.Duration
+	// retries is the number of times that the request was retried
+	retries int
+	// upstream is how long forwarding the request to its
+	// backend took, retries included, if it was forwarded
+	upstream time.Duration
+	// accessLogged is true if the request is written
+	// to the access log once it was served
+	accessLogged bool
+}
+
+// withRequestStats returns a copy of ctx that
+// carries new, empty requestStats
+func withRequestStats(ctx context.Context) (context.Context, *requestStats) {
+	stats := &requestStats{}
+	return context.WithValue(ctx, statsCtxKey{}, stats), stats
+}
+
+// requestStatsFrom returns the requestStats that ctx carries, or nil
+func requestStatsFrom(ctx context.Context) *requestStats {
+	stats, _ := ctx.Value(statsCtxKey{}).(*requestStats)
+	return stats
+}
+
+// requestLogger returns lggr for the lines that the proxy handlers log
+// for each request, or lggr.V(1) if the request that ctx belongs to is
+// access logged, since the access log already has a line for it
+func requestLogger(ctx context.Context, lggr logr.Logger) logr.Logger {
+	if stats := requestStatsFrom(ctx); stats != nil && stats.accessLogged {
+		return lggr.V(1)
+	}
+	return lggr
+}
+
+// routed records that the request was routed
+// to target under the routing table key key
+func (s *requestStats) routed(key, namespace string) {
+	if s == nil {
+		return
+	}
+	s.key = key
+	s.namespace = namespace
+}
+
+// waitedFor records that the request waited for d for its
+// Target, which had no replicas if coldStart is true
+func (s *requestStats) waitedFor(d time.Duration, coldStart bool) {
+	if s == nil {
+		return
+	}
+	s.waited = true
+	s.wait = d
+	s.coldStart = coldStart
+}
+
+// forwarded records that forwarding the request
+// to its backend took d, retries included
+func (s *requestStats) forwarded(d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.upstream = d
+}
+
+// retried records that the request was retried once more
+func (s *requestStats) retried() {
+	if s == nil {
+		return
+	}
+	s.retries++
+}
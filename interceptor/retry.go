@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+// upstreamConnectError is the start of the body of the 503 responses that
+// an Envoy sidecar returns when it could not reach the backend
+const upstreamConnectError = "upstream connect error or disconnect/reset before headers"
+
+// upstreamConnectErrorPattern matches the bodies that
+// start with upstreamConnectError
+var upstreamConnectErrorPattern = regexp.MustCompile("^" + regexp.QuoteMeta(upstreamConnectError))
+
+// retryPolicy is how retryRoundTripper retries requests
+type retryPolicy struct {
+	// maxRetries is the number of times a request is retried.
+	// Zero disables retries
+	maxRetries int
+	// backoff is the wait before each retry
+	backoff wait.Backoff
+	// maxBodyBytes is the largest request body that is buffered so
+	// that it can be replayed. Requests with larger bodies are not retried.
+	// It is also the most of a response body that bodyPatterns are
+	// matched against
+	maxBodyBytes int64
+	// retryNonIdempotent allows retrying requests with
+	// non-idempotent methods, such as POST
+	retryNonIdempotent bool
+	// statusCodes and bodyPatterns select the responses to retry.
+	// An empty list matches any status code or body, but at least
+	// one of them must be set for responses to be retried
+	statusCodes  []int
+	bodyPatterns []*regexp.Regexp
+	// responseHeaders are the names of the headers
+	// that mark a response to retry
+	responseHeaders []string
+	// connectionErrors allows retrying requests
+	// that failed to reach the backend
+	connectionErrors bool
+	// perTryTimeout, if not zero, is the timeout of each attempt
+	perTryTimeout time.Duration
+}
+
+// defaultRetryPolicy returns the retryPolicy that retries the 503
+// responses of an Envoy sidecar that could not reach the backend
+func defaultRetryPolicy(maxRetries int, backoff wait.Backoff, maxBodyBytes int64) retryPolicy {
+	return retryPolicy{
+		maxRetries:   maxRetries,
+		backoff:      backoff,
+		maxBodyBytes: maxBodyBytes,
+		statusCodes:  []int{http.StatusServiceUnavailable},
+		bodyPatterns: []*regexp.Regexp{upstreamConnectErrorPattern},
+	}
+}
+
+// retryPatterns caches the compiled body patterns of retry policies,
+// since the same patterns are used by every request to a target
+var retryPatterns sync.Map
+
+func compileRetryPattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := retryPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	retryPatterns.Store(pattern, re)
+	return re, nil
+}
+
+// withTarget returns p with the RetryPolicy of a routing target, if
+// any, applied. Body patterns that are not valid regular expressions
+// are logged and skipped, and so are body patterns without status
+// codes, since every response would be held back to match its body
+func (p retryPolicy) withTarget(lggr logr.Logger, tp *routing.RetryPolicy) retryPolicy {
+	if tp == nil {
+		return p
+	}
+	p.retryNonIdempotent = tp.RetryNonIdempotent
+	p.connectionErrors = tp.ConnectionErrors
+	p.responseHeaders = tp.ResponseHeaders
+	if tp.MaxAttempts > 0 {
+		p.maxRetries = tp.MaxAttempts - 1
+		p.backoff.Steps = p.maxRetries
+	}
+	if tp.PerTryTimeout > 0 {
+		p.perTryTimeout = tp.PerTryTimeout
+	}
+	if len(tp.StatusCodes) == 0 && len(tp.BodyPatterns) > 0 {
+		lggr.Error(
+			errors.New("retry body patterns require status codes"),
+			"skipping retry body patterns",
+			"patterns", tp.BodyPatterns,
+		)
+	} else if len(tp.StatusCodes) > 0 {
+		p.statusCodes = tp.StatusCodes
+		p.bodyPatterns = nil
+		for _, pattern := range tp.BodyPatterns {
+			re, err := compileRetryPattern(pattern)
+			if err != nil {
+				lggr.Error(err, "skipping invalid retry body pattern", "pattern", pattern)
+				continue
+			}
+			p.bodyPatterns = append(p.bodyPatterns, re)
+		}
+	}
+	return p
+}
+
+// retryResponse returns true if resp should be retried. When the body
+// of resp is matched against the body patterns, it is buffered up to
+// p.maxBodyBytes, so that resp can still be returned
+func (p retryPolicy) retryResponse(resp *http.Response) (bool, error) {
+	for _, name := range p.responseHeaders {
+		if resp.Header.Get(name) != "" {
+			return true, nil
+		}
+	}
+	if len(p.statusCodes) == 0 && len(p.bodyPatterns) == 0 {
+		return false, nil
+	}
+	if len(p.statusCodes) > 0 && !containsStatusCode(p.statusCodes, resp.StatusCode) {
+		return false, nil
+	}
+	if len(p.bodyPatterns) == 0 {
+		return true, nil
+	}
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, p.maxBodyBytes))
+	if err != nil {
+		return false, err
+	}
+	resp.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(buf), resp.Body),
+		Closer: resp.Body,
+	}
+	for _, re := range p.bodyPatterns {
+		if re.Match(buf) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func containsStatusCode(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retryError returns true if the request with context ctx should be
+// retried after an attempt failed with err. Attempts that hit the
+// per-try timeout are always retried
+func (p retryPolicy) retryError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return p.perTryTimeout > 0
+	}
+	return p.connectionErrors
+}
+
+// isIdempotent returns true if requests with method can be
+// safely sent more than once. See RFC 9110, section 9.2.2
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet,
+		http.MethodHead,
+		http.MethodOptions,
+		http.MethodTrace,
+		http.MethodPut,
+		http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryRoundTripper is an http.RoundTripper that retries requests
+// through next according to policy
+type retryRoundTripper struct {
+	lggr   logr.Logger
+	next   http.RoundTripper
+	policy retryPolicy
+}
+
+func newRetryRoundTripper(
+	lggr logr.Logger,
+	next http.RoundTripper,
+	policy retryPolicy,
+) *retryRoundTripper {
+	return &retryRoundTripper{
+		lggr:   lggr.WithName("retryRoundTripper"),
+		next:   next,
+		policy: policy,
+	}
+}
+
+// RoundTrip implements http.RoundTripper. A request is retried while
+// the policy says that its response or error should be, and only if
+// its method is idempotent or the policy allows non-idempotent retries.
+// The request body is buffered up to the maxBodyBytes of the policy so
+// that it can be sent again. Waits between retries end early when the
+// request context is done
+func (rt *retryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.policy.maxRetries <= 0 ||
+		(!isIdempotent(req.Method) && !rt.policy.retryNonIdempotent) {
+		return rt.attempt(req, nil)
+	}
+	body, replayable, err := bufferBody(req, rt.policy.maxBodyBytes)
+	if err != nil {
+		return nil, err
+	}
+	if !replayable {
+		rt.lggr.Info("request body too large to retry", "maxBodyBytes", rt.policy.maxBodyBytes)
+		return rt.attempt(req, nil)
+	}
+
+	// copy the backoff, since Step mutates it
+	backoff := rt.policy.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := rt.attempt(req, body)
+		if attempt >= rt.policy.maxRetries {
+			return resp, err
+		}
+		var retry bool
+		if err != nil {
+			retry = rt.policy.retryError(req.Context(), err)
+		} else if retry, err = rt.policy.retryResponse(resp); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if !retry {
+			return resp, err
+		}
+
+		delay := backoff.Step()
+		rt.lggr.Info("retrying request", "attempt", attempt+1, "wait", delay, "error", err)
+		t := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			t.Stop()
+			return resp, err
+		case <-t.C:
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		requestStatsFrom(req.Context()).retried()
+	}
+}
+
+// attempt sends req through rt.next once, with body as its body if
+// it is not nil, and within the per-try timeout of the policy
+func (rt *retryRoundTripper) attempt(req *http.Request, body []byte) (*http.Response, error) {
+	if body == nil && rt.policy.perTryTimeout <= 0 {
+		return rt.next.RoundTrip(req)
+	}
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if rt.policy.perTryTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, rt.policy.perTryTimeout)
+	}
+	attemptReq := req.Clone(ctx)
+	if body != nil {
+		attemptReq.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	resp, err := rt.next.RoundTrip(attemptReq)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the timeout covers reading the body too, so it
+	// is only released once the body is closed
+	respBody := resp.Body
+	resp.Body = readCloser{
+		Reader: respBody,
+		Closer: closerFunc(func() error {
+			defer cancel()
+			return respBody.Close()
+		}),
+	}
+	return resp, nil
+}
+
+// bufferBody reads the body of req, up to maxBytes, so that it can be
+// sent again. It returns false if the body is larger than maxBytes,
+// in which case req still has its whole body but it can't be replayed.
+// A nil body is returned for requests without one
+func bufferBody(req *http.Request, maxBytes int64) ([]byte, bool, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, true, nil
+	}
+	buf, err := io.ReadAll(io.LimitReader(req.Body, maxBytes+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("buffering request body: %w", err)
+	}
+	if int64(len(buf)) > maxBytes {
+		req.Body = readCloser{
+			Reader: io.MultiReader(bytes.NewReader(buf), req.Body),
+			Closer: req.Body,
+		}
+		return nil, false, nil
+	}
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(buf))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf)), nil
+	}
+	return buf, true, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+// flakyOrigin answers the first failures requests with the 503 that
+// Envoy returns for an unreachable backend, and 200 after that. It
+// records the body of every request that it receives
+type flakyOrigin struct {
+	l        sync.Mutex
+	failures int
+	bodies   []string
+}
+
+func (f *flakyOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.bodies = append(f.bodies, string(body))
+	if len(f.bodies) <= f.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(upstreamConnectError + ": connection refused"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("OK"))
+}
+
+func (f *flakyOrigin) received() []string {
+	f.l.Lock()
+	defer f.l.Unlock()
+	return append([]string(nil), f.bodies...)
+}
+
+func testRetryPolicy() retryPolicy {
+	return defaultRetryPolicy(
+		2,
+		wait.Backoff{Duration: time.Millisecond, Factor: 2, Steps: 2},
+		64,
+	)
+}
+
+func TestRetryRoundTripper(t *testing.T) {
+	testCases := []struct {
+		name     string
+		method   string
+		body     string
+		policy   func(retryPolicy) retryPolicy
+		failures int
+		code     int
+		bodies   []string
+	}{
+		{
+			name:     "idempotent request is retried",
+			method:   "GET",
+			failures: 1,
+			code:     200,
+			bodies:   []string{"", ""},
+		},
+		{
+			name:     "request body is replayed",
+			method:   "PUT",
+			body:     "payload",
+			failures: 2,
+			code:     200,
+			bodies:   []string{"payload", "payload", "payload"},
+		},
+		{
+			name:     "retries run out",
+			method:   "GET",
+			failures: 5,
+			code:     503,
+			bodies:   []string{"", "", ""},
+		},
+		{
+			name:     "non-idempotent request is not retried",
+			method:   "POST",
+			body:     "payload",
+			failures: 1,
+			code:     503,
+			bodies:   []string{"payload"},
+		},
+		{
+			name:   "non-idempotent request is retried when allowed",
+			method: "POST",
+			body:   "payload",
+			policy: func(p retryPolicy) retryPolicy {
+				p.retryNonIdempotent = true
+				return p
+			},
+			failures: 1,
+			code:     200,
+			bodies:   []string{"payload", "payload"},
+		},
+		{
+			name:     "request body over the limit is not retried",
+			method:   "PUT",
+			body:     strings.Repeat("a", 65),
+			failures: 1,
+			code:     503,
+			bodies:   []string{strings.Repeat("a", 65)},
+		},
+		{
+			name:   "retries disabled",
+			method: "GET",
+			policy: func(p retryPolicy) retryPolicy {
+				p.maxRetries = 0
+				return p
+			},
+			failures: 1,
+			code:     503,
+			bodies:   []string{""},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+			origin := &flakyOrigin{failures: tc.failures}
+			srv := httptest.NewServer(origin)
+			defer srv.Close()
+
+			policy := testRetryPolicy()
+			if tc.policy != nil {
+				policy = tc.policy(policy)
+			}
+			rt := newRetryRoundTripper(logr.Discard(), http.DefaultTransport, policy)
+			req, err := http.NewRequest(tc.method, srv.URL, strings.NewReader(tc.body))
+			r.NoError(err)
+			resp, err := rt.RoundTrip(req)
+			r.NoError(err)
+			defer resp.Body.Close()
+
+			r.Equal(tc.code, resp.StatusCode)
+			r.Equal(tc.bodies, origin.received())
+			if tc.code == 503 {
+				// the body of the last response is kept for the client
+				body, err := io.ReadAll(resp.Body)
+				r.NoError(err)
+				r.True(strings.HasPrefix(string(body), upstreamConnectError))
+			}
+		})
+	}
+}
+
+func TestRetryRoundTripperContextDone(t *testing.T) {
+	r := require.New(t)
+	origin := &flakyOrigin{failures: 5}
+	srv := httptest.NewServer(origin)
+	defer srv.Close()
+
+	policy := testRetryPolicy()
+	policy.backoff.Duration = time.Minute
+	rt := newRetryRoundTripper(logr.Discard(), http.DefaultTransport, policy)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	r.NoError(err)
+
+	start := time.Now()
+	resp, err := rt.RoundTrip(req)
+	r.NoError(err)
+	defer resp.Body.Close()
+	r.Less(time.Since(start), 10*time.Second, "the backoff did not stop with the context")
+	r.Equal(503, resp.StatusCode)
+	r.Len(origin.received(), 1)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRetryPolicyWithTarget(t *testing.T) {
+	r := require.New(t)
+	base := testRetryPolicy()
+
+	r.Equal(base, base.withTarget(logr.Discard(), nil))
+
+	// without status codes and body patterns, the
+	// default triggers are kept
+	p := base.withTarget(logr.Discard(), &routing.RetryPolicy{
+		ResponseHeaders: []string{"x-envoy-overloaded"},
+		MaxAttempts:     5,
+		PerTryTimeout:   time.Second,
+	})
+	r.Equal(base.statusCodes, p.statusCodes)
+	r.Equal(base.bodyPatterns, p.bodyPatterns)
+	r.Equal([]string{"x-envoy-overloaded"}, p.responseHeaders)
+	r.Equal(4, p.maxRetries)
+	r.Equal(4, p.backoff.Steps)
+	r.Equal(time.Second, p.perTryTimeout)
+
+	// invalid body patterns are skipped
+	p = base.withTarget(logr.Discard(), &routing.RetryPolicy{
+		StatusCodes:  []int{502, 504},
+		BodyPatterns: []string{"(", "timeout"},
+	})
+	r.Equal([]int{502, 504}, p.statusCodes)
+	r.Len(p.bodyPatterns, 1)
+	r.Equal("timeout", p.bodyPatterns[0].String())
+}
+
+func TestRetryRoundTripperTriggers(t *testing.T) {
+	testCases := []struct {
+		name     string
+		target   routing.RetryPolicy
+		header   http.Header
+		code     int
+		body     string
+		attempts int
+	}{
+		{
+			name:     "response header",
+			target:   routing.RetryPolicy{ResponseHeaders: []string{"X-Envoy-Overloaded"}},
+			header:   http.Header{"X-Envoy-Overloaded": {"true"}},
+			code:     200,
+			attempts: 3,
+		},
+		{
+			name:     "status code with any body",
+			target:   routing.RetryPolicy{StatusCodes: []int{502}},
+			code:     502,
+			body:     "bad gateway",
+			attempts: 3,
+		},
+		{
+			name:     "status code not in the list",
+			target:   routing.RetryPolicy{StatusCodes: []int{502}},
+			code:     503,
+			body:     upstreamConnectError,
+			attempts: 1,
+		},
+		{
+			name:     "status code and body pattern",
+			target:   routing.RetryPolicy{StatusCodes: []int{500}, BodyPatterns: []string{"(?i)overloaded"}},
+			code:     500,
+			body:     "Backend Overloaded",
+			attempts: 3,
+		},
+		{
+			name:     "body pattern not matching",
+			target:   routing.RetryPolicy{StatusCodes: []int{500}, BodyPatterns: []string{"(?i)overloaded"}},
+			code:     500,
+			body:     "internal error",
+			attempts: 1,
+		},
+		{
+			name:     "body pattern without status codes",
+			target:   routing.RetryPolicy{BodyPatterns: []string{"(?i)overloaded"}},
+			code:     500,
+			body:     "Backend Overloaded",
+			attempts: 1,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+			attempts := 0
+			next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				attempts++
+				return &http.Response{
+					StatusCode: tc.code,
+					Header:     tc.header,
+					Body:       io.NopCloser(strings.NewReader(tc.body)),
+				}, nil
+			})
+			policy := testRetryPolicy().withTarget(logr.Discard(), &tc.target)
+			rt := newRetryRoundTripper(logr.Discard(), next, policy)
+			req, err := http.NewRequest("GET", "http://testsvc.testns:8080", nil)
+			r.NoError(err)
+			resp, err := rt.RoundTrip(req)
+			r.NoError(err)
+			defer resp.Body.Close()
+
+			r.Equal(tc.attempts, attempts)
+			r.Equal(tc.code, resp.StatusCode)
+			// matching the body doesn't consume it
+			body, err := io.ReadAll(resp.Body)
+			r.NoError(err)
+			r.Equal(tc.body, string(body))
+		})
+	}
+}
+
+func TestRetryRoundTripperErrors(t *testing.T) {
+	r := require.New(t)
+	attempts := 0
+	connErr := errors.New("connection refused")
+	failing := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		return nil, connErr
+	})
+
+	// connection errors are only retried when enabled
+	rt := newRetryRoundTripper(logr.Discard(), failing, testRetryPolicy())
+	req, err := http.NewRequest("GET", "http://testsvc.testns:8080", nil)
+	r.NoError(err)
+	_, err = rt.RoundTrip(req)
+	r.ErrorIs(err, connErr)
+	r.Equal(1, attempts)
+
+	attempts = 0
+	policy := testRetryPolicy().withTarget(logr.Discard(), &routing.RetryPolicy{
+		ConnectionErrors: true,
+	})
+	rt = newRetryRoundTripper(logr.Discard(), failing, policy)
+	_, err = rt.RoundTrip(req)
+	r.ErrorIs(err, connErr)
+	r.Equal(3, attempts)
+
+	// attempts that time out are retried
+	attempts = 0
+	slow := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		if attempts == 1 {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader("OK")),
+		}, nil
+	})
+	policy = testRetryPolicy().withTarget(logr.Discard(), &routing.RetryPolicy{
+		PerTryTimeout: 10 * time.Millisecond,
+	})
+	rt = newRetryRoundTripper(logr.Discard(), slow, policy)
+	resp, err := rt.RoundTrip(req)
+	r.NoError(err)
+	defer resp.Body.Close()
+	r.Equal(200, resp.StatusCode)
+	r.Equal(2, attempts)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+// routeCtxKey is the context key under which the routing table
+// match of a request is stored
+type routeCtxKey struct{}
+
+// route is a routing table match: the key a Target was found
+// under and the Target itself
+type route struct {
+	key    string
+	target *routing.Target
+}
+
+// withRoute returns a copy of ctx that carries the given match
+func withRoute(ctx context.Context, key string, target *routing.Target) context.Context {
+	return context.WithValue(ctx, routeCtxKey{}, route{key: key, target: target})
+}
+
+// routeRequest returns the routing table match of r for host. If
+// r has already been routed, e.g. by countMiddleware, the earlier
+// match is returned, so that every handler of a request agrees on
+// its Target even when the Target is picked by weight
+func routeRequest(
+	routingTable *routing.Table,
+	host string,
+	r *http.Request,
+) (string, *routing.Target, error) {
+	if rt, ok := r.Context().Value(routeCtxKey{}).(route); ok {
+		return rt.key, rt.target, nil
+	}
+	return routingTable.Route(host, r.URL.Path, r.Header)
+}
+
+// routeKey returns the key of the routing table match that
+// ctx carries, and false if it carries none
+func routeKey(ctx context.Context) (string, bool) {
+	rt, ok := ctx.Value(routeCtxKey{}).(route)
+	return rt.key, ok
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+func TestRouteRequest(t *testing.T) {
+	const host = "testhost"
+	r := require.New(t)
+	table := routing.NewTable()
+	target := routing.NewTarget("testns", "testsvc", 8080, "testdepl", 100)
+	r.NoError(table.AddTarget(host, target))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	key, routed, err := routeRequest(table, host, req)
+	r.NoError(err)
+	r.Equal(host, key)
+	r.Equal(target, *routed)
+
+	// an earlier match takes precedence over the routing table
+	canary := routing.NewTarget("testns", "testsvc-canary", 8080, "testdepl-canary", 100)
+	canaryKey := routing.BackendKey(host, "canary")
+	req = req.WithContext(withRoute(req.Context(), canaryKey, &canary))
+	key, routed, err = routeRequest(table, host, req)
+	r.NoError(err)
+	r.Equal(canaryKey, key)
+	r.Equal(canary, *routed)
+
+	_, _, err = routeRequest(table, "otherhost", httptest.NewRequest("GET", "/", nil))
+	r.ErrorIs(err, routing.ErrTargetNotFound)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/sync/singleflight"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/scale"
+
+	"github.com/kedacore/http-add-on/pkg/k8s"
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+const (
+	deploymentAPIVersion = "apps/v1"
+	deploymentKind       = "Deployment"
+)
+
+// targetWorkload returns the API version, kind and name of
+// the workload that serves target
+func targetWorkload(target routing.Target) (string, string, string) {
+	if target.Kind == "" {
+		return deploymentAPIVersion, deploymentKind, target.Deployment
+	}
+	return target.APIVersion, target.Kind, target.Deployment
+}
+
+// targetReplicasFunc returns the number of desired replicas
+// of the workload that serves a target
+type targetReplicasFunc func(context.Context, routing.Target) (int32, error)
+
+// newCachedTargetReplicasFunc returns a targetReplicasFunc that reads
+// the replicas of Deployments from deployCache, and the replicas of
+// other workloads from scaleTargetCache
+func newCachedTargetReplicasFunc(
+	deployCache k8s.DeploymentCache,
+	scaleTargetCache k8s.ScaleTargetCache,
+) targetReplicasFunc {
+	return func(ctx context.Context, target routing.Target) (int32, error) {
+		if target.Kind == "" {
+			depl, err := deployCache.Get(target.Namespace, target.Deployment)
+			if err != nil {
+				return 0, err
+			}
+			// Deployments without replicas have the
+			// single replica that it defaults to
+			if depl.Spec.Replicas == nil {
+				return 1, nil
+			}
+			return *depl.Spec.Replicas, nil
+		}
+		apiVersion, kind, name := targetWorkload(target)
+		return scaleTargetCache.Replicas(ctx, apiVersion, kind, target.Namespace, name)
+	}
+}
+
+// scaleTargetFunc scales the workload that serves a target from zero
+// to one replica. It returns false if the workload already had
+// replicas, or was scaled by someone else first
+type scaleTargetFunc func(context.Context, routing.Target) (bool, error)
+
+// newScaleSubresourceScaleTargetFunc returns a scaleTargetFunc that
+// scales workloads through their scale subresource
+func newScaleSubresourceScaleTargetFunc(
+	scales scale.ScalesGetter,
+	mapper meta.RESTMapper,
+) scaleTargetFunc {
+	return func(ctx context.Context, target routing.Target) (bool, error) {
+		apiVersion, kind, name := targetWorkload(target)
+		return k8s.ScaleFromZero(ctx, scales, mapper, apiVersion, kind, target.Namespace, name)
+	}
+}
+
+// scaleFromZero scales the workloads without replicas to one replica as
+// soon as a request for them comes in, rather than when KEDA next polls
+// the external scaler. Concurrent requests for a workload share a single
+// scale up, and scale ups by other interceptor replicas are detected by
+// scaleFunc
+type scaleFromZero struct {
+	lggr         logr.Logger
+	replicasFunc targetReplicasFunc
+	scaleFunc    scaleTargetFunc
+	timeout      time.Duration
+	group        singleflight.Group
+}
+
+func newScaleFromZero(
+	lggr logr.Logger,
+	replicasFunc targetReplicasFunc,
+	scaleFunc scaleTargetFunc,
+	timeout time.Duration,
+) *scaleFromZero {
+	return &scaleFromZero{
+		lggr:         lggr.WithName("scaleFromZero"),
+		replicasFunc: replicasFunc,
+		scaleFunc:    scaleFunc,
+		timeout:      timeout,
+	}
+}
+
+// trigger starts scaling the workload of target to one replica in the
+// background if it has no replicas. It returns right away, so that the
+// request can wait for the workload the same way it otherwise would
+func (s *scaleFromZero) trigger(ctx context.Context, target routing.Target) {
+	replicas, err := s.replicasFunc(ctx, target)
+	if err != nil {
+		s.lggr.Error(err, "getting replicas", "namespace", target.Namespace, "name", target.Deployment)
+		return
+	}
+	if replicas > 0 {
+		return
+	}
+	apiVersion, kind, name := targetWorkload(target)
+	key := apiVersion + "/" + kind + "/" + target.Namespace + "/" + name
+	// the scale up must outlive the request that triggered it,
+	// hence the background context
+	s.group.DoChan(key, func() (interface{}, error) {
+		scaleCtx, done := context.WithTimeout(context.Background(), s.timeout)
+		defer done()
+		scaled, err := s.scaleFunc(scaleCtx, target)
+		if err != nil {
+			s.lggr.Error(err, "scaling from zero", "kind", kind, "namespace", target.Namespace, "name", name)
+		} else if scaled {
+			s.lggr.Info("scaled from zero", "kind", kind, "namespace", target.Namespace, "name", name)
+		}
+		return scaled, err
+	})
+}
+
+// newScaleFromZeroForwardWaitFunc returns a forwardWaitFunc that
+// triggers s for the target before it waits with waitFunc
+func newScaleFromZeroForwardWaitFunc(
+	s *scaleFromZero,
+	waitFunc forwardWaitFunc,
+) forwardWaitFunc {
+	return func(ctx context.Context, target routing.Target) (int, error) {
+		s.trigger(ctx, target)
+		return waitFunc(ctx, target)
+	}
+}
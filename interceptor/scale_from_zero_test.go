@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	"github.com/kedacore/http-add-on/pkg/k8s"
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+func TestCachedTargetReplicasFunc(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	deployCache := k8s.NewFakeDeploymentCache()
+	deployCache.Set("testns", "testdepl", appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "testdepl", Namespace: "testns"},
+		Spec:       appsv1.DeploymentSpec{Replicas: pointer.Int32(0)},
+	})
+	scaleTargetCache := k8s.NewFakeScaleTargetCache()
+	scaleTargetCache.SetReplicas("apps/v1", "StatefulSet", "testns", "teststs", 3)
+	replicasFunc := newCachedTargetReplicasFunc(deployCache, scaleTargetCache)
+
+	replicas, err := replicasFunc(ctx, routing.NewTarget("testns", "testsvc", 8080, "testdepl", 123))
+	r.NoError(err)
+	r.EqualValues(0, replicas)
+
+	stsTarget := routing.NewTarget("testns", "testsvc", 8080, "teststs", 123)
+	stsTarget.APIVersion = "apps/v1"
+	stsTarget.Kind = "StatefulSet"
+	replicas, err = replicasFunc(ctx, stsTarget)
+	r.NoError(err)
+	r.EqualValues(3, replicas)
+
+	_, err = replicasFunc(ctx, routing.NewTarget("testns", "testsvc", 8080, "nosuchdepl", 123))
+	r.Error(err)
+}
+
+func TestScaleFromZero(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	var replicas atomic.Int32
+	var scaleCalls atomic.Int32
+	scaledCh := make(chan routing.Target, 10)
+	releaseCh := make(chan struct{})
+	s := newScaleFromZero(
+		logr.Discard(),
+		func(context.Context, routing.Target) (int32, error) {
+			return replicas.Load(), nil
+		},
+		func(_ context.Context, target routing.Target) (bool, error) {
+			scaleCalls.Add(1)
+			<-releaseCh
+			scaledCh <- target
+			return true, nil
+		},
+		time.Second,
+	)
+
+	// a workload with replicas is not scaled
+	replicas.Store(1)
+	s.trigger(ctx, routing.NewTarget("testns", "testsvc", 8080, "testdepl", 123))
+	r.EqualValues(0, scaleCalls.Load())
+
+	// concurrent requests for a workload without
+	// replicas share a single scale up
+	replicas.Store(0)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.trigger(ctx, routing.NewTarget("testns", "testsvc", 8080, "testdepl", 123))
+		}()
+	}
+	wg.Wait()
+	close(releaseCh)
+	select {
+	case target := <-scaledCh:
+		r.Equal("testdepl", target.Deployment)
+	case <-time.After(5 * time.Second):
+		r.Fail("workload was not scaled")
+	}
+	r.EqualValues(1, scaleCalls.Load())
+}
+
+func TestScaleFromZeroForwardWaitFunc(t *testing.T) {
+	r := require.New(t)
+	scaledCh := make(chan struct{})
+	s := newScaleFromZero(
+		logr.Discard(),
+		func(context.Context, routing.Target) (int32, error) {
+			return 0, nil
+		},
+		func(context.Context, routing.Target) (bool, error) {
+			close(scaledCh)
+			return true, nil
+		},
+		time.Second,
+	)
+	// the wait starts without waiting for the scale up
+	waitFunc := newScaleFromZeroForwardWaitFunc(s, func(ctx context.Context, _ routing.Target) (int, error) {
+		select {
+		case <-scaledCh:
+			return 0, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	})
+
+	ctx, done := context.WithTimeout(context.Background(), 5*time.Second)
+	defer done()
+	replicas, err := waitFunc(ctx, routing.NewTarget("testns", "testsvc", 8080, "testdepl", 123))
+	r.NoError(err)
+	r.Equal(0, replicas)
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// hijacking should fail if the wrapped
+// http.ResponseWriter can't be hijacked
+func TestStatusRecorderHijackUnsupported(t *testing.T) {
+	r := require.New(t)
+	var rec http.ResponseWriter = newStatusRecorder(httptest.NewRecorder())
+	hj, ok := rec.(http.Hijacker)
+	r.True(ok)
+	_, _, err := hj.Hijack()
+	r.Error(err)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kedacore/http-add-on/interceptor/config"
+)
+
+// tracerName is the name of the tracer of the proxy handlers
+const tracerName = "github.com/kedacore/http-add-on/interceptor"
+
+// newTracerProvider returns a TracerProvider that exports spans in
+// batches to the OTLP receiver at the TracingOTLPEndpoint of cfg. It
+// has to be shut down to flush the spans that are not exported yet
+func newTracerProvider(
+	ctx context.Context,
+	cfg *config.Serving,
+) (*sdktrace.TracerProvider, error) {
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(cfg.TracingOTLPEndpoint),
+	}
+	if cfg.TracingOTLPInsecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(cfg.TracingServiceName),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(
+			sdktrace.TraceIDRatioBased(cfg.TracingSampleRatio),
+		)),
+	), nil
+}
+
+// startSpan starts a span named name with the tracer of the
+// proxy handlers, from the global TracerProvider. Spans are
+// not recorded until tracing is set up, which it isn't if it
+// is disabled
+func startSpan(
+	ctx context.Context,
+	name string,
+	opts ...trace.SpanStartOption,
+) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// extractTraceContext returns a copy of ctx that carries
+// the trace context of the headers of r, if there is one
+func extractTraceContext(ctx context.Context, r *http.Request) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+}
+
+// injectTraceContext adds the trace context of ctx to header,
+// so that the backend that a request is forwarded to can
+// continue its trace
+func injectTraceContext(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// endSpanWithStatus records the status code of the response to
+// a request on span, marks span as failed if status is a server
+// error, and ends it
+func endSpanWithStatus(span trace.Span, status int) {
+	// handlers that write nothing respond with 200
+	if status == 0 {
+		status = http.StatusOK
+	}
+	span.SetAttributes(semconv.HTTPStatusCode(status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
+
+// routeAttributes returns the span attributes of the
+// routing table match of a request
+func routeAttributes(key, namespace string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("keda.http.route_key", key),
+		attribute.String("keda.http.target_namespace", namespace),
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	kedanet "github.com/kedacore/http-add-on/pkg/net"
+	"github.com/kedacore/http-add-on/pkg/queue"
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+// setupTestTracing makes the global TracerProvider export spans
+// to the returned exporter, and restores it when t is done
+func setupTestTracing(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prevTP := otel.GetTracerProvider()
+	prevPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevPropagator)
+		_ = tp.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+// spansByName returns the spans of exporter by their name
+func spansByName(exporter *tracetest.InMemoryExporter) map[string]tracetest.SpanStub {
+	ret := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		ret[span.Name] = span
+	}
+	return ret
+}
+
+func spanAttr(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+// the spans of a proxied request should form a single trace that
+// continues the trace of the caller, and the backend should receive
+// the trace context of the forwarding span
+func TestTracingProxiedRequest(t *testing.T) {
+	r := require.New(t)
+	exporter := setupTestTracing(t)
+	host := fmt.Sprintf("%s.testing", t.Name())
+
+	backendTraceparent := make(chan string, 1)
+	originHdl := kedanet.NewTestHTTPHandlerWrapper(
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			backendTraceparent <- req.Header.Get("traceparent")
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	srv, originURL, err := kedanet.StartTestServer(originHdl)
+	r.NoError(err)
+	defer srv.Close()
+	originPort, err := strconv.Atoi(originURL.Port())
+	r.NoError(err)
+	routingTable := routing.NewTable()
+	r.NoError(routingTable.AddTarget(host, targetFromURL(originURL, originPort, "testdepl")))
+
+	timeouts := defaultTimeouts()
+	dialCtxFunc := retryDialContextFunc(timeouts, timeouts.DefaultBackoff())
+	waitFunc := func(context.Context, routing.Target) (int, error) {
+		return 0, nil
+	}
+	q := queue.NewMemory(time.Second, false, time.Minute, logr.Discard())
+	hdl := countMiddleware(
+		logr.Discard(),
+		q,
+		routingTable,
+		hostHeaderResolver{},
+		pendingLimitConfig{},
+		newForwardingHandler(
+			logr.Discard(),
+			routingTable,
+			hostHeaderResolver{},
+			nil,
+			nil,
+			dialCtxFunc,
+			waitFunc,
+			func(routing.Target) (*url.URL, error) {
+				return originURL, nil
+			},
+			forwardingConfig{
+				waitTimeout:       timeouts.DeploymentReplicas,
+				respHeaderTimeout: timeouts.ResponseHeader,
+			},
+		),
+	)
+
+	const callerTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest("GET", "/testfwd", nil)
+	req.Host = host
+	req.Header.Set("traceparent", callerTraceparent)
+	res := httptest.NewRecorder()
+	hdl.ServeHTTP(res, req)
+	r.Equal(http.StatusOK, res.Code)
+
+	spans := spansByName(exporter)
+	r.Len(spans, 3)
+	count, ok := spans["interceptor.count"]
+	r.True(ok)
+	wait, ok := spans["interceptor.wait"]
+	r.True(ok)
+	fwd, ok := spans["interceptor.forward"]
+	r.True(ok)
+
+	r.Equal("4bf92f3577b34da6a3ce929d0e0e4736", count.SpanContext.TraceID().String())
+	r.Equal("00f067aa0ba902b7", count.Parent.SpanID().String())
+	r.Equal(trace.SpanKindServer, count.SpanKind)
+	r.Equal(count.SpanContext.SpanID(), wait.Parent.SpanID())
+	r.Equal(count.SpanContext.SpanID(), fwd.Parent.SpanID())
+	r.Equal(trace.SpanKindClient, fwd.SpanKind)
+	r.Equal(count.SpanContext.TraceID(), wait.SpanContext.TraceID())
+	r.Equal(count.SpanContext.TraceID(), fwd.SpanContext.TraceID())
+
+	key, ok := spanAttr(count, "keda.http.route_key")
+	r.True(ok)
+	r.Equal(host, key.AsString())
+	coldStart, ok := spanAttr(wait, "keda.http.cold_start")
+	r.True(ok)
+	r.True(coldStart.AsBool())
+	status, ok := spanAttr(fwd, "http.status_code")
+	r.True(ok)
+	r.Equal(int64(http.StatusOK), status.AsInt64())
+
+	r.Equal(
+		fmt.Sprintf(
+			"00-%s-%s-01",
+			fwd.SpanContext.TraceID(),
+			fwd.SpanContext.SpanID(),
+		),
+		<-backendTraceparent,
+	)
+}
+
+// a request for a host that isn't in the routing table
+// should still be traced, as a failed request
+func TestTracingUnroutedRequest(t *testing.T) {
+	r := require.New(t)
+	exporter := setupTestTracing(t)
+
+	q := queue.NewMemory(time.Second, false, time.Minute, logr.Discard())
+	hdl := countMiddleware(
+		logr.Discard(),
+		q,
+		routing.NewTable(),
+		hostHeaderResolver{},
+		pendingLimitConfig{},
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}),
+	)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "nosuchhost"
+	hdl.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	r.Len(spans, 1)
+	r.Equal("interceptor.count", spans[0].Name)
+	r.False(spans[0].Parent.IsValid())
+	r.Equal(codes.Error, spans[0].Status.Code)
+}
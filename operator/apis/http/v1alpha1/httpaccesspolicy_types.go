@@ -0,0 +1,68 @@
+/*
+Copyright 2023 The KEDA Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HTTPAccessPolicySpec defines which namespaces may call services
+// in the namespace of the HTTPAccessPolicy through the interceptor
+type HTTPAccessPolicySpec struct {
+	// (optional) The names of the services in the namespace of the policy that the policy
+	// applies to. If empty, the policy applies to every service in the namespace
+	// +optional
+	Services []string `json:"services,omitempty"`
+	// The namespaces that callers may come from. An entry ending with "*" matches every namespace
+	// that starts with the rest of the entry, e.g. "dp-team-a-*". Callers from the namespace of the
+	// policy are always allowed. Services that several policies apply to accept callers that any of
+	// them allows, and services that no policy applies to accept callers from any namespace. The
+	// namespace of callers is only known with the "reverse-dns" and "pod-ip" host resolvers of the
+	// interceptor. With the "header" host resolver, services that a policy applies to deny all callers,
+	// including the ones from the namespace of the policy
+	AllowedNamespaces []string `json:"allowedNamespaces"`
+}
+
+//+genclient
+//+genclient:noStatus
+//+k8s:openapi-gen=true
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Services",type="string",JSONPath=".spec.services"
+//+kubebuilder:printcolumn:name="AllowedNamespaces",type="string",JSONPath=".spec.allowedNamespaces"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+//+kubebuilder:resource:shortName=httpap
+
+// HTTPAccessPolicy is the Schema for the httpaccesspolicies API
+type HTTPAccessPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec HTTPAccessPolicySpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// HTTPAccessPolicyList contains a list of HTTPAccessPolicy
+type HTTPAccessPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HTTPAccessPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HTTPAccessPolicy{}, &HTTPAccessPolicyList{})
+}
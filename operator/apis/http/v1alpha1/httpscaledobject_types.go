@@ -22,14 +22,182 @@ import (
 
 // ScaleTargetRef contains all the details about an HTTP application to scale and route to
 type ScaleTargetRef struct {
-	// The name of the deployment to scale according to HTTP traffic
-	Deployment string `json:"deployment"`
+	// (optional) (deprecated) The name of the deployment to scale according to HTTP traffic.
+	// The deployment field is mutually exclusive of the name field
+	// +optional
+	Deployment string `json:"deployment,omitempty"`
+	// (optional) The API version of the workload to scale according to HTTP traffic
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty" description:"The API version of the workload (Default apps/v1)"`
+	// (optional) The kind of the workload to scale according to HTTP traffic. It must
+	// implement the scale subresource, e.g. StatefulSet or an Argo Rollout
+	// +optional
+	Kind string `json:"kind,omitempty" description:"The kind of the workload (Default Deployment)"`
+	// (optional) The name of the workload to scale according to HTTP traffic. The name
+	// field is mutually exclusive of the deployment field
+	// +optional
+	Name string `json:"name,omitempty"`
 	// The name of the service to route to
 	Service string `json:"service"`
 	// The port to route to
 	Port int32 `json:"port"`
 }
 
+const (
+	defaultScaleTargetAPIVersion = "apps/v1"
+	defaultScaleTargetKind       = "Deployment"
+)
+
+// GetAPIVersion returns the API version of the workload, which
+// defaults to the one of Deployments
+func (s ScaleTargetRef) GetAPIVersion() string {
+	if s.APIVersion == "" {
+		return defaultScaleTargetAPIVersion
+	}
+	return s.APIVersion
+}
+
+// GetKind returns the kind of the workload, which defaults to Deployment
+func (s ScaleTargetRef) GetKind() string {
+	if s.Kind == "" {
+		return defaultScaleTargetKind
+	}
+	return s.Kind
+}
+
+// GetName returns the name of the workload, which is set by either
+// the name or the deprecated deployment field
+func (s ScaleTargetRef) GetName() string {
+	if s.Name == "" {
+		return s.Deployment
+	}
+	return s.Name
+}
+
+// IsDeployment returns true if the workload is a Deployment
+func (s ScaleTargetRef) IsDeployment() bool {
+	return s.GetAPIVersion() == defaultScaleTargetAPIVersion &&
+		s.GetKind() == defaultScaleTargetKind
+}
+
+// HeaderRoutingRule routes the requests that carry all of the given headers
+// to a scale target of its own
+type HeaderRoutingRule struct {
+	// The name of the rule. It must be unique within the HTTPScaledObject
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+	// The header names and values that a request must all carry to match the rule
+	Headers map[string]string `json:"headers"`
+	// The scale target to route matching requests to (and to autoscale)
+	ScaleTargetRef *ScaleTargetRef `json:"scaleTargetRef"`
+	// (optional) Target metric value for the scale target of the rule
+	// +optional
+	TargetPendingRequests *int32 `json:"targetPendingRequests,omitempty" description:"The target metric value for the HPA (Default 100)"`
+}
+
+// WeightedBackend is an additional scale target that receives a share of
+// the requests to the hosts of an HTTPScaledObject, e.g. a canary release
+type WeightedBackend struct {
+	// The name of the backend. It must be unique among the backends and header rules
+	// of the HTTPScaledObject
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+	// The scale target to route the share of requests of the backend to (and to autoscale)
+	ScaleTargetRef *ScaleTargetRef `json:"scaleTargetRef"`
+	// The percentage of requests to route to the backend. The weights of all backends
+	// must not add up to more than 100
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Weight int32 `json:"weight"`
+	// (optional) Target metric value for the scale target of the backend
+	// +optional
+	TargetPendingRequests *int32 `json:"targetPendingRequests,omitempty" description:"The target metric value for the HPA (Default 100)"`
+}
+
+// RateLimit limits the rate at which the interceptors forward requests
+type RateLimit struct {
+	// The number of requests per second to forward
+	// +kubebuilder:validation:Minimum=1
+	RequestsPerSecond int32 `json:"requestsPerSecond"`
+	// (optional) The number of requests that may be forwarded at once above the rate
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	Burst *int32 `json:"burst,omitempty" description:"The maximum burst of requests (Default requestsPerSecond)"`
+}
+
+// ReadinessCheck is what the interceptors wait for before they forward
+// a request to a scale target without ready pods
+// +kubebuilder:validation:Enum=ReadyReplicas;Endpoints
+type ReadinessCheck string
+
+const (
+	// ReadinessCheckReadyReplicas waits until the workload has a ready replica
+	ReadinessCheckReadyReplicas ReadinessCheck = "ReadyReplicas"
+	// ReadinessCheckEndpoints waits until the service has a ready endpoint
+	ReadinessCheckEndpoints ReadinessCheck = "Endpoints"
+)
+
+// ScalingMetricType is the metric that a scale target is scaled by
+// +kubebuilder:validation:Enum=concurrency;rate;waitTimeP95
+type ScalingMetricType string
+
+const (
+	// ScalingMetricConcurrency scales by the number of pending requests
+	ScalingMetricConcurrency ScalingMetricType = "concurrency"
+	// ScalingMetricRate scales by the number of requests per second
+	ScalingMetricRate ScalingMetricType = "rate"
+	// ScalingMetricWaitTimeP95 scales by the 95th percentile of the
+	// times that requests waited in the interceptors, in milliseconds
+	ScalingMetricWaitTimeP95 ScalingMetricType = "waitTimeP95"
+)
+
+// ScalingMetric is a metric that the scale target is scaled by,
+// along with the target value of the metric for each replica
+type ScalingMetric struct {
+	// The metric to scale by. "concurrency" scales by the number of pending requests,
+	// "rate" by the number of requests per second over the interceptors' rate window,
+	// and "waitTimeP95" by the 95th percentile of the times, in milliseconds, that the
+	// requests waited in the interceptors over the same window
+	Type ScalingMetricType `json:"type"`
+	// The target value of the metric. It replaces targetPendingRequests
+	// +kubebuilder:validation:Minimum=1
+	TargetValue int32 `json:"targetValue"`
+}
+
+// RetryPolicy describes which requests the interceptors retry and how often.
+// A response is retried if it carries one of responseHeaders, or if its status code
+// is one of statusCodes and its body matches one of bodyPatterns. Without statusCodes
+// and bodyPatterns, the 503 responses that an Envoy sidecar returns when it can't
+// reach the backend are retried
+type RetryPolicy struct {
+	// (optional) The status codes of the responses to retry
+	// +optional
+	StatusCodes []int32 `json:"statusCodes,omitempty"`
+	// (optional) Regular expressions matched against the body of the responses with one
+	// of statusCodes, which bodyPatterns requires. If empty, responses with one of
+	// statusCodes are retried whatever their body
+	// +optional
+	BodyPatterns []string `json:"bodyPatterns,omitempty"`
+	// (optional) The names of response headers, e.g. x-envoy-overloaded, that mark
+	// a response to retry
+	// +optional
+	ResponseHeaders []string `json:"responseHeaders,omitempty"`
+	// (optional) Retry requests that failed because the backend could not be connected to
+	// +optional
+	ConnectionErrors bool `json:"connectionErrors,omitempty"`
+	// (optional) The maximum number of times a request is sent, including the first attempt
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxAttempts *int32 `json:"maxAttempts,omitempty" description:"The maximum number of attempts (Default KEDA_SERVICE_UNAVAILABLE_RETRY + 1)"`
+	// (optional) The timeout of each attempt. Attempts that time out are retried
+	// +optional
+	PerTryTimeout *metav1.Duration `json:"perTryTimeout,omitempty"`
+	// (optional) Retry requests with non-idempotent methods, such as POST. Only enable
+	// this if the backend can safely receive such requests more than once
+	// +optional
+	RetryNonIdempotent bool `json:"retryNonIdempotent,omitempty"`
+}
+
 // ReplicaStruct contains the minimum and maximum amount of replicas to have in the deployment
 type ReplicaStruct struct {
 	// Minimum amount of replicas to have in the deployment (Default 0)
@@ -48,8 +216,29 @@ type HTTPScaledObjectSpec struct {
 	// be routed to the Service and Port specified in the scaleTargetRef. The hosts field is mutually exclusive of the host field.
 	// +optional
 	Hosts []string `json:"hosts,omitempty"`
-	// The name of the deployment to route HTTP requests to (and to autoscale).
-	// Either this or Image must be set
+	// (optional) The path prefixes to route. Only requests to one of the hosts whose path starts
+	// with one of these prefixes will be routed to the scaleTargetRef. When several HTTPScaledObjects
+	// share a host, the longest matching prefix wins. If empty, all paths on the hosts are routed
+	// +optional
+	PathPrefixes []string `json:"pathPrefixes,omitempty"`
+	// (optional) The path that replaces the matched path prefix before the request is forwarded
+	// to the scaleTargetRef. For example, "/" strips the prefix. Only used with pathPrefixes
+	// +optional
+	PathRewrite *string `json:"pathRewrite,omitempty"`
+	// (optional) Rules that route requests to other scale targets based on their headers.
+	// Rules are evaluated in order, and requests that match none of them are routed to the
+	// scaleTargetRef
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	HeaderRules []HeaderRoutingRule `json:"headerRules,omitempty"`
+	// (optional) Backends that receive a percentage of the requests that match no header rule.
+	// The scaleTargetRef receives the remaining requests
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Backends []WeightedBackend `json:"backends,omitempty"`
+	// The workload to route HTTP requests to (and to autoscale)
 	ScaleTargetRef *ScaleTargetRef `json:"scaleTargetRef"`
 	// (optional) Replica information
 	// +optional
@@ -57,6 +246,31 @@ type HTTPScaledObjectSpec struct {
 	// (optional) Target metric value
 	// +optional
 	TargetPendingRequests *int32 `json:"targetPendingRequests,omitempty" description:"The target metric value for the HPA (Default 100)"`
+	// (optional) The metrics that the scaleTargetRef is scaled by, each with its own
+	// target value. The scaleTargetRef gets the highest replica count of all of them.
+	// Each type of metric can only be listed once. Header rules and backends are scaled
+	// by their pending requests
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	ScalingMetrics []ScalingMetric `json:"scalingMetrics,omitempty" description:"The scaling metrics (Default concurrency)"`
+	// (optional) Maximum number of pending requests for each host. The interceptor
+	// rejects new requests while a host is at the limit
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxPendingRequests *int32 `json:"maxPendingRequests,omitempty" description:"The maximum number of pending requests per host (Default unlimited)"`
+	// (optional) Rate limit of the requests to each host. Requests above the limit are
+	// rejected by the interceptor. Header rules and backends are limited separately
+	// +optional
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+	// (optional) How the interceptors retry requests to the hosts
+	// +optional
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+	// (optional) What the interceptors wait for before they forward a request to a scale
+	// target without ready pods. "Endpoints" waits until the service has a ready endpoint,
+	// which avoids forwarding requests before the service routes to the new pods
+	// +optional
+	ReadinessCheck ReadinessCheck `json:"readinessCheck,omitempty" description:"What to wait for on cold starts (Default ReadyReplicas)"`
 	// (optional) Cooldown period value
 	// +optional
 	CooldownPeriod *int32 `json:"scaledownPeriod,omitempty" description:"Cooldown period (seconds) for resources to scale down (Default 300)"`
@@ -124,6 +338,11 @@ type HTTPScaledObjectCondition struct {
 type HTTPScaledObjectStatus struct {
 	// Conditions of the operator
 	Conditions []HTTPScaledObjectCondition `json:"conditions,omitempty" description:"List of auditable conditions of the operator"`
+	// The routing table keys that the operator last added entries under for the
+	// HTTPScaledObject. Their entries are replaced when it is updated, and those
+	// that it no longer declares are removed
+	// +optional
+	RoutingKeys []string `json:"routingKeys,omitempty" description:"Routing table keys of the HTTPScaledObject"`
 }
 
 //+genclient
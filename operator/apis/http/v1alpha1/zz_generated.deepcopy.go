@@ -22,9 +22,93 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPAccessPolicy) DeepCopyInto(out *HTTPAccessPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPAccessPolicy.
+func (in *HTTPAccessPolicy) DeepCopy() *HTTPAccessPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPAccessPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HTTPAccessPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPAccessPolicyList) DeepCopyInto(out *HTTPAccessPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HTTPAccessPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPAccessPolicyList.
+func (in *HTTPAccessPolicyList) DeepCopy() *HTTPAccessPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPAccessPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HTTPAccessPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPAccessPolicySpec) DeepCopyInto(out *HTTPAccessPolicySpec) {
+	*out = *in
+	if in.Services != nil {
+		in, out := &in.Services, &out.Services
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPAccessPolicySpec.
+func (in *HTTPAccessPolicySpec) DeepCopy() *HTTPAccessPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPAccessPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HTTPScaledObject) DeepCopyInto(out *HTTPScaledObject) {
 	*out = *in
@@ -102,6 +186,40 @@ func (in *HTTPScaledObjectList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HTTPScaledObjectSpec) DeepCopyInto(out *HTTPScaledObjectSpec) {
 	*out = *in
+	if in.Host != nil {
+		in, out := &in.Host, &out.Host
+		*out = new(string)
+		**out = **in
+	}
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PathPrefixes != nil {
+		in, out := &in.PathPrefixes, &out.PathPrefixes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PathRewrite != nil {
+		in, out := &in.PathRewrite, &out.PathRewrite
+		*out = new(string)
+		**out = **in
+	}
+	if in.HeaderRules != nil {
+		in, out := &in.HeaderRules, &out.HeaderRules
+		*out = make([]HeaderRoutingRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Backends != nil {
+		in, out := &in.Backends, &out.Backends
+		*out = make([]WeightedBackend, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.ScaleTargetRef != nil {
 		in, out := &in.ScaleTargetRef, &out.ScaleTargetRef
 		*out = new(ScaleTargetRef)
@@ -117,6 +235,26 @@ func (in *HTTPScaledObjectSpec) DeepCopyInto(out *HTTPScaledObjectSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.ScalingMetrics != nil {
+		in, out := &in.ScalingMetrics, &out.ScalingMetrics
+		*out = make([]ScalingMetric, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxPendingRequests != nil {
+		in, out := &in.MaxPendingRequests, &out.MaxPendingRequests
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RateLimit != nil {
+		in, out := &in.RateLimit, &out.RateLimit
+		*out = new(RateLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RetryPolicy != nil {
+		in, out := &in.RetryPolicy, &out.RetryPolicy
+		*out = new(RetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.CooldownPeriod != nil {
 		in, out := &in.CooldownPeriod, &out.CooldownPeriod
 		*out = new(int32)
@@ -142,6 +280,11 @@ func (in *HTTPScaledObjectStatus) DeepCopyInto(out *HTTPScaledObjectStatus) {
 		*out = make([]HTTPScaledObjectCondition, len(*in))
 		copy(*out, *in)
 	}
+	if in.RoutingKeys != nil {
+		in, out := &in.RoutingKeys, &out.RoutingKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPScaledObjectStatus.
@@ -154,6 +297,58 @@ func (in *HTTPScaledObjectStatus) DeepCopy() *HTTPScaledObjectStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HeaderRoutingRule) DeepCopyInto(out *HeaderRoutingRule) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ScaleTargetRef != nil {
+		in, out := &in.ScaleTargetRef, &out.ScaleTargetRef
+		*out = new(ScaleTargetRef)
+		**out = **in
+	}
+	if in.TargetPendingRequests != nil {
+		in, out := &in.TargetPendingRequests, &out.TargetPendingRequests
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HeaderRoutingRule.
+func (in *HeaderRoutingRule) DeepCopy() *HeaderRoutingRule {
+	if in == nil {
+		return nil
+	}
+	out := new(HeaderRoutingRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RateLimit) DeepCopyInto(out *RateLimit) {
+	*out = *in
+	if in.Burst != nil {
+		in, out := &in.Burst, &out.Burst
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RateLimit.
+func (in *RateLimit) DeepCopy() *RateLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(RateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ReplicaStruct) DeepCopyInto(out *ReplicaStruct) {
 	*out = *in
@@ -179,6 +374,46 @@ func (in *ReplicaStruct) DeepCopy() *ReplicaStruct {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetryPolicy) DeepCopyInto(out *RetryPolicy) {
+	*out = *in
+	if in.StatusCodes != nil {
+		in, out := &in.StatusCodes, &out.StatusCodes
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+	if in.BodyPatterns != nil {
+		in, out := &in.BodyPatterns, &out.BodyPatterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ResponseHeaders != nil {
+		in, out := &in.ResponseHeaders, &out.ResponseHeaders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxAttempts != nil {
+		in, out := &in.MaxAttempts, &out.MaxAttempts
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PerTryTimeout != nil {
+		in, out := &in.PerTryTimeout, &out.PerTryTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryPolicy.
+func (in *RetryPolicy) DeepCopy() *RetryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ScaleTargetRef) DeepCopyInto(out *ScaleTargetRef) {
 	*out = *in
@@ -193,3 +428,43 @@ func (in *ScaleTargetRef) DeepCopy() *ScaleTargetRef {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScalingMetric) DeepCopyInto(out *ScalingMetric) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScalingMetric.
+func (in *ScalingMetric) DeepCopy() *ScalingMetric {
+	if in == nil {
+		return nil
+	}
+	out := new(ScalingMetric)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WeightedBackend) DeepCopyInto(out *WeightedBackend) {
+	*out = *in
+	if in.ScaleTargetRef != nil {
+		in, out := &in.ScaleTargetRef, &out.ScaleTargetRef
+		*out = new(ScaleTargetRef)
+		**out = **in
+	}
+	if in.TargetPendingRequests != nil {
+		in, out := &in.TargetPendingRequests, &out.TargetPendingRequests
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WeightedBackend.
+func (in *WeightedBackend) DeepCopy() *WeightedBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(WeightedBackend)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -46,46 +46,84 @@ func removeApplicationResources(
 		httpso.Namespace,
 	)
 
-	// Delete App ScaledObject
-	scaledObject := &unstructured.Unstructured{}
-	scaledObject.SetNamespace(httpso.Namespace)
-	scaledObject.SetName(config.AppScaledObjectName(httpso))
-	scaledObject.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   "keda.sh",
-		Kind:    "ScaledObject",
-		Version: "v1alpha1",
-	})
-	if err := cl.Delete(ctx, scaledObject); err != nil {
-		if apierrs.IsNotFound(err) {
-			logger.Info("App ScaledObject not found, moving on")
-		} else {
-			logger.Error(err, "Deleting scaledobject")
-			AddCondition(
-				httpso,
-				*SetMessage(
-					CreateCondition(
-						v1alpha1.Error,
-						v1.ConditionFalse,
-						v1alpha1.AppScaledObjectTerminationError,
+	// Delete App ScaledObjects, including those of the header rules
+	// and weighted backends, and then those that httpso no longer
+	// declares but that are still labelled as its own
+	scaledObjectNames := []string{config.AppScaledObjectName(httpso)}
+	for _, rule := range httpso.Spec.HeaderRules {
+		scaledObjectNames = append(
+			scaledObjectNames,
+			config.AdditionalScaledObjectName(httpso, rule.Name),
+		)
+	}
+	for _, backend := range httpso.Spec.Backends {
+		scaledObjectNames = append(
+			scaledObjectNames,
+			config.AdditionalScaledObjectName(httpso, backend.Name),
+		)
+	}
+	for _, scaledObjectName := range scaledObjectNames {
+		scaledObject := &unstructured.Unstructured{}
+		scaledObject.SetNamespace(httpso.Namespace)
+		scaledObject.SetName(scaledObjectName)
+		scaledObject.SetGroupVersionKind(schema.GroupVersionKind{
+			Group:   "keda.sh",
+			Kind:    "ScaledObject",
+			Version: "v1alpha1",
+		})
+		if err := cl.Delete(ctx, scaledObject); err != nil {
+			if apierrs.IsNotFound(err) {
+				logger.Info("App ScaledObject not found, moving on", "ScaledObject", scaledObjectName)
+			} else {
+				logger.Error(err, "Deleting scaledobject", "ScaledObject", scaledObjectName)
+				AddCondition(
+					httpso,
+					*SetMessage(
+						CreateCondition(
+							v1alpha1.Error,
+							v1.ConditionFalse,
+							v1alpha1.AppScaledObjectTerminationError,
+						),
+						err.Error(),
 					),
-					err.Error(),
-				),
-			)
-			return err
+				)
+				return err
+			}
 		}
 	}
+	if err := pruneScaledObjects(ctx, cl, logger, httpso, nil); err != nil {
+		AddCondition(
+			httpso,
+			*SetMessage(
+				CreateCondition(
+					v1alpha1.Error,
+					v1.ConditionFalse,
+					v1alpha1.AppScaledObjectTerminationError,
+				),
+				err.Error(),
+			),
+		)
+		return err
+	}
 	AddCondition(httpso, *CreateCondition(
 		v1alpha1.Terminated,
 		v1.ConditionTrue,
 		v1alpha1.AppScaledObjectTerminated,
 	))
 
+	// the keys that httpso last added entries under can differ from
+	// those of its spec, if the spec was updated since. the keys of
+	// the spec are only used for objects that didn't record them
+	keys := httpso.Status.RoutingKeys
+	if len(keys) == 0 {
+		keys = allRoutingKeys(httpso)
+	}
 	return removeAndUpdateRoutingTable(
 		ctx,
 		logger,
 		cl,
 		routingTable,
-		httpso.Spec.Hosts,
+		keys,
 		baseConfig.CurrentNamespace,
 	)
 }
@@ -135,24 +173,15 @@ func createOrUpdateApplicationResources(
 		return err
 	}
 
-	targetPendingReqs := baseConfig.TargetPendingRequests
-	if tpr := httpso.Spec.TargetPendingRequests; tpr != nil {
-		targetPendingReqs = *tpr
-	}
-
-	return addAndUpdateRoutingTable(
+	keys, err := addAndUpdateRoutingTable(
 		ctx,
 		logger,
 		cl,
 		routingTable,
-		httpso.Spec.Hosts,
-		routing.NewTarget(
-			httpso.GetNamespace(),
-			httpso.Spec.ScaleTargetRef.Service,
-			int(httpso.Spec.ScaleTargetRef.Port),
-			httpso.Spec.ScaleTargetRef.Deployment,
-			targetPendingReqs,
-		),
+		routingTargets(httpso, baseConfig.TargetPendingRequests),
+		httpso.Status.RoutingKeys,
 		baseConfig.CurrentNamespace,
 	)
+	httpso.Status.RoutingKeys = keys
+	return err
 }
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"testing"
+
+	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kedacore/http-add-on/operator/apis/http/v1alpha1"
+	"github.com/kedacore/http-add-on/operator/controllers/http/config"
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+// changing the weight and the scale target of a weighted backend
+// should reach the routing table and the ScaledObject of the backend
+func TestCreateOrUpdateApplicationResourcesBackendUpdate(t *testing.T) {
+	r := require.New(t)
+	testInfra := newCommonTestInfra("testns", "testapp")
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testInfra.ns,
+			Name:      routing.ConfigMapRoutingTableName,
+		},
+		Data: map[string]string{},
+	}
+	r.NoError(routing.SaveTableToConfigMap(routing.NewTable(), cm))
+	r.NoError(testInfra.cl.Create(testInfra.ctx, cm))
+
+	table := routing.NewTable()
+	baseConfig := config.Base{
+		TargetPendingRequests: 100,
+		CurrentNamespace:      testInfra.ns,
+	}
+	externalScalerConfig := config.ExternalScaler{
+		ServiceName: "testscaler",
+		Port:        9090,
+	}
+	httpso := &testInfra.httpso
+	httpso.Spec.Hosts = []string{"myhost.com"}
+	httpso.Spec.Host = nil
+	setBackend := func(deployment string, weight int32) {
+		httpso.Spec.Backends = []v1alpha1.WeightedBackend{
+			{
+				Name:   "canary",
+				Weight: weight,
+				ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+					Deployment: deployment,
+					Service:    deployment,
+					Port:       8081,
+				},
+			},
+		}
+	}
+
+	setBackend("testapp-canary", 10)
+	r.NoError(createOrUpdateApplicationResources(
+		testInfra.ctx,
+		testInfra.logger,
+		testInfra.cl,
+		table,
+		baseConfig,
+		externalScalerConfig,
+		httpso,
+	))
+	setBackend("testapp-canary-v2", 50)
+	r.NoError(createOrUpdateApplicationResources(
+		testInfra.ctx,
+		testInfra.logger,
+		testInfra.cl,
+		table,
+		baseConfig,
+		externalScalerConfig,
+		httpso,
+	))
+
+	target, err := table.Lookup("myhost.com")
+	r.NoError(err)
+	r.Equal([]routing.Backend{{Name: "canary", Weight: 50}}, target.Backends)
+	backendTarget, err := table.Lookup(routing.BackendKey("myhost.com", "canary"))
+	r.NoError(err)
+	r.Equal("testapp-canary-v2", backendTarget.Deployment)
+	r.Equal("testapp-canary-v2", backendTarget.Service)
+
+	var backendSO kedav1alpha1.ScaledObject
+	r.NoError(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+		Namespace: testInfra.ns,
+		Name:      config.AdditionalScaledObjectName(httpso, "canary"),
+	}, &backendSO))
+	r.Equal("testapp-canary-v2", backendSO.Spec.ScaleTargetRef.Name)
+
+	r.Equal(
+		[]string{"myhost.com", routing.BackendKey("myhost.com", "canary")},
+		httpso.Status.RoutingKeys,
+	)
+
+	// the saved routing table has the new weights too
+	r.NoError(testInfra.cl.Get(testInfra.ctx, client.ObjectKeyFromObject(cm), cm))
+	savedTable, err := routing.FetchTableFromConfigMap(cm)
+	r.NoError(err)
+	savedTarget, err := savedTable.Lookup("myhost.com")
+	r.NoError(err)
+	r.Equal(target, savedTarget)
+}
@@ -9,5 +9,14 @@ import (
 // AppScaledObjectName returns the name of the ScaledObject
 // that should be created alongside the given HTTPScaledObject.
 func AppScaledObjectName(httpso *v1alpha1.HTTPScaledObject) string {
-	return fmt.Sprintf("%s-app", httpso.Spec.ScaleTargetRef.Deployment)
+	return fmt.Sprintf("%s-app", httpso.Spec.ScaleTargetRef.GetName())
+}
+
+// AdditionalScaledObjectName returns the name of the ScaledObject
+// that should be created for the header routing rule or weighted
+// backend with the given name of the given HTTPScaledObject. Its
+// suffix sets it apart from the names of the app ScaledObjects,
+// which end in -app.
+func AdditionalScaledObjectName(httpso *v1alpha1.HTTPScaledObject, name string) string {
+	return fmt.Sprintf("%s-%s-route", httpso.GetName(), name)
 }
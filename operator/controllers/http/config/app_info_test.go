@@ -27,3 +27,20 @@ func TestAppScaledObjectName(t *testing.T) {
 		name,
 	)
 }
+
+// the ScaledObjects of header rules and weighted backends should
+// not be named like the app ScaledObject of another HTTPScaledObject
+func TestAdditionalScaledObjectName(t *testing.T) {
+	r := require.New(t)
+	obj := &v1alpha1.HTTPScaledObject{}
+	obj.SetName("foo")
+	other := &v1alpha1.HTTPScaledObject{
+		Spec: v1alpha1.HTTPScaledObjectSpec{
+			ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+				Name: "foo-bar",
+			},
+		},
+	}
+	r.Equal("foo-bar-route", AdditionalScaledObjectName(obj, "bar"))
+	r.NotEqual(AppScaledObjectName(other), AdditionalScaledObjectName(obj, "bar"))
+}
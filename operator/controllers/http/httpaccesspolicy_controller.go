@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	pkgerrs "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	httpv1alpha1 "github.com/kedacore/http-add-on/operator/apis/http/v1alpha1"
+	"github.com/kedacore/http-add-on/operator/controllers/http/config"
+	"github.com/kedacore/http-add-on/pkg/k8s"
+	"github.com/kedacore/http-add-on/pkg/policy"
+)
+
+// HTTPAccessPolicyReconciler reconciles HTTPAccessPolicy objects into
+// the access policies ConfigMap that the interceptors enforce
+//
+//revive:disable-next-line:exported
+//goland:noinspection GoNameStartsWithPackageName
+type HTTPAccessPolicyReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	BaseConfig config.Base
+}
+
+// +kubebuilder:rbac:groups=http.keda.sh,resources=httpaccesspolicies,verbs=get;list;watch
+
+// Reconcile reconciles a newly created, deleted, or otherwise changed
+// HTTPAccessPolicy. Since the ConfigMap holds the policies of the whole
+// cluster, it is rebuilt from every HTTPAccessPolicy on each change
+func (r *HTTPAccessPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx, "httpaccesspolicy", req.NamespacedName)
+	logger.Info("Reconciliation start")
+
+	policies := &httpv1alpha1.HTTPAccessPolicyList{}
+	if err := r.Client.List(ctx, policies); err != nil {
+		logger.Error(err, "Listing the HTTP access policies, requeueing")
+		return ctrl.Result{
+			RequeueAfter: 500 * time.Millisecond,
+		}, err
+	}
+
+	if err := updatePolicyMap(
+		ctx,
+		logger,
+		r.Client,
+		r.BaseConfig.CurrentNamespace,
+		newPolicyTable(policies.Items),
+	); err != nil {
+		return ctrl.Result{
+			RequeueAfter: 1000 * time.Millisecond,
+		}, err
+	}
+
+	logger.Info("Reconcile success")
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HTTPAccessPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&httpv1alpha1.HTTPAccessPolicy{}).
+		Complete(r)
+}
+
+// newPolicyTable returns the policy table that holds the given policies
+func newPolicyTable(policies []httpv1alpha1.HTTPAccessPolicy) *policy.Table {
+	table := policy.NewTable()
+	for _, p := range policies {
+		table.Set(policy.Key(p.GetNamespace(), p.GetName()), policy.Policy{
+			Namespace:         p.GetNamespace(),
+			Services:          p.Spec.Services,
+			AllowedNamespaces: p.Spec.AllowedNamespaces,
+		})
+	}
+	return table
+}
+
+// updatePolicyMap saves table to the access policies ConfigMap in
+// namespace, and creates the ConfigMap if it doesn't exist yet
+func updatePolicyMap(
+	ctx context.Context,
+	lggr logr.Logger,
+	cl client.Client,
+	namespace string,
+	table *policy.Table,
+) error {
+	lggr = lggr.WithName("updatePolicyMap")
+	policyConfigMap, err := k8s.GetConfigMap(ctx, cl, namespace, policy.ConfigMapAccessPoliciesName)
+	if k8serrors.IsNotFound(err) {
+		newCM := &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      policy.ConfigMapAccessPoliciesName,
+				Namespace: namespace,
+			},
+		}
+		if err := policy.SaveTableToConfigMap(table, newCM); err != nil {
+			lggr.Error(err, "couldn't save access policies to ConfigMap", "configMap", policy.ConfigMapAccessPoliciesName)
+			return pkgerrs.Wrap(err, "ConfigMap save error")
+		}
+		if err := cl.Create(ctx, newCM); err != nil {
+			lggr.Error(err, "couldn't create access policies ConfigMap", "configMap", policy.ConfigMapAccessPoliciesName)
+			return pkgerrs.Wrap(err, "creating access policies ConfigMap in Kubernetes")
+		}
+		return nil
+	}
+	if err != nil {
+		lggr.Error(err, "Error getting configmap", "configMapName", policy.ConfigMapAccessPoliciesName)
+		return pkgerrs.Wrap(err, "access policies ConfigMap fetch error")
+	}
+	newCM := policyConfigMap.DeepCopy()
+	if err := policy.SaveTableToConfigMap(table, newCM); err != nil {
+		lggr.Error(err, "couldn't save access policies to ConfigMap", "configMap", policy.ConfigMapAccessPoliciesName)
+		return pkgerrs.Wrap(err, "ConfigMap save error")
+	}
+	if _, err := k8s.PatchConfigMap(ctx, lggr, cl, policyConfigMap, newCM); err != nil {
+		lggr.Error(err, "couldn't save access policies ConfigMap to Kubernetes", "configMap", policy.ConfigMapAccessPoliciesName)
+		return pkgerrs.Wrap(err, "saving access policies ConfigMap to Kubernetes")
+	}
+	return nil
+}
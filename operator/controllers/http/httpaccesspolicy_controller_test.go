@@ -0,0 +1,70 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kedacore/http-add-on/operator/apis/http/v1alpha1"
+	"github.com/kedacore/http-add-on/operator/controllers/http/config"
+	"github.com/kedacore/http-add-on/pkg/policy"
+)
+
+func TestHTTPAccessPolicyReconcile(t *testing.T) {
+	const operatorNs = "keda"
+	r := require.New(t)
+	testInfra := newCommonTestInfra("payments", "ledger")
+	reconciler := &HTTPAccessPolicyReconciler{
+		Client:     testInfra.cl,
+		BaseConfig: config.Base{CurrentNamespace: operatorNs},
+	}
+
+	ap := &v1alpha1.HTTPAccessPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "payments",
+			Name:      "ledger",
+		},
+		Spec: v1alpha1.HTTPAccessPolicySpec{
+			Services:          []string{"ledger"},
+			AllowedNamespaces: []string{"billing"},
+		},
+	}
+	r.NoError(testInfra.cl.Create(testInfra.ctx, ap))
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "payments", Name: "ledger"}}
+
+	getTable := func() *policy.Table {
+		cm := &corev1.ConfigMap{}
+		r.NoError(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+			Namespace: operatorNs,
+			Name:      policy.ConfigMapAccessPoliciesName,
+		}, cm))
+		table, err := policy.FetchTableFromConfigMap(cm)
+		r.NoError(err)
+		return table
+	}
+
+	// the ConfigMap is created on the first reconcile
+	_, err := reconciler.Reconcile(testInfra.ctx, req)
+	r.NoError(err)
+	table := getTable()
+	r.NoError(table.Check("billing", "payments", "ledger"))
+	r.ErrorIs(table.Check("shop", "payments", "ledger"), policy.ErrAccessDenied)
+
+	// and updated on the following ones
+	ap.Spec.AllowedNamespaces = append(ap.Spec.AllowedNamespaces, "shop")
+	r.NoError(testInfra.cl.Update(testInfra.ctx, ap))
+	_, err = reconciler.Reconcile(testInfra.ctx, req)
+	r.NoError(err)
+	r.NoError(getTable().Check("shop", "payments", "ledger"))
+
+	// deleted policies are dropped
+	r.NoError(testInfra.cl.Delete(testInfra.ctx, ap))
+	_, err = reconciler.Reconcile(testInfra.ctx, req)
+	r.NoError(err)
+	r.NoError(getTable().Check("anywhere", "payments", "ledger"))
+}
@@ -19,6 +19,8 @@ package http
 import (
 	"context"
 	"errors"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -116,6 +118,27 @@ func (r *HTTPScaledObjectReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, err
 	}
 
+	// ensure every scale target names its workload exactly once
+	if err := validateScaleTargetRefs(logger, httpso); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// ensure the header rules and weighted backends can be told apart
+	// and the weights of the backends add up to at most 100%
+	if err := validateBackends(logger, httpso); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// ensure every scaling metric gets its own metric name
+	if err := validateScalingMetrics(logger, httpso); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// ensure the retry policy only has valid body patterns
+	if err := validateRetryPolicy(logger, httpso); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// httpso is updated now
 	logger.Info(
 		"Reconciling HTTPScaledObject",
@@ -181,7 +204,7 @@ func (r *HTTPScaledObjectReconciler) SetupWithManager(mgr ctrl.Manager) error {
 }
 
 // sanitize hosts by converting the host definition to hosts are erroring
-// when both fields are set
+// when both fields are set or when a host has a misplaced wildcard
 func sanitizeHosts(
 	logger logr.Logger,
 	httpso *httpv1alpha1.HTTPScaledObject,
@@ -199,8 +222,125 @@ func sanitizeHosts(
 		httpso.Spec.Hosts = []string{*httpso.Spec.Host}
 		httpso.Spec.Host = nil
 		logger.Info("Using the 'host' field is deprecated. Please consider switching to the 'hosts' field")
+	}
+	for _, host := range httpso.Spec.Hosts {
+		if strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+			err := errors.New("invalid wildcard host Error")
+			logger.Error(err, "Wildcards are only allowed as the first label of a host, e.g. '*.example.com'", "host", host)
+			return err
+		}
+	}
+	return nil
+}
+
+// validateScaleTargetRefs errors when a scale target of httpso sets
+// both or neither of the name and the deprecated deployment fields
+func validateScaleTargetRefs(
+	logger logr.Logger,
+	httpso *httpv1alpha1.HTTPScaledObject,
+) error {
+	refs := []*httpv1alpha1.ScaleTargetRef{httpso.Spec.ScaleTargetRef}
+	for _, rule := range httpso.Spec.HeaderRules {
+		refs = append(refs, rule.ScaleTargetRef)
+	}
+	for _, backend := range httpso.Spec.Backends {
+		refs = append(refs, backend.ScaleTargetRef)
+	}
+	for _, ref := range refs {
+		switch {
+		case ref == nil:
+			err := errors.New("no scale target specified Error")
+			logger.Error(err, "Every 'scaleTargetRef' field must be defined")
+			return err
+		case ref.Name != "" && ref.Deployment != "":
+			err := errors.New("mutually exclusive fields Error")
+			logger.Error(err, "Only one of 'name' or 'deployment' field can be defined", "scaleTargetRef", *ref)
+			return err
+		case ref.Name == "" && ref.Deployment == "":
+			err := errors.New("no scale target name specified Error")
+			logger.Error(err, "At least one of 'name' or 'deployment' field must be defined", "scaleTargetRef", *ref)
+			return err
+		case ref.Deployment != "" && !ref.IsDeployment():
+			err := errors.New("deployment field with other kind Error")
+			logger.Error(err, "The 'deployment' field can only be used for Deployments, use the 'name' field instead", "scaleTargetRef", *ref)
+			return err
+		}
+		if ref.Deployment != "" {
+			logger.Info("Using the 'deployment' field is deprecated. Please consider switching to the 'name' field")
+		}
+	}
+	return nil
+}
+
+// validateBackends errors when a weighted backend shares its name with
+// another backend or a header rule, or when the weights of the backends
+// add up to more than 100
+func validateBackends(
+	logger logr.Logger,
+	httpso *httpv1alpha1.HTTPScaledObject,
+) error {
+	names := make(map[string]struct{}, len(httpso.Spec.HeaderRules))
+	for _, rule := range httpso.Spec.HeaderRules {
+		names[rule.Name] = struct{}{}
+	}
+	var totalWeight int32
+	for _, backend := range httpso.Spec.Backends {
+		if _, ok := names[backend.Name]; ok {
+			err := errors.New("duplicate backend name Error")
+			logger.Error(err, "Backend names must be unique among the backends and header rules", "backend", backend.Name)
+			return err
+		}
+		names[backend.Name] = struct{}{}
+		totalWeight += backend.Weight
+	}
+	if totalWeight > 100 {
+		err := errors.New("backend weights Error")
+		logger.Error(err, "The weights of the backends must not add up to more than 100", "totalWeight", totalWeight)
+		return err
+	}
+	return nil
+}
+
+// validateScalingMetrics errors when a type of metric is listed more
+// than once in the scaling metrics. KEDA rejects ScaledObjects whose
+// metrics share a name, and each type of metric has a single name
+func validateScalingMetrics(
+	logger logr.Logger,
+	httpso *httpv1alpha1.HTTPScaledObject,
+) error {
+	types := make(map[httpv1alpha1.ScalingMetricType]struct{}, len(httpso.Spec.ScalingMetrics))
+	for _, metric := range httpso.Spec.ScalingMetrics {
+		if _, ok := types[metric.Type]; ok {
+			err := errors.New("duplicate scaling metric type Error")
+			logger.Error(err, "Each type of metric can only be listed once in the 'scalingMetrics' field", "type", metric.Type)
+			return err
+		}
+		types[metric.Type] = struct{}{}
+	}
+	return nil
+}
+
+// validateRetryPolicy errors when a body pattern of the retry policy
+// is not a valid regular expression, or when the retry policy has body
+// patterns but no status codes. Matching the body of every response
+// would hold back every response until its body was read
+func validateRetryPolicy(
+	logger logr.Logger,
+	httpso *httpv1alpha1.HTTPScaledObject,
+) error {
+	if httpso.Spec.RetryPolicy == nil {
 		return nil
-	default:
-		return nil
 	}
+	if len(httpso.Spec.RetryPolicy.BodyPatterns) > 0 && len(httpso.Spec.RetryPolicy.StatusCodes) == 0 {
+		err := errors.New("body patterns without status codes Error")
+		logger.Error(err, "The 'bodyPatterns' field of the retry policy can only be used along with the 'statusCodes' field")
+		return err
+	}
+	for _, pattern := range httpso.Spec.RetryPolicy.BodyPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			logger.Error(err, "Retry policy body patterns must be valid regular expressions", "pattern", pattern)
+			return err
+		}
+	}
+	return nil
 }
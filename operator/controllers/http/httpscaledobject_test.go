@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/kedacore/http-add-on/operator/apis/http/v1alpha1"
 )
 
 func TestSanitizeHostsWithOnlyHosts(t *testing.T) {
@@ -63,3 +65,115 @@ func TestSanitizeHostsWithNoHostOrHosts(t *testing.T) {
 	r.Nil(testInfra.httpso.Spec.Host)
 	r.Nil(testInfra.httpso.Spec.Hosts)
 }
+
+func TestSanitizeHostsWithWildcardHosts(t *testing.T) {
+	r := require.New(t)
+
+	testInfra := newCommonTestInfra("testns", "testapp")
+	testInfra.httpso.Spec.Hosts = []string{"*.preview.example.com"}
+	r.NoError(sanitizeHosts(
+		testInfra.logger,
+		&testInfra.httpso,
+	))
+
+	for _, host := range []string{"*", "pr-*.example.com", "preview.*.com", "*.*.example.com"} {
+		testInfra.httpso.Spec.Hosts = []string{host}
+		r.Error(sanitizeHosts(
+			testInfra.logger,
+			&testInfra.httpso,
+		), "host %s", host)
+	}
+}
+
+func TestValidateBackends(t *testing.T) {
+	r := require.New(t)
+
+	testInfra := newCommonTestInfra("testns", "testapp")
+	testInfra.httpso.Spec.HeaderRules = []v1alpha1.HeaderRoutingRule{
+		{Name: "beta"},
+	}
+	testInfra.httpso.Spec.Backends = []v1alpha1.WeightedBackend{
+		{Name: "canary", Weight: 60},
+		{Name: "canary-2", Weight: 40},
+	}
+	r.NoError(validateBackends(testInfra.logger, &testInfra.httpso))
+
+	testInfra.httpso.Spec.Backends[1].Weight = 41
+	r.Error(validateBackends(testInfra.logger, &testInfra.httpso))
+
+	testInfra.httpso.Spec.Backends[1] = v1alpha1.WeightedBackend{Name: "beta", Weight: 10}
+	r.Error(validateBackends(testInfra.logger, &testInfra.httpso))
+
+	testInfra.httpso.Spec.Backends[1] = v1alpha1.WeightedBackend{Name: "canary", Weight: 10}
+	r.Error(validateBackends(testInfra.logger, &testInfra.httpso))
+}
+
+func TestValidateScalingMetrics(t *testing.T) {
+	r := require.New(t)
+
+	testInfra := newCommonTestInfra("testns", "testapp")
+	r.NoError(validateScalingMetrics(testInfra.logger, &testInfra.httpso))
+
+	testInfra.httpso.Spec.ScalingMetrics = []v1alpha1.ScalingMetric{
+		{Type: v1alpha1.ScalingMetricConcurrency, TargetValue: 10},
+		{Type: v1alpha1.ScalingMetricRate, TargetValue: 20},
+	}
+	r.NoError(validateScalingMetrics(testInfra.logger, &testInfra.httpso))
+
+	testInfra.httpso.Spec.ScalingMetrics = append(
+		testInfra.httpso.Spec.ScalingMetrics,
+		v1alpha1.ScalingMetric{Type: v1alpha1.ScalingMetricRate, TargetValue: 50},
+	)
+	r.Error(validateScalingMetrics(testInfra.logger, &testInfra.httpso))
+}
+
+func TestValidateRetryPolicy(t *testing.T) {
+	r := require.New(t)
+
+	testInfra := newCommonTestInfra("testns", "testapp")
+	r.NoError(validateRetryPolicy(testInfra.logger, &testInfra.httpso))
+
+	testInfra.httpso.Spec.RetryPolicy = &v1alpha1.RetryPolicy{
+		BodyPatterns: []string{"^upstream connect error", "(?i)overloaded"},
+	}
+	// body patterns require status codes
+	r.Error(validateRetryPolicy(testInfra.logger, &testInfra.httpso))
+
+	testInfra.httpso.Spec.RetryPolicy.StatusCodes = []int32{503}
+	r.NoError(validateRetryPolicy(testInfra.logger, &testInfra.httpso))
+
+	testInfra.httpso.Spec.RetryPolicy.BodyPatterns = append(
+		testInfra.httpso.Spec.RetryPolicy.BodyPatterns,
+		"(",
+	)
+	r.Error(validateRetryPolicy(testInfra.logger, &testInfra.httpso))
+}
+
+func TestValidateScaleTargetRefs(t *testing.T) {
+	r := require.New(t)
+
+	testInfra := newCommonTestInfra("testns", "testapp")
+	r.NoError(validateScaleTargetRefs(testInfra.logger, &testInfra.httpso))
+
+	testInfra.httpso.Spec.ScaleTargetRef.Name = "testapp"
+	r.Error(validateScaleTargetRefs(testInfra.logger, &testInfra.httpso))
+
+	testInfra.httpso.Spec.ScaleTargetRef.Deployment = ""
+	testInfra.httpso.Spec.ScaleTargetRef.Kind = "StatefulSet"
+	r.NoError(validateScaleTargetRefs(testInfra.logger, &testInfra.httpso))
+
+	testInfra.httpso.Spec.Backends = []v1alpha1.WeightedBackend{
+		{
+			Name:           "canary",
+			ScaleTargetRef: &v1alpha1.ScaleTargetRef{Kind: "StatefulSet", Deployment: "canary"},
+			Weight:         10,
+		},
+	}
+	r.Error(validateScaleTargetRefs(testInfra.logger, &testInfra.httpso))
+
+	testInfra.httpso.Spec.Backends[0].ScaleTargetRef = &v1alpha1.ScaleTargetRef{Kind: "StatefulSet"}
+	r.Error(validateScaleTargetRefs(testInfra.logger, &testInfra.httpso))
+
+	testInfra.httpso.Spec.Backends[0].ScaleTargetRef = nil
+	r.Error(validateScaleTargetRefs(testInfra.logger, &testInfra.httpso))
+}
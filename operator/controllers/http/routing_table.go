@@ -2,31 +2,207 @@ package http
 
 import (
 	"context"
+	"sort"
 
 	"github.com/go-logr/logr"
 	pkgerrs "github.com/pkg/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/kedacore/http-add-on/operator/apis/http/v1alpha1"
 	"github.com/kedacore/http-add-on/pkg/k8s"
 	"github.com/kedacore/http-add-on/pkg/routing"
 )
 
+// pathPrefixes returns the path prefixes of httpso. An HTTPScaledObject
+// without path prefixes serves every path, which the empty prefix
+// stands for
+func pathPrefixes(httpso *v1alpha1.HTTPScaledObject) []string {
+	if len(httpso.Spec.PathPrefixes) == 0 {
+		return []string{""}
+	}
+	return httpso.Spec.PathPrefixes
+}
+
+// routingKeys returns the routing table keys for httpso, one for
+// every combination of its hosts and path prefixes
+func routingKeys(httpso *v1alpha1.HTTPScaledObject) []string {
+	prefixes := pathPrefixes(httpso)
+	keys := make([]string, 0, len(httpso.Spec.Hosts)*len(prefixes))
+	for _, host := range httpso.Spec.Hosts {
+		for _, prefix := range prefixes {
+			keys = append(keys, routing.Key(host, prefix))
+		}
+	}
+	return keys
+}
+
+// ruleRoutingKeys returns the routing table keys for the header
+// rule named rule of httpso
+func ruleRoutingKeys(httpso *v1alpha1.HTTPScaledObject, rule string) []string {
+	keys := routingKeys(httpso)
+	for i, key := range keys {
+		keys[i] = routing.RuleKey(key, rule)
+	}
+	return keys
+}
+
+// backendRoutingKeys returns the routing table keys for the
+// weighted backend named backend of httpso
+func backendRoutingKeys(httpso *v1alpha1.HTTPScaledObject, backend string) []string {
+	keys := routingKeys(httpso)
+	for i, key := range keys {
+		keys[i] = routing.BackendKey(key, backend)
+	}
+	return keys
+}
+
+// allRoutingKeys returns every routing table key that httpso
+// has entries under, including the keys of its header rules
+// and weighted backends
+func allRoutingKeys(httpso *v1alpha1.HTTPScaledObject) []string {
+	keys := routingKeys(httpso)
+	for _, rule := range httpso.Spec.HeaderRules {
+		keys = append(keys, ruleRoutingKeys(httpso, rule.Name)...)
+	}
+	for _, backend := range httpso.Spec.Backends {
+		keys = append(keys, backendRoutingKeys(httpso, backend.Name)...)
+	}
+	return keys
+}
+
+// newRoutingTarget returns the routing.Target for scaleTargetRef
+// in namespace. defaultTargetPendingReqs is used if
+// targetPendingReqs is nil
+func newRoutingTarget(
+	namespace string,
+	scaleTargetRef *v1alpha1.ScaleTargetRef,
+	targetPendingReqs *int32,
+	defaultTargetPendingReqs int32,
+) routing.Target {
+	if targetPendingReqs != nil {
+		defaultTargetPendingReqs = *targetPendingReqs
+	}
+	target := routing.NewTarget(
+		namespace,
+		scaleTargetRef.Service,
+		int(scaleTargetRef.Port),
+		scaleTargetRef.GetName(),
+		defaultTargetPendingReqs,
+	)
+	if !scaleTargetRef.IsDeployment() {
+		target.APIVersion = scaleTargetRef.GetAPIVersion()
+		target.Kind = scaleTargetRef.GetKind()
+	}
+	return target
+}
+
+// routingTargets returns the routing table entries for httpso, keyed
+// by the keys that allRoutingKeys returns. Every entry has the path
+// prefix of its key and the path rewrite, pending request limit, rate
+// limit, retry policy and readiness check of httpso applied. Only the
+// entries of the scaleTargetRef have the scaling metrics of httpso
+func routingTargets(
+	httpso *v1alpha1.HTTPScaledObject,
+	defaultTargetPendingReqs int32,
+) map[string]routing.Target {
+	target := newRoutingTarget(
+		httpso.GetNamespace(),
+		httpso.Spec.ScaleTargetRef,
+		httpso.Spec.TargetPendingRequests,
+		defaultTargetPendingReqs,
+	)
+	if httpso.Spec.PathRewrite != nil {
+		target.PathRewrite = *httpso.Spec.PathRewrite
+	}
+	if httpso.Spec.MaxPendingRequests != nil {
+		target.MaxPendingRequests = *httpso.Spec.MaxPendingRequests
+	}
+	if rp := httpso.Spec.RetryPolicy; rp != nil {
+		target.RetryPolicy = newRoutingRetryPolicy(rp)
+	}
+	target.ReadinessCheck = string(httpso.Spec.ReadinessCheck)
+	target.Metrics = newRoutingMetrics(httpso.Spec.ScalingMetrics)
+	if rl := httpso.Spec.RateLimit; rl != nil {
+		target.RateLimit = &routing.RateLimit{
+			RequestsPerSecond: rl.RequestsPerSecond,
+			Burst:             rl.RequestsPerSecond,
+		}
+		if rl.Burst != nil {
+			target.RateLimit.Burst = *rl.Burst
+		}
+	}
+	for _, rule := range httpso.Spec.HeaderRules {
+		target.HeaderRules = append(target.HeaderRules, routing.HeaderRule{
+			Name:    rule.Name,
+			Headers: rule.Headers,
+		})
+	}
+	for _, backend := range httpso.Spec.Backends {
+		target.Backends = append(target.Backends, routing.Backend{
+			Name:   backend.Name,
+			Weight: backend.Weight,
+		})
+	}
+
+	targets := make(map[string]routing.Target)
+	for _, host := range httpso.Spec.Hosts {
+		for _, prefix := range pathPrefixes(httpso) {
+			key := routing.Key(host, prefix)
+			prefixTarget := target
+			prefixTarget.PathPrefix = prefix
+			targets[key] = prefixTarget
+
+			for _, rule := range httpso.Spec.HeaderRules {
+				ruleTarget := newRoutingTarget(
+					httpso.GetNamespace(),
+					rule.ScaleTargetRef,
+					rule.TargetPendingRequests,
+					defaultTargetPendingReqs,
+				)
+				ruleTarget.PathPrefix = prefix
+				ruleTarget.PathRewrite = target.PathRewrite
+				ruleTarget.MaxPendingRequests = target.MaxPendingRequests
+				ruleTarget.RateLimit = target.RateLimit
+				ruleTarget.RetryPolicy = target.RetryPolicy
+				ruleTarget.ReadinessCheck = target.ReadinessCheck
+				targets[routing.RuleKey(key, rule.Name)] = ruleTarget
+			}
+			for _, backend := range httpso.Spec.Backends {
+				backendTarget := newRoutingTarget(
+					httpso.GetNamespace(),
+					backend.ScaleTargetRef,
+					backend.TargetPendingRequests,
+					defaultTargetPendingReqs,
+				)
+				backendTarget.PathPrefix = prefix
+				backendTarget.PathRewrite = target.PathRewrite
+				backendTarget.MaxPendingRequests = target.MaxPendingRequests
+				backendTarget.RateLimit = target.RateLimit
+				backendTarget.RetryPolicy = target.RetryPolicy
+				backendTarget.ReadinessCheck = target.ReadinessCheck
+				targets[routing.BackendKey(key, backend.Name)] = backendTarget
+			}
+		}
+	}
+	return targets
+}
+
 func removeAndUpdateRoutingTable(
 	ctx context.Context,
 	lggr logr.Logger,
 	cl client.Client,
 	table *routing.Table,
-	hosts []string,
+	keys []string,
 	namespace string,
 ) error {
 	lggr = lggr.WithName("removeAndUpdateRoutingTable")
-	for _, host := range hosts {
-		if err := table.RemoveTarget(host); err != nil {
+	for _, key := range keys {
+		if err := table.RemoveTarget(key); err != nil {
 			lggr.Error(
 				err,
 				"could not remove host from routing table, progressing anyway",
 				"host",
-				host,
+				key,
 			)
 		}
 	}
@@ -34,27 +210,58 @@ func removeAndUpdateRoutingTable(
 	return updateRoutingMap(ctx, lggr, cl, namespace, table)
 }
 
+// addAndUpdateRoutingTable adds targets to table and saves it.
+// previousKeys are the keys that the same HTTPScaledObject added
+// entries under before: their entries are replaced, and those
+// of the keys that are not in targets anymore are removed. The
+// keys of other HTTPScaledObjects are left alone.
+//
+// It returns the keys of targets that were added, sorted
 func addAndUpdateRoutingTable(
 	ctx context.Context,
 	lggr logr.Logger,
 	cl client.Client,
 	table *routing.Table,
-	hosts []string,
-	target routing.Target,
+	targets map[string]routing.Target,
+	previousKeys []string,
 	namespace string,
-) error {
+) ([]string, error) {
 	lggr = lggr.WithName("addAndUpdateRoutingTable")
-	for _, host := range hosts {
-		if err := table.AddTarget(host, target); err != nil {
+	owned := make(map[string]struct{}, len(previousKeys))
+	for _, key := range previousKeys {
+		owned[key] = struct{}{}
+		if _, ok := targets[key]; ok {
+			continue
+		}
+		if err := table.RemoveTarget(key); err != nil {
+			lggr.Error(
+				err,
+				"could not remove host from routing table, progressing anyway",
+				"host",
+				key,
+			)
+		}
+	}
+	added := make([]string, 0, len(targets))
+	for key, target := range targets {
+		if _, ok := owned[key]; ok {
+			// the entry may be gone, e.g. if the
+			// operator restarted since it was added
+			_ = table.RemoveTarget(key)
+		}
+		if err := table.AddTarget(key, target); err != nil {
 			lggr.Error(
 				err,
 				"could not add host to routing table, progressing anyway",
 				"host",
-				host,
+				key,
 			)
+			continue
 		}
+		added = append(added, key)
 	}
-	return updateRoutingMap(ctx, lggr, cl, namespace, table)
+	sort.Strings(added)
+	return added, updateRoutingMap(ctx, lggr, cl, namespace, table)
 }
 
 func updateRoutingMap(
@@ -82,3 +289,39 @@ func updateRoutingMap(
 
 	return nil
 }
+
+// newRoutingMetrics converts the scaling metrics
+// of an HTTPScaledObject to routing.Metrics
+func newRoutingMetrics(metrics []v1alpha1.ScalingMetric) []routing.Metric {
+	if len(metrics) == 0 {
+		return nil
+	}
+	ret := make([]routing.Metric, 0, len(metrics))
+	for _, metric := range metrics {
+		ret = append(ret, routing.Metric{
+			Type:        string(metric.Type),
+			TargetValue: metric.TargetValue,
+		})
+	}
+	return ret
+}
+
+// newRoutingRetryPolicy returns the routing table form of rp
+func newRoutingRetryPolicy(rp *v1alpha1.RetryPolicy) *routing.RetryPolicy {
+	ret := &routing.RetryPolicy{
+		BodyPatterns:       rp.BodyPatterns,
+		ResponseHeaders:    rp.ResponseHeaders,
+		ConnectionErrors:   rp.ConnectionErrors,
+		RetryNonIdempotent: rp.RetryNonIdempotent,
+	}
+	for _, code := range rp.StatusCodes {
+		ret.StatusCodes = append(ret.StatusCodes, int(code))
+	}
+	if rp.MaxAttempts != nil {
+		ret.MaxAttempts = int(*rp.MaxAttempts)
+	}
+	if rp.PerTryTimeout != nil {
+		ret.PerTryTimeout = rp.PerTryTimeout.Duration
+	}
+	return ret
+}
@@ -3,12 +3,15 @@ package http
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/kedacore/http-add-on/operator/apis/http/v1alpha1"
 	"github.com/kedacore/http-add-on/pkg/k8s"
 	"github.com/kedacore/http-add-on/pkg/routing"
 )
@@ -44,15 +47,17 @@ func TestRoutingTable(t *testing.T) {
 		deplName,
 		1234,
 	)
-	r.NoError(addAndUpdateRoutingTable(
+	keys, err := addAndUpdateRoutingTable(
 		ctx,
 		logr.Discard(),
 		cl,
 		table,
-		hosts,
-		target,
+		map[string]routing.Target{hosts[0]: target},
+		nil,
 		ns,
-	))
+	)
+	r.NoError(err)
+	r.Equal(hosts, keys)
 	// ensure that the ConfigMap was read and created. no updates
 	// should occur
 	r.Equal(1, len(cl.FakeRuntimeClientReader.GetCalls))
@@ -87,3 +92,432 @@ func TestRoutingTable(t *testing.T) {
 		r.Error(err)
 	}
 }
+
+// updating an HTTPScaledObject should replace the entries of its keys,
+// remove those of the keys that it no longer declares, and leave the
+// keys of other HTTPScaledObjects alone
+func TestRoutingTableUpdate(t *testing.T) {
+	r := require.New(t)
+	const ns = "testns"
+	table := routing.NewTable()
+	ctx := context.Background()
+	cl := k8s.NewFakeRuntimeClient()
+	cm := &corev1.ConfigMap{
+		Data: map[string]string{},
+	}
+	r.NoError(routing.SaveTableToConfigMap(table, cm))
+	cl.GetFunc = func() client.Object {
+		return cm
+	}
+	other := routing.NewTarget(ns, "othersvc", 8080, "otherdepl", 100)
+	r.NoError(table.AddTarget("other.com", other))
+
+	httpso := &v1alpha1.HTTPScaledObject{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "testapp"},
+		Spec: v1alpha1.HTTPScaledObjectSpec{
+			Hosts: []string{"myhost.com"},
+			ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+				Name:    "testdepl",
+				Service: "testsvc",
+				Port:    8080,
+			},
+			HeaderRules: []v1alpha1.HeaderRoutingRule{
+				{
+					Name:    "tenant-a",
+					Headers: map[string]string{"X-Tenant": "a"},
+					ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+						Name:    "testdepl-a",
+						Service: "testsvc-a",
+						Port:    8080,
+					},
+				},
+			},
+		},
+	}
+	keys, err := addAndUpdateRoutingTable(ctx, logr.Discard(), cl, table, routingTargets(httpso, 100), nil, ns)
+	r.NoError(err)
+	r.Equal([]string{"myhost.com", "myhost.com#tenant-a"}, keys)
+
+	// swap the header rule for a weighted backend, and
+	// try to take over the host of another HTTPScaledObject
+	httpso.Spec.Hosts = []string{"myhost.com", "other.com"}
+	httpso.Spec.HeaderRules = nil
+	httpso.Spec.Backends = []v1alpha1.WeightedBackend{
+		{
+			Name:   "canary",
+			Weight: 20,
+			ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+				Name:    "testdepl-canary",
+				Service: "testsvc-canary",
+				Port:    8080,
+			},
+		},
+	}
+	keys, err = addAndUpdateRoutingTable(ctx, logr.Discard(), cl, table, routingTargets(httpso, 100), keys, ns)
+	r.NoError(err)
+	// other.com is left to the other HTTPScaledObject
+	r.Equal([]string{"myhost.com", "myhost.com@canary", "other.com@canary"}, keys)
+
+	target, err := table.Lookup("myhost.com")
+	r.NoError(err)
+	r.Equal([]routing.Backend{{Name: "canary", Weight: 20}}, target.Backends)
+	r.Empty(target.HeaderRules)
+	_, err = table.Lookup("myhost.com#tenant-a")
+	r.Error(err)
+	_, err = table.Lookup("myhost.com@canary")
+	r.NoError(err)
+	otherTarget, err := table.Lookup("other.com")
+	r.NoError(err)
+	r.Equal(other, *otherTarget)
+}
+
+func TestRoutingTargetsPathPrefixes(t *testing.T) {
+	r := require.New(t)
+	rewrite := "/"
+	httpso := &v1alpha1.HTTPScaledObject{
+		Spec: v1alpha1.HTTPScaledObjectSpec{
+			Hosts:        []string{"api.example.com", "api.example.org"},
+			PathPrefixes: []string{"/orders", "/billing/"},
+			PathRewrite:  &rewrite,
+			ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+				Deployment: "testdepl",
+				Service:    "testsvc",
+				Port:       8080,
+			},
+		},
+	}
+	httpso.Namespace = "testns"
+	target := routing.NewTarget("testns", "testsvc", 8080, "testdepl", 100)
+
+	keys := routingKeys(httpso)
+	r.Equal([]string{
+		"api.example.com/orders",
+		"api.example.com/billing",
+		"api.example.org/orders",
+		"api.example.org/billing",
+	}, keys)
+
+	targets := routingTargets(httpso, 100)
+	r.Len(targets, len(keys))
+	for _, key := range keys {
+		r.Contains(targets, key)
+	}
+	ordersTarget := targets["api.example.com/orders"]
+	r.Equal("/orders", ordersTarget.PathPrefix)
+	r.Equal("/", ordersTarget.PathRewrite)
+	r.Equal(target.Service, ordersTarget.Service)
+
+	// without path prefixes, the hosts are the keys
+	httpso.Spec.PathPrefixes = nil
+	httpso.Spec.PathRewrite = nil
+	r.Equal(httpso.Spec.Hosts, routingKeys(httpso))
+	targets = routingTargets(httpso, 100)
+	r.Equal(map[string]routing.Target{
+		"api.example.com": target,
+		"api.example.org": target,
+	}, targets)
+}
+
+func TestRoutingTargetsHeaderRules(t *testing.T) {
+	r := require.New(t)
+	tenantTPR := int32(10)
+	httpso := &v1alpha1.HTTPScaledObject{
+		Spec: v1alpha1.HTTPScaledObjectSpec{
+			Hosts: []string{"api.example.com"},
+			ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+				Deployment: "testdepl",
+				Service:    "testsvc",
+				Port:       8080,
+			},
+			HeaderRules: []v1alpha1.HeaderRoutingRule{
+				{
+					Name:    "tenant-a",
+					Headers: map[string]string{"X-Tenant": "a"},
+					ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+						Deployment: "testdepl-a",
+						Service:    "testsvc-a",
+						Port:       8080,
+					},
+					TargetPendingRequests: &tenantTPR,
+				},
+			},
+		},
+	}
+	httpso.Namespace = "testns"
+
+	r.Equal([]string{"api.example.com#tenant-a"}, ruleRoutingKeys(httpso, "tenant-a"))
+	r.Equal([]string{"api.example.com", "api.example.com#tenant-a"}, allRoutingKeys(httpso))
+
+	targets := routingTargets(httpso, 100)
+	r.Len(targets, 2)
+	hostTarget := targets["api.example.com"]
+	r.Equal("testsvc", hostTarget.Service)
+	r.Equal([]routing.HeaderRule{
+		{Name: "tenant-a", Headers: map[string]string{"X-Tenant": "a"}},
+	}, hostTarget.HeaderRules)
+	r.Equal(
+		routing.NewTarget("testns", "testsvc-a", 8080, "testdepl-a", tenantTPR),
+		targets["api.example.com#tenant-a"],
+	)
+}
+
+func TestRoutingTargetsBackends(t *testing.T) {
+	r := require.New(t)
+	httpso := &v1alpha1.HTTPScaledObject{
+		Spec: v1alpha1.HTTPScaledObjectSpec{
+			Hosts: []string{"api.example.com"},
+			ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+				Deployment: "testdepl",
+				Service:    "testsvc",
+				Port:       8080,
+			},
+			Backends: []v1alpha1.WeightedBackend{
+				{
+					Name: "canary",
+					ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+						Deployment: "testdepl-canary",
+						Service:    "testsvc-canary",
+						Port:       8080,
+					},
+					Weight: 10,
+				},
+			},
+		},
+	}
+	httpso.Namespace = "testns"
+
+	r.Equal([]string{"api.example.com@canary"}, backendRoutingKeys(httpso, "canary"))
+	r.Equal([]string{"api.example.com", "api.example.com@canary"}, allRoutingKeys(httpso))
+
+	targets := routingTargets(httpso, 100)
+	r.Len(targets, 2)
+	r.Equal(
+		[]routing.Backend{{Name: "canary", Weight: 10}},
+		targets["api.example.com"].Backends,
+	)
+	r.Equal(
+		routing.NewTarget("testns", "testsvc-canary", 8080, "testdepl-canary", 100),
+		targets["api.example.com@canary"],
+	)
+}
+
+func TestRoutingTargetsMaxPendingRequests(t *testing.T) {
+	r := require.New(t)
+	maxPending := int32(20)
+	httpso := &v1alpha1.HTTPScaledObject{
+		Spec: v1alpha1.HTTPScaledObjectSpec{
+			Hosts: []string{"api.example.com"},
+			ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+				Deployment: "testdepl",
+				Service:    "testsvc",
+				Port:       8080,
+			},
+			Backends: []v1alpha1.WeightedBackend{
+				{
+					Name: "canary",
+					ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+						Deployment: "testdepl-canary",
+						Service:    "testsvc-canary",
+						Port:       8080,
+					},
+					Weight: 10,
+				},
+			},
+			MaxPendingRequests: &maxPending,
+		},
+	}
+	httpso.Namespace = "testns"
+
+	targets := routingTargets(httpso, 100)
+	r.Len(targets, 2)
+	for key, target := range targets {
+		r.Equalf(maxPending, target.MaxPendingRequests, "target %s", key)
+	}
+}
+
+func TestRoutingTargetsRateLimit(t *testing.T) {
+	r := require.New(t)
+	burst := int32(50)
+	httpso := &v1alpha1.HTTPScaledObject{
+		Spec: v1alpha1.HTTPScaledObjectSpec{
+			Hosts: []string{"api.example.com"},
+			ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+				Deployment: "testdepl",
+				Service:    "testsvc",
+				Port:       8080,
+			},
+			RateLimit: &v1alpha1.RateLimit{
+				RequestsPerSecond: 10,
+			},
+		},
+	}
+	httpso.Namespace = "testns"
+
+	// the burst defaults to the rate
+	targets := routingTargets(httpso, 100)
+	r.Equal(
+		&routing.RateLimit{RequestsPerSecond: 10, Burst: 10},
+		targets["api.example.com"].RateLimit,
+	)
+
+	httpso.Spec.RateLimit.Burst = &burst
+	targets = routingTargets(httpso, 100)
+	r.Equal(
+		&routing.RateLimit{RequestsPerSecond: 10, Burst: 50},
+		targets["api.example.com"].RateLimit,
+	)
+}
+
+func TestRoutingTargetsReadinessCheck(t *testing.T) {
+	r := require.New(t)
+	httpso := &v1alpha1.HTTPScaledObject{
+		Spec: v1alpha1.HTTPScaledObjectSpec{
+			Hosts: []string{"api.example.com"},
+			ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+				Deployment: "testdepl",
+				Service:    "testsvc",
+				Port:       8080,
+			},
+			Backends: []v1alpha1.WeightedBackend{
+				{
+					Name: "canary",
+					ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+						Deployment: "canarydepl",
+						Service:    "canarysvc",
+						Port:       8080,
+					},
+					Weight: 10,
+				},
+			},
+			ReadinessCheck: v1alpha1.ReadinessCheckEndpoints,
+		},
+	}
+	httpso.Namespace = "testns"
+
+	targets := routingTargets(httpso, 100)
+	r.Equal(routing.ReadinessCheckEndpoints, targets["api.example.com"].ReadinessCheck)
+	r.Equal(
+		routing.ReadinessCheckEndpoints,
+		targets[routing.BackendKey("api.example.com", "canary")].ReadinessCheck,
+	)
+}
+
+func TestRoutingTargetsScaleTargetKind(t *testing.T) {
+	r := require.New(t)
+	httpso := &v1alpha1.HTTPScaledObject{
+		Spec: v1alpha1.HTTPScaledObjectSpec{
+			Hosts: []string{"api.example.com"},
+			ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+				Deployment: "testdepl",
+				Service:    "testsvc",
+				Port:       8080,
+			},
+			HeaderRules: []v1alpha1.HeaderRoutingRule{
+				{
+					Name:    "beta",
+					Headers: map[string]string{"X-Beta": "true"},
+					ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+						Kind:    "StatefulSet",
+						Name:    "betasts",
+						Service: "betasvc",
+						Port:    8080,
+					},
+				},
+			},
+		},
+	}
+	httpso.Namespace = "testns"
+
+	targets := routingTargets(httpso, 100)
+	// Deployments keep the routing table entries they had
+	// before other kinds of workloads were supported
+	target := targets["api.example.com"]
+	r.Equal("testdepl", target.Deployment)
+	r.Empty(target.APIVersion)
+	r.Empty(target.Kind)
+
+	ruleTarget := targets[routing.RuleKey("api.example.com", "beta")]
+	r.Equal("betasts", ruleTarget.Deployment)
+	r.Equal("apps/v1", ruleTarget.APIVersion)
+	r.Equal("StatefulSet", ruleTarget.Kind)
+}
+
+func TestNewRoutingRetryPolicy(t *testing.T) {
+	r := require.New(t)
+	maxAttempts := int32(4)
+	rp := &v1alpha1.RetryPolicy{
+		StatusCodes:        []int32{502, 503},
+		BodyPatterns:       []string{"^upstream connect error"},
+		ResponseHeaders:    []string{"x-envoy-overloaded"},
+		ConnectionErrors:   true,
+		MaxAttempts:        &maxAttempts,
+		PerTryTimeout:      &metav1.Duration{Duration: 2 * time.Second},
+		RetryNonIdempotent: true,
+	}
+	r.Equal(
+		&routing.RetryPolicy{
+			StatusCodes:        []int{502, 503},
+			BodyPatterns:       []string{"^upstream connect error"},
+			ResponseHeaders:    []string{"x-envoy-overloaded"},
+			ConnectionErrors:   true,
+			MaxAttempts:        4,
+			PerTryTimeout:      2 * time.Second,
+			RetryNonIdempotent: true,
+		},
+		newRoutingRetryPolicy(rp),
+	)
+	r.Equal(&routing.RetryPolicy{}, newRoutingRetryPolicy(&v1alpha1.RetryPolicy{}))
+}
+
+func TestRoutingTargetsScalingMetrics(t *testing.T) {
+	r := require.New(t)
+	targetPendingRequests := int32(50)
+	httpso := &v1alpha1.HTTPScaledObject{
+		Spec: v1alpha1.HTTPScaledObjectSpec{
+			Hosts: []string{"api.example.com"},
+			ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+				Deployment: "testdepl",
+				Service:    "testsvc",
+				Port:       8080,
+			},
+			TargetPendingRequests: &targetPendingRequests,
+			ScalingMetrics: []v1alpha1.ScalingMetric{
+				{Type: v1alpha1.ScalingMetricConcurrency, TargetValue: 10},
+				{Type: v1alpha1.ScalingMetricRate, TargetValue: 20},
+			},
+			HeaderRules: []v1alpha1.HeaderRoutingRule{
+				{
+					Name:    "beta",
+					Headers: map[string]string{"X-Beta": "true"},
+					ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+						Deployment: "betadepl",
+						Service:    "betasvc",
+						Port:       8080,
+					},
+				},
+			},
+		},
+	}
+	httpso.Namespace = "testns"
+
+	targets := routingTargets(httpso, 100)
+	target := targets["api.example.com"]
+	r.Equal(
+		[]routing.Metric{
+			{Type: routing.ScalingMetricConcurrency, TargetValue: 10},
+			{Type: routing.ScalingMetricRate, TargetValue: 20},
+		},
+		target.Metrics,
+	)
+	r.EqualValues(50, target.TargetPendingRequests)
+
+	// header rules keep scaling by their pending requests
+	ruleTarget := targets[routing.RuleKey("api.example.com", "beta")]
+	r.Empty(ruleTarget.Metrics)
+	r.EqualValues(100, ruleTarget.TargetPendingRequests)
+
+	// without scaling metrics, the target is scaled by its pending requests
+	httpso.Spec.ScalingMetrics = nil
+	target = routingTargets(httpso, 100)["api.example.com"]
+	r.Empty(target.Metrics)
+}
@@ -11,13 +11,22 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/kedacore/http-add-on/operator/apis/http/v1alpha1"
+	"github.com/kedacore/http-add-on/operator/controllers/http/config"
 	"github.com/kedacore/http-add-on/pkg/k8s"
 )
 
+// httpScaledObjectLabel is the label of the ScaledObjects of
+// an HTTPScaledObject, whose value is its name
+const httpScaledObjectLabel = "http.keda.sh/httpscaledobject"
+
 // createOrUpdateScaledObject attempts to create a new ScaledObject
-// according to the given parameters. If the create failed because the
-// ScaledObject already exists, attempts to patch the scaledobject.
-// otherwise, fails.
+// according to the given parameters, plus one for every header rule
+// and weighted backend of httpso. If the create failed because the ScaledObject already
+// exists, attempts to patch the scaledobject. otherwise, fails.
+//
+// The ScaledObjects are labelled with the name of httpso, so that
+// those of the header rules and weighted backends that it no longer
+// declares can be found and deleted.
 func createOrUpdateScaledObject(
 	ctx context.Context,
 	cl client.Client,
@@ -34,40 +43,58 @@ func createOrUpdateScaledObject(
 		maxReplicaCount = replicas.Max
 	}
 
-	appScaledObject := k8s.NewScaledObject(
-		httpso.GetNamespace(),
-		fmt.Sprintf("%s-app", httpso.GetName()), // HTTPScaledObject name is the same as the ScaledObject name
-		httpso.Spec.ScaleTargetRef.Deployment,
-		externalScalerHostName,
-		httpso.Spec.Hosts,
-		minReplicaCount,
-		maxReplicaCount,
-		httpso.Spec.CooldownPeriod,
-	)
+	appScaledObjects := []*kedav1alpha1.ScaledObject{
+		k8s.NewScaledObject(
+			httpso.GetNamespace(),
+			fmt.Sprintf("%s-app", httpso.GetName()), // HTTPScaledObject name is the same as the ScaledObject name
+			httpso.Spec.ScaleTargetRef.GetAPIVersion(),
+			httpso.Spec.ScaleTargetRef.GetKind(),
+			httpso.Spec.ScaleTargetRef.GetName(),
+			externalScalerHostName,
+			routingKeys(httpso),
+			minReplicaCount,
+			maxReplicaCount,
+			httpso.Spec.CooldownPeriod,
+		),
+	}
+	// every header rule routes to a scale target of its own, which
+	// is scaled by the pending requests of the rule only
+	for _, rule := range httpso.Spec.HeaderRules {
+		appScaledObjects = append(appScaledObjects, k8s.NewScaledObject(
+			httpso.GetNamespace(),
+			config.AdditionalScaledObjectName(httpso, rule.Name),
+			rule.ScaleTargetRef.GetAPIVersion(),
+			rule.ScaleTargetRef.GetKind(),
+			rule.ScaleTargetRef.GetName(),
+			externalScalerHostName,
+			ruleRoutingKeys(httpso, rule.Name),
+			minReplicaCount,
+			maxReplicaCount,
+			httpso.Spec.CooldownPeriod,
+		))
+	}
+	// the same goes for every weighted backend
+	for _, backend := range httpso.Spec.Backends {
+		appScaledObjects = append(appScaledObjects, k8s.NewScaledObject(
+			httpso.GetNamespace(),
+			config.AdditionalScaledObjectName(httpso, backend.Name),
+			backend.ScaleTargetRef.GetAPIVersion(),
+			backend.ScaleTargetRef.GetKind(),
+			backend.ScaleTargetRef.GetName(),
+			externalScalerHostName,
+			backendRoutingKeys(httpso, backend.Name),
+			minReplicaCount,
+			maxReplicaCount,
+			httpso.Spec.CooldownPeriod,
+		))
+	}
 
-	logger.Info("Creating App ScaledObject", "ScaledObject", *appScaledObject)
-	if err := cl.Create(ctx, appScaledObject); err != nil {
-		if errors.IsAlreadyExists(err) {
-			existingSOKey := client.ObjectKey{
-				Namespace: httpso.GetNamespace(),
-				Name:      appScaledObject.GetName(),
-			}
-			var fetchedSO kedav1alpha1.ScaledObject
-			if err := cl.Get(ctx, existingSOKey, &fetchedSO); err != nil {
-				logger.Error(
-					err,
-					"failed to fetch existing ScaledObject for patching",
-				)
-				return err
-			}
-			if err := cl.Patch(ctx, appScaledObject, client.Merge); err != nil {
-				logger.Error(
-					err,
-					"failed to patch existing ScaledObject",
-				)
-				return err
-			}
-		} else {
+	keep := make(map[string]struct{}, len(appScaledObjects))
+	for _, appScaledObject := range appScaledObjects {
+		appScaledObject.Labels[httpScaledObjectLabel] = httpso.GetName()
+		keep[appScaledObject.GetName()] = struct{}{}
+		logger.Info("Creating App ScaledObject", "ScaledObject", *appScaledObject)
+		if err := createOrPatchScaledObject(ctx, cl, logger, appScaledObject); err != nil {
 			AddCondition(
 				httpso,
 				*SetMessage(
@@ -85,6 +112,24 @@ func createOrUpdateScaledObject(
 		}
 	}
 
+	// delete the ScaledObjects of the header rules and
+	// weighted backends that httpso no longer declares
+	if err := pruneScaledObjects(ctx, cl, logger, httpso, keep); err != nil {
+		AddCondition(
+			httpso,
+			*SetMessage(
+				CreateCondition(
+					v1alpha1.Error,
+					v1.ConditionFalse,
+					v1alpha1.ErrorCreatingAppScaledObject,
+				),
+				err.Error(),
+			),
+		)
+		logger.Error(err, "Pruning ScaledObjects")
+		return err
+	}
+
 	AddCondition(
 		httpso,
 		*SetMessage(
@@ -99,3 +144,86 @@ func createOrUpdateScaledObject(
 
 	return nil
 }
+
+// pruneScaledObjects deletes the ScaledObjects that are labelled as
+// those of httpso, but that are not named in keep
+func pruneScaledObjects(
+	ctx context.Context,
+	cl client.Client,
+	logger logr.Logger,
+	httpso *v1alpha1.HTTPScaledObject,
+	keep map[string]struct{},
+) error {
+	var scaledObjects kedav1alpha1.ScaledObjectList
+	if err := cl.List(
+		ctx,
+		&scaledObjects,
+		client.InNamespace(httpso.GetNamespace()),
+		client.MatchingLabels{httpScaledObjectLabel: httpso.GetName()},
+	); err != nil {
+		logger.Error(err, "failed to list the ScaledObjects of the HTTPScaledObject")
+		return err
+	}
+	for i := range scaledObjects.Items {
+		scaledObject := &scaledObjects.Items[i]
+		if _, ok := keep[scaledObject.GetName()]; ok {
+			continue
+		}
+		logger.Info("Deleting App ScaledObject that is no longer declared", "ScaledObject", scaledObject.GetName())
+		if err := cl.Delete(ctx, scaledObject); err != nil && !errors.IsNotFound(err) {
+			logger.Error(err, "failed to delete App ScaledObject", "ScaledObject", scaledObject.GetName())
+			return err
+		}
+	}
+	return nil
+}
+
+// createOrPatchScaledObject creates scaledObject, or patches it if
+// it already exists. It fails if the existing ScaledObject is labelled
+// as that of another HTTPScaledObject than scaledObject, so that the
+// ScaledObjects of HTTPScaledObjects whose names collide are not
+// taken over
+func createOrPatchScaledObject(
+	ctx context.Context,
+	cl client.Client,
+	logger logr.Logger,
+	scaledObject *kedav1alpha1.ScaledObject,
+) error {
+	err := cl.Create(ctx, scaledObject)
+	if !errors.IsAlreadyExists(err) {
+		return err
+	}
+	existingSOKey := client.ObjectKey{
+		Namespace: scaledObject.GetNamespace(),
+		Name:      scaledObject.GetName(),
+	}
+	var fetchedSO kedav1alpha1.ScaledObject
+	if err := cl.Get(ctx, existingSOKey, &fetchedSO); err != nil {
+		logger.Error(
+			err,
+			"failed to fetch existing ScaledObject for patching",
+		)
+		return err
+	}
+	owner := scaledObject.GetLabels()[httpScaledObjectLabel]
+	if fetchedOwner := fetchedSO.GetLabels()[httpScaledObjectLabel]; fetchedOwner != "" && fetchedOwner != owner {
+		err := fmt.Errorf(
+			"ScaledObject %s belongs to HTTPScaledObject %s",
+			scaledObject.GetName(),
+			fetchedOwner,
+		)
+		logger.Error(
+			err,
+			"ScaledObject name collides with that of another HTTPScaledObject",
+		)
+		return err
+	}
+	if err := cl.Patch(ctx, scaledObject, client.Merge); err != nil {
+		logger.Error(
+			err,
+			"failed to patch existing ScaledObject",
+		)
+		return err
+	}
+	return nil
+}
@@ -115,6 +115,202 @@ func TestCreateOrUpdateScaledObject(t *testing.T) {
 	)
 }
 
+func TestCreateOrUpdateScaledObjectHeaderRules(t *testing.T) {
+	r := require.New(t)
+	const externalScalerHostName = "mysvc.myns.svc.cluster.local:9090"
+
+	testInfra := newCommonTestInfra("testns", "testapp")
+	testInfra.httpso.Spec.HeaderRules = []v1alpha1.HeaderRoutingRule{
+		{
+			Name:    "tenant-a",
+			Headers: map[string]string{"X-Tenant": "a"},
+			ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+				Deployment: "testapp-a",
+				Service:    "testapp-a",
+				Port:       8081,
+			},
+		},
+	}
+	r.NoError(createOrUpdateScaledObject(
+		testInfra.ctx,
+		testInfra.cl,
+		testInfra.logger,
+		externalScalerHostName,
+		&testInfra.httpso,
+	))
+
+	var ruleSO kedav1alpha1.ScaledObject
+	r.NoError(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+		Namespace: testInfra.ns,
+		Name:      config.AdditionalScaledObjectName(&testInfra.httpso, "tenant-a"),
+	}, &ruleSO))
+	r.Equal("testapp-a", ruleSO.Spec.ScaleTargetRef.Name)
+	r.Len(ruleSO.Spec.Triggers, 1)
+	r.Equal(
+		"myhost1.com#tenant-a,myhost2.com#tenant-a",
+		ruleSO.Spec.Triggers[0].Metadata["hosts"],
+	)
+
+	// the ScaledObject of the scaleTargetRef only scales on
+	// requests that matched no rule
+	appSO, err := getSO(
+		testInfra.ctx,
+		testInfra.cl,
+		testInfra.httpso,
+	)
+	r.NoError(err)
+	r.Equal(
+		"myhost1.com,myhost2.com",
+		appSO.Spec.Triggers[0].Metadata["hosts"],
+	)
+}
+
+// updating an HTTPScaledObject should delete the ScaledObjects of
+// the header rules that it no longer declares
+func TestCreateOrUpdateScaledObjectUpdate(t *testing.T) {
+	r := require.New(t)
+	const externalScalerHostName = "mysvc.myns.svc.cluster.local:9090"
+
+	testInfra := newCommonTestInfra("testns", "testapp")
+	newRule := func(name string) v1alpha1.HeaderRoutingRule {
+		return v1alpha1.HeaderRoutingRule{
+			Name:    name,
+			Headers: map[string]string{"X-Tenant": name},
+			ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+				Deployment: "testapp-" + name,
+				Service:    "testapp-" + name,
+				Port:       8081,
+			},
+		}
+	}
+	testInfra.httpso.Spec.HeaderRules = []v1alpha1.HeaderRoutingRule{newRule("tenant-a")}
+	r.NoError(createOrUpdateScaledObject(
+		testInfra.ctx,
+		testInfra.cl,
+		testInfra.logger,
+		externalScalerHostName,
+		&testInfra.httpso,
+	))
+
+	testInfra.httpso.Spec.HeaderRules = []v1alpha1.HeaderRoutingRule{newRule("tenant-b")}
+	r.NoError(createOrUpdateScaledObject(
+		testInfra.ctx,
+		testInfra.cl,
+		testInfra.logger,
+		externalScalerHostName,
+		&testInfra.httpso,
+	))
+
+	var scaledObjects kedav1alpha1.ScaledObjectList
+	r.NoError(testInfra.cl.List(testInfra.ctx, &scaledObjects, client.InNamespace(testInfra.ns)))
+	names := []string{}
+	for _, so := range scaledObjects.Items {
+		r.Equal(testInfra.httpso.GetName(), so.Labels[httpScaledObjectLabel])
+		names = append(names, so.GetName())
+	}
+	r.ElementsMatch(
+		[]string{
+			"testapp-app",
+			config.AdditionalScaledObjectName(&testInfra.httpso, "tenant-b"),
+		},
+		names,
+	)
+}
+
+// an HTTPScaledObject should not take over the ScaledObject of
+// another one whose ScaledObject name collides with its own
+func TestCreateOrUpdateScaledObjectNameCollision(t *testing.T) {
+	r := require.New(t)
+	const externalScalerHostName = "mysvc.myns.svc.cluster.local:9090"
+
+	newRule := func(name, deployment string) v1alpha1.HeaderRoutingRule {
+		return v1alpha1.HeaderRoutingRule{
+			Name:    name,
+			Headers: map[string]string{"X-Tenant": name},
+			ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+				Deployment: deployment,
+				Service:    deployment,
+				Port:       8081,
+			},
+		}
+	}
+	testInfra := newCommonTestInfra("testns", "foo")
+	testInfra.httpso.Spec.HeaderRules = []v1alpha1.HeaderRoutingRule{
+		newRule("bar-baz", "foo-bar-baz"),
+	}
+	r.NoError(createOrUpdateScaledObject(
+		testInfra.ctx,
+		testInfra.cl,
+		testInfra.logger,
+		externalScalerHostName,
+		&testInfra.httpso,
+	))
+
+	// the rule "baz" of "foo-bar" is named like
+	// the rule "bar-baz" of "foo"
+	other := testInfra.httpso.DeepCopy()
+	other.SetName("foo-bar")
+	other.Spec.HeaderRules = []v1alpha1.HeaderRoutingRule{
+		newRule("baz", "other"),
+	}
+	r.Equal(
+		config.AdditionalScaledObjectName(&testInfra.httpso, "bar-baz"),
+		config.AdditionalScaledObjectName(other, "baz"),
+	)
+	r.Error(createOrUpdateScaledObject(
+		testInfra.ctx,
+		testInfra.cl,
+		testInfra.logger,
+		externalScalerHostName,
+		other,
+	))
+
+	var ruleSO kedav1alpha1.ScaledObject
+	r.NoError(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+		Namespace: testInfra.ns,
+		Name:      config.AdditionalScaledObjectName(&testInfra.httpso, "bar-baz"),
+	}, &ruleSO))
+	r.Equal("foo-bar-baz", ruleSO.Spec.ScaleTargetRef.Name)
+	r.Equal("foo", ruleSO.Labels[httpScaledObjectLabel])
+}
+
+func TestCreateOrUpdateScaledObjectBackends(t *testing.T) {
+	r := require.New(t)
+	const externalScalerHostName = "mysvc.myns.svc.cluster.local:9090"
+
+	testInfra := newCommonTestInfra("testns", "testapp")
+	testInfra.httpso.Spec.Backends = []v1alpha1.WeightedBackend{
+		{
+			Name: "canary",
+			ScaleTargetRef: &v1alpha1.ScaleTargetRef{
+				Deployment: "testapp-canary",
+				Service:    "testapp-canary",
+				Port:       8081,
+			},
+			Weight: 20,
+		},
+	}
+	r.NoError(createOrUpdateScaledObject(
+		testInfra.ctx,
+		testInfra.cl,
+		testInfra.logger,
+		externalScalerHostName,
+		&testInfra.httpso,
+	))
+
+	var backendSO kedav1alpha1.ScaledObject
+	r.NoError(testInfra.cl.Get(testInfra.ctx, client.ObjectKey{
+		Namespace: testInfra.ns,
+		Name:      config.AdditionalScaledObjectName(&testInfra.httpso, "canary"),
+	}, &backendSO))
+	r.Equal("testapp-canary", backendSO.Spec.ScaleTargetRef.Name)
+	r.Len(backendSO.Spec.Triggers, 1)
+	r.Equal(
+		"myhost1.com@canary,myhost2.com@canary",
+		backendSO.Spec.Triggers[0].Metadata["hosts"],
+	)
+}
+
 func getSO(
 	ctx context.Context,
 	cl client.Client,
@@ -127,3 +323,50 @@ func getSO(
 	}, &retSO)
 	return &retSO, err
 }
+
+func TestCreateOrUpdateScaledObjectScaleTargetKind(t *testing.T) {
+	r := require.New(t)
+	const externalScalerHostName = "mysvc.myns.svc.cluster.local:9090"
+
+	testInfra := newCommonTestInfra("testns", "testapp")
+	r.NoError(createOrUpdateScaledObject(
+		testInfra.ctx,
+		testInfra.cl,
+		testInfra.logger,
+		externalScalerHostName,
+		&testInfra.httpso,
+	))
+	appSO, err := getSO(
+		testInfra.ctx,
+		testInfra.cl,
+		testInfra.httpso,
+	)
+	r.NoError(err)
+	r.Equal("apps/v1", appSO.Spec.ScaleTargetRef.APIVersion)
+	r.Equal("Deployment", appSO.Spec.ScaleTargetRef.Kind)
+	r.Equal("testapp", appSO.Spec.ScaleTargetRef.Name)
+
+	testInfra.httpso.Spec.ScaleTargetRef = &v1alpha1.ScaleTargetRef{
+		APIVersion: "argoproj.io/v1alpha1",
+		Kind:       "Rollout",
+		Name:       "testapp",
+		Service:    "testapp",
+		Port:       8081,
+	}
+	r.NoError(createOrUpdateScaledObject(
+		testInfra.ctx,
+		testInfra.cl,
+		testInfra.logger,
+		externalScalerHostName,
+		&testInfra.httpso,
+	))
+	appSO, err = getSO(
+		testInfra.ctx,
+		testInfra.cl,
+		testInfra.httpso,
+	)
+	r.NoError(err)
+	r.Equal("argoproj.io/v1alpha1", appSO.Spec.ScaleTargetRef.APIVersion)
+	r.Equal("Rollout", appSO.Spec.ScaleTargetRef.Kind)
+	r.Equal("testapp", appSO.Spec.ScaleTargetRef.Name)
+}
@@ -28,6 +28,10 @@ type FakeHttpV1alpha1 struct {
 	*testing.Fake
 }
 
+func (c *FakeHttpV1alpha1) HTTPAccessPolicies(namespace string) v1alpha1.HTTPAccessPolicyInterface {
+	return &FakeHTTPAccessPolicies{c, namespace}
+}
+
 func (c *FakeHttpV1alpha1) HTTPScaledObjects(namespace string) v1alpha1.HTTPScaledObjectInterface {
 	return &FakeHTTPScaledObjects{c, namespace}
 }
@@ -28,6 +28,7 @@ import (
 
 type HttpV1alpha1Interface interface {
 	RESTClient() rest.Interface
+	HTTPAccessPoliciesGetter
 	HTTPScaledObjectsGetter
 }
 
@@ -36,6 +37,10 @@ type HttpV1alpha1Client struct {
 	restClient rest.Interface
 }
 
+func (c *HttpV1alpha1Client) HTTPAccessPolicies(namespace string) HTTPAccessPolicyInterface {
+	return newHTTPAccessPolicies(c, namespace)
+}
+
 func (c *HttpV1alpha1Client) HTTPScaledObjects(namespace string) HTTPScaledObjectInterface {
 	return newHTTPScaledObjects(c, namespace)
 }
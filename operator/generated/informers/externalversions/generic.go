@@ -53,6 +53,8 @@ func (f *genericInformer) Lister() cache.GenericLister {
 func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource) (GenericInformer, error) {
 	switch resource {
 	// Group=http, Version=v1alpha1
+	case v1alpha1.SchemeGroupVersion.WithResource("httpaccesspolicies"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Http().V1alpha1().HTTPAccessPolicies().Informer()}, nil
 	case v1alpha1.SchemeGroupVersion.WithResource("httpscaledobjects"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Http().V1alpha1().HTTPScaledObjects().Informer()}, nil
 
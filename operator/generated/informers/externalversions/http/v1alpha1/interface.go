@@ -24,6 +24,8 @@ import (
 
 // Interface provides access to all the informers in this group version.
 type Interface interface {
+	// HTTPAccessPolicies returns a HTTPAccessPolicyInformer.
+	HTTPAccessPolicies() HTTPAccessPolicyInformer
 	// HTTPScaledObjects returns a HTTPScaledObjectInformer.
 	HTTPScaledObjects() HTTPScaledObjectInformer
 }
@@ -39,6 +41,11 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
 }
 
+// HTTPAccessPolicies returns a HTTPAccessPolicyInformer.
+func (v *version) HTTPAccessPolicies() HTTPAccessPolicyInformer {
+	return &hTTPAccessPolicyInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // HTTPScaledObjects returns a HTTPScaledObjectInformer.
 func (v *version) HTTPScaledObjects() HTTPScaledObjectInformer {
 	return &hTTPScaledObjectInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
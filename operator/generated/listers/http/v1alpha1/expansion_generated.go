@@ -18,6 +18,14 @@ limitations under the License.
 
 package v1alpha1
 
+// HTTPAccessPolicyListerExpansion allows custom methods to be added to
+// HTTPAccessPolicyLister.
+type HTTPAccessPolicyListerExpansion interface{}
+
+// HTTPAccessPolicyNamespaceListerExpansion allows custom methods to be added to
+// HTTPAccessPolicyNamespaceLister.
+type HTTPAccessPolicyNamespaceListerExpansion interface{}
+
 // HTTPScaledObjectListerExpansion allows custom methods to be added to
 // HTTPScaledObjectLister.
 type HTTPScaledObjectListerExpansion interface{}
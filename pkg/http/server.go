@@ -2,21 +2,75 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 )
 
+// DrainFunc waits for the requests in flight of a server that
+// stopped accepting new connections, until they are done or
+// ctx is. It is meant to report the progress of the drain
+type DrainFunc func(ctx context.Context)
+
+// ServeContext serves hdl on addr until ctx is done, and then closes
+// the server without waiting for the requests in flight
 func ServeContext(ctx context.Context, addr string, hdl http.Handler) error {
+	return ServeContextWithDrain(ctx, addr, hdl, 0, nil)
+}
+
+// ServeContextWithDrain serves hdl on addr until ctx is done. Then the
+// server stops accepting new connections and waits for up to gracePeriod
+// for its requests in flight to finish, before it closes the connections
+// that are left. drain, if not nil, runs along with the wait, with a
+// context that is done once the server has no connections left or the
+// grace period is over, and the wait lasts until drain returns too.
+//
+// It returns http.ErrServerClosed once the server is closed, or the
+// error that the server failed with
+func ServeContextWithDrain(
+	ctx context.Context,
+	addr string,
+	hdl http.Handler,
+	gracePeriod time.Duration,
+	drain DrainFunc,
+) error {
 	srv := &http.Server{
 		Handler: hdl,
 		Addr:    addr,
 	}
 
+	closed := make(chan struct{})
 	go func() {
+		defer close(closed)
 		<-ctx.Done()
-		if err := srv.Shutdown(ctx); err != nil {
-			fmt.Println("failed shutting down server:", err)
+		// ctx is already done, so the drain gets a context of its own
+		drainCtx, drainDone := context.WithTimeout(context.Background(), gracePeriod)
+		defer drainDone()
+		drained := make(chan struct{})
+		if drain != nil {
+			go func() {
+				defer close(drained)
+				drain(drainCtx)
+			}()
+		} else {
+			close(drained)
+		}
+		// the connections that are left when the grace
+		// period is over are closed below
+		if err := srv.Shutdown(drainCtx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			fmt.Println("failed draining server:", err)
+		}
+		drainDone()
+		<-drained
+		if err := srv.Close(); err != nil {
+			fmt.Println("failed closing server:", err)
 		}
 	}()
-	return srv.ListenAndServe()
+
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		<-closed
+	}
+	return err
 }
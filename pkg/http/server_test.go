@@ -37,3 +37,93 @@ func TestServeContext(t *testing.T) {
 	r.Greater(elapsed, cancelDur)
 	r.Less(elapsed, cancelDur*4)
 }
+
+// a draining server should finish its requests in flight,
+// but not accept new connections
+func TestServeContextWithDrain(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	hdl := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = w.Write([]byte("hello world"))
+	})
+	const addr = "localhost:1235"
+	drained := make(chan struct{})
+	srvErr := make(chan error, 1)
+	go func() {
+		srvErr <- ServeContextWithDrain(ctx, addr, hdl, 5*time.Second, func(ctx context.Context) {
+			<-ctx.Done()
+			close(drained)
+		})
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	resErr := make(chan error, 1)
+	go func() {
+		res, err := http.Get("http://" + addr)
+		if err == nil {
+			res.Body.Close()
+		}
+		resErr <- err
+	}()
+	<-started
+	done()
+	time.Sleep(200 * time.Millisecond)
+
+	_, err := http.Get("http://" + addr)
+	r.Error(err, "draining server accepted a new connection")
+	select {
+	case err := <-srvErr:
+		t.Fatalf("server closed before its requests in flight finished (%v)", err)
+	default:
+	}
+
+	close(release)
+	r.NoError(<-resErr)
+	<-drained
+	r.True(errors.Is(<-srvErr, http.ErrServerClosed))
+}
+
+// a draining server should close the connections that
+// are left once the grace period is over
+func TestServeContextWithDrainGracePeriod(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	hdl := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	const addr = "localhost:1236"
+	const gracePeriod = 300 * time.Millisecond
+	srvErr := make(chan error, 1)
+	go func() {
+		srvErr <- ServeContextWithDrain(ctx, addr, hdl, gracePeriod, nil)
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	resErr := make(chan error, 1)
+	go func() {
+		res, err := http.Get("http://" + addr)
+		if err == nil {
+			res.Body.Close()
+		}
+		resErr <- err
+	}()
+	<-started
+	start := time.Now()
+	done()
+
+	r.True(errors.Is(<-srvErr, http.ErrServerClosed))
+	elapsed := time.Since(start)
+	r.GreaterOrEqual(elapsed, gracePeriod)
+	r.Less(elapsed, gracePeriod*4)
+	r.Error(<-resErr)
+}
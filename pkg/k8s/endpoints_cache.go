@@ -0,0 +1,94 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// EndpointsCache is a cache of the endpoints of the Services in the
+// cluster. It allows callers to send requests straight to the pods
+// behind a Service without waiting for kube-proxy to program them
+type EndpointsCache interface {
+	// ReadyAddresses returns the host:port addresses of the ready
+	// endpoints behind the given port of a Service. The addresses
+	// may be empty, e.g. while the Service is scaled to zero. If the
+	// cache hasn't synced yet, it waits for it until ctx is done
+	ReadyAddresses(ctx context.Context, namespace, service string, port int32) ([]string, error)
+	// HasSynced returns whether the cache has synced, i.e. whether
+	// ReadyAddresses would return without waiting
+	HasSynced() bool
+	// Watch opens a watch stream for the EndpointSlices of the Service
+	// with the given name in the given namespace. Callers should get
+	// the ReadyAddresses again on every event
+	Watch(namespace, service string) (watch.Interface, error)
+}
+
+// servicePort returns the port of svc that serves port
+func servicePort(svc *corev1.Service, port int32) (corev1.ServicePort, error) {
+	for _, p := range svc.Spec.Ports {
+		if p.Port == port {
+			return p, nil
+		}
+	}
+	return corev1.ServicePort{}, fmt.Errorf(
+		"service %s/%s has no port %d",
+		svc.Namespace,
+		svc.Name,
+		port,
+	)
+}
+
+// readyAddresses returns the host:port addresses of the ready endpoints
+// in slices for svcPort. Addresses that are in several slices are only
+// returned once
+func readyAddresses(slices []*discoveryv1.EndpointSlice, svcPort corev1.ServicePort) []string {
+	seen := map[string]struct{}{}
+	ret := []string{}
+	for _, slice := range slices {
+		if slice.AddressType == discoveryv1.AddressTypeFQDN {
+			continue
+		}
+		port, ok := endpointPort(slice, svcPort)
+		if !ok {
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			// a nil Ready condition means that the readiness is
+			// unknown, which consumers should treat as ready
+			if ready := endpoint.Conditions.Ready; ready != nil && !*ready {
+				continue
+			}
+			for _, ip := range endpoint.Addresses {
+				addr := net.JoinHostPort(ip, strconv.Itoa(int(port)))
+				if _, ok := seen[addr]; ok {
+					continue
+				}
+				seen[addr] = struct{}{}
+				ret = append(ret, addr)
+			}
+		}
+	}
+	return ret
+}
+
+// endpointPort returns the port that the endpoints of slice serve
+// svcPort on. Ports are matched by name, which is empty for
+// Services with a single port
+func endpointPort(slice *discoveryv1.EndpointSlice, svcPort corev1.ServicePort) (int32, bool) {
+	for _, p := range slice.Ports {
+		name := ""
+		if p.Name != nil {
+			name = *p.Name
+		}
+		if name == svcPort.Name && p.Port != nil {
+			return *p.Port, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,99 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// FakeEndpointsCache is a fake implementation of EndpointsCache,
+// suitable for testing interceptor-level logic without any
+// Kubernetes API interaction
+type FakeEndpointsCache struct {
+	mut      sync.RWMutex
+	current  map[string][]string
+	bcaster  *watch.Broadcaster
+	unsynced bool
+}
+
+var _ EndpointsCache = &FakeEndpointsCache{}
+
+func NewFakeEndpointsCache() *FakeEndpointsCache {
+	return &FakeEndpointsCache{
+		current: map[string][]string{},
+		bcaster: watch.NewBroadcaster(0, watch.WaitIfChannelFull),
+	}
+}
+
+func fakeEndpointsCacheKey(namespace, service string, port int32) string {
+	return fmt.Sprintf("%s/%s:%d", namespace, service, port)
+}
+
+// SetReadyAddresses sets the ready addresses behind the given port of
+// a Service, and sends an event to the watchers of the Service
+func (f *FakeEndpointsCache) SetReadyAddresses(
+	namespace,
+	service string,
+	port int32,
+	addrs []string,
+) {
+	f.mut.Lock()
+	f.current[fakeEndpointsCacheKey(namespace, service, port)] = addrs
+	f.mut.Unlock()
+
+	_ = f.bcaster.Action(watch.Modified, &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      service,
+			Namespace: namespace,
+			Labels:    map[string]string{discoveryv1.LabelServiceName: service},
+		},
+	})
+}
+
+// SetSynced sets whether the cache has synced. ReadyAddresses
+// waits until its context is done while it hasn't
+func (f *FakeEndpointsCache) SetSynced(synced bool) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.unsynced = !synced
+}
+
+func (f *FakeEndpointsCache) HasSynced() bool {
+	f.mut.RLock()
+	defer f.mut.RUnlock()
+	return !f.unsynced
+}
+
+func (f *FakeEndpointsCache) ReadyAddresses(
+	ctx context.Context,
+	namespace,
+	service string,
+	port int32,
+) ([]string, error) {
+	if !f.HasSynced() {
+		<-ctx.Done()
+		return nil, fmt.Errorf("endpoints cache didn't sync: %w", ctx.Err())
+	}
+	f.mut.RLock()
+	defer f.mut.RUnlock()
+	addrs, ok := f.current[fakeEndpointsCacheKey(namespace, service, port)]
+	if !ok {
+		return nil, fmt.Errorf("service %s/%s not found", namespace, service)
+	}
+	return addrs, nil
+}
+
+func (f *FakeEndpointsCache) Watch(namespace, service string) (watch.Interface, error) {
+	watched, err := f.bcaster.Watch()
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(watched, func(e watch.Event) (watch.Event, bool) {
+		slice := e.Object.(*discoveryv1.EndpointSlice)
+		return e, slice.Namespace == namespace && slice.Name == service
+	}), nil
+}
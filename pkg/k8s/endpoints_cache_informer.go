@@ -0,0 +1,196 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/informers"
+	infcorev1 "k8s.io/client-go/informers/core/v1"
+	infdiscoveryv1 "k8s.io/client-go/informers/discovery/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// endpointSliceServiceIndex is the name of the informer index
+// of EndpointSlices by the namespace and name of their Service
+const endpointSliceServiceIndex = "service"
+
+// InformerBackedEndpointsCache is an EndpointsCache that runs informers
+// of the Services and EndpointSlices of the cluster. The informers are
+// only started on first use, so that interceptors whose targets don't
+// need the endpoints don't list and watch them
+type InformerBackedEndpointsCache struct {
+	lggr                  logr.Logger
+	factory               informers.SharedInformerFactory
+	serviceInformer       infcorev1.ServiceInformer
+	endpointSliceInformer infdiscoveryv1.EndpointSliceInformer
+	bcaster               *watch.Broadcaster
+	stopCh                chan struct{}
+	startOnce             sync.Once
+}
+
+var _ EndpointsCache = &InformerBackedEndpointsCache{}
+
+func (i *InformerBackedEndpointsCache) Start(ctx context.Context) error {
+	<-ctx.Done()
+	close(i.stopCh)
+	i.factory.Shutdown()
+	return errors.Wrap(
+		ctx.Err(), "endpoints cache informers were stopped",
+	)
+}
+
+func (i *InformerBackedEndpointsCache) ReadyAddresses(
+	ctx context.Context,
+	namespace,
+	service string,
+	port int32,
+) ([]string, error) {
+	if !i.HasSynced() && !cache.WaitForCacheSync(
+		ctx.Done(),
+		i.serviceInformer.Informer().HasSynced,
+		i.endpointSliceInformer.Informer().HasSynced,
+	) {
+		return nil, errors.Wrap(ctx.Err(), "endpoints cache informers didn't sync")
+	}
+	svc, err := i.serviceInformer.Lister().Services(namespace).Get(service)
+	if err != nil {
+		return nil, err
+	}
+	svcPort, err := servicePort(svc, port)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := i.endpointSliceInformer.Informer().GetIndexer().ByIndex(
+		endpointSliceServiceIndex,
+		namespace+"/"+service,
+	)
+	if err != nil {
+		return nil, err
+	}
+	slices := make([]*discoveryv1.EndpointSlice, 0, len(objs))
+	for _, obj := range objs {
+		slices = append(slices, obj.(*discoveryv1.EndpointSlice))
+	}
+	return readyAddresses(slices, svcPort), nil
+}
+
+// HasSynced starts the informers of i if they
+// weren't yet, and returns whether they have synced
+func (i *InformerBackedEndpointsCache) HasSynced() bool {
+	i.startInformers()
+	return i.serviceInformer.Informer().HasSynced() &&
+		i.endpointSliceInformer.Informer().HasSynced()
+}
+
+func (i *InformerBackedEndpointsCache) Watch(
+	namespace,
+	service string,
+) (watch.Interface, error) {
+	i.startInformers()
+	watched, err := i.bcaster.Watch()
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(watched, func(e watch.Event) (watch.Event, bool) {
+		slice := e.Object.(*discoveryv1.EndpointSlice)
+		if slice.Namespace == namespace && slice.Labels[discoveryv1.LabelServiceName] == service {
+			return e, true
+		}
+		return e, false
+	}), nil
+}
+
+// startInformers starts the informers of i
+// the first time that it is called
+func (i *InformerBackedEndpointsCache) startInformers() {
+	i.startOnce.Do(func() {
+		i.lggr.Info("starting endpoints cache informers")
+		i.factory.Start(i.stopCh)
+	})
+}
+
+func (i *InformerBackedEndpointsCache) addEvtHandler(obj interface{}) {
+	i.forwardEvent(watch.Added, obj)
+}
+
+func (i *InformerBackedEndpointsCache) updateEvtHandler(oldObj, newObj interface{}) {
+	i.forwardEvent(watch.Modified, newObj)
+}
+
+func (i *InformerBackedEndpointsCache) deleteEvtHandler(obj interface{}) {
+	i.forwardEvent(watch.Deleted, obj)
+}
+
+// forwardEvent sends an event for obj to the watchers of i
+func (i *InformerBackedEndpointsCache) forwardEvent(action watch.EventType, obj interface{}) {
+	slice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		i.lggr.Error(
+			fmt.Errorf("informer expected endpoint slice, got %v", obj),
+			"not forwarding event",
+		)
+		return
+	}
+
+	if err := i.bcaster.Action(action, slice); err != nil {
+		i.lggr.Error(err, "informer expected endpoint slice")
+	}
+}
+
+// endpointSliceService is the informer index function of
+// endpointSliceServiceIndex
+func endpointSliceService(obj interface{}) ([]string, error) {
+	slice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return nil, fmt.Errorf("informer expected endpoint slice, got %v", obj)
+	}
+	svcName, ok := slice.Labels[discoveryv1.LabelServiceName]
+	if !ok {
+		return nil, nil
+	}
+	return []string{slice.Namespace + "/" + svcName}, nil
+}
+
+func NewInformerBackedEndpointsCache(
+	lggr logr.Logger,
+	cl kubernetes.Interface,
+	defaultResync time.Duration,
+) *InformerBackedEndpointsCache {
+	factory := informers.NewSharedInformerFactory(
+		cl,
+		defaultResync,
+	)
+	ret := &InformerBackedEndpointsCache{
+		lggr:                  lggr,
+		factory:               factory,
+		serviceInformer:       factory.Core().V1().Services(),
+		endpointSliceInformer: factory.Discovery().V1().EndpointSlices(),
+		bcaster:               watch.NewBroadcaster(0, watch.WaitIfChannelFull),
+		stopCh:                make(chan struct{}),
+	}
+	// the informers must be requested from the
+	// factory before it is started to run
+	ret.serviceInformer.Informer()
+	err := ret.endpointSliceInformer.Informer().AddIndexers(cache.Indexers{
+		endpointSliceServiceIndex: endpointSliceService,
+	})
+	if err != nil {
+		lggr.Error(err, "error creating endpoint slice informer")
+	}
+	_, err = ret.endpointSliceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ret.addEvtHandler,
+		UpdateFunc: ret.updateEvtHandler,
+		DeleteFunc: ret.deleteEvtHandler,
+	})
+	if err != nil {
+		lggr.Error(err, "error creating endpoint slice informer")
+	}
+	return ret
+}
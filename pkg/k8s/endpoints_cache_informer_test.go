@@ -0,0 +1,182 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/pointer"
+)
+
+func TestInformerBackedEndpointsCacheReadyAddresses(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "testsvc", Namespace: "testns"},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{
+				{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080)},
+				{Name: "metrics", Port: 9090, TargetPort: intstr.FromInt(9091)},
+			},
+		},
+	}
+	newSlice := func(name string, addrType discoveryv1.AddressType, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+		return &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "testns",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "testsvc"},
+			},
+			AddressType: addrType,
+			Endpoints:   endpoints,
+			Ports: []discoveryv1.EndpointPort{
+				{Name: pointer.String("http"), Port: pointer.Int32(8080)},
+				{Name: pointer.String("metrics"), Port: pointer.Int32(9091)},
+			},
+		}
+	}
+	endpoint := func(ready *bool, ips ...string) discoveryv1.Endpoint {
+		return discoveryv1.Endpoint{
+			Addresses:  ips,
+			Conditions: discoveryv1.EndpointConditions{Ready: ready},
+		}
+	}
+	otherSvcSlice := newSlice("othersvc-abc", discoveryv1.AddressTypeIPv4, endpoint(nil, "1.1.1.1"))
+	otherSvcSlice.Labels[discoveryv1.LabelServiceName] = "othersvc"
+	cl := fake.NewSimpleClientset(
+		svc,
+		newSlice(
+			"testsvc-abc",
+			discoveryv1.AddressTypeIPv4,
+			endpoint(pointer.Bool(true), "1.2.3.4"),
+			endpoint(pointer.Bool(false), "1.2.3.5"),
+			// unknown readiness counts as ready
+			endpoint(nil, "1.2.3.6"),
+		),
+		// the same endpoint can briefly be in two slices
+		newSlice("testsvc-def", discoveryv1.AddressTypeIPv4, endpoint(pointer.Bool(true), "1.2.3.4")),
+		newSlice("testsvc-ipv6", discoveryv1.AddressTypeIPv6, endpoint(pointer.Bool(true), "fd00::1")),
+		otherSvcSlice,
+	)
+
+	endpointsCache := NewInformerBackedEndpointsCache(logr.Discard(), cl, time.Minute)
+	go func() {
+		_ = endpointsCache.Start(ctx)
+	}()
+
+	addrs, err := endpointsCache.ReadyAddresses(ctx, "testns", "testsvc", 80)
+	r.NoError(err)
+	r.ElementsMatch([]string{"1.2.3.4:8080", "1.2.3.6:8080", "[fd00::1]:8080"}, addrs)
+
+	addrs, err = endpointsCache.ReadyAddresses(ctx, "testns", "testsvc", 9090)
+	r.NoError(err)
+	r.ElementsMatch([]string{"1.2.3.4:9091", "1.2.3.6:9091", "[fd00::1]:9091"}, addrs)
+
+	_, err = endpointsCache.ReadyAddresses(ctx, "testns", "testsvc", 1234)
+	r.Error(err, "unknown service port")
+
+	_, err = endpointsCache.ReadyAddresses(ctx, "testns", "nosuchsvc", 80)
+	r.Error(err, "unknown service")
+}
+
+func TestInformerBackedEndpointsCacheWatch(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+
+	cl := fake.NewSimpleClientset()
+	endpointsCache := NewInformerBackedEndpointsCache(logr.Discard(), cl, time.Minute)
+	go func() {
+		_ = endpointsCache.Start(ctx)
+	}()
+
+	watcher, err := endpointsCache.Watch("testns", "testsvc")
+	r.NoError(err)
+	defer watcher.Stop()
+	r.True(cache.WaitForCacheSync(
+		ctx.Done(),
+		endpointsCache.endpointSliceInformer.Informer().HasSynced,
+	))
+
+	newSlice := func(name, svcName string) *discoveryv1.EndpointSlice {
+		return &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "testns",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: svcName},
+			},
+			AddressType: discoveryv1.AddressTypeIPv4,
+		}
+	}
+	slices := cl.DiscoveryV1().EndpointSlices("testns")
+	// events of other Services are filtered out
+	_, err = slices.Create(ctx, newSlice("othersvc-abc", "othersvc"), metav1.CreateOptions{})
+	r.NoError(err)
+	_, err = slices.Create(ctx, newSlice("testsvc-abc", "testsvc"), metav1.CreateOptions{})
+	r.NoError(err)
+
+	select {
+	case evt := <-watcher.ResultChan():
+		r.Equal(watch.Added, evt.Type)
+		r.Equal("testsvc-abc", evt.Object.(*discoveryv1.EndpointSlice).Name)
+	case <-time.After(5 * time.Second):
+		r.Fail("no event for the endpoint slice of the service")
+	}
+}
+
+// the informers should only list and watch
+// the cluster once the cache is first used
+func TestInformerBackedEndpointsCacheLazyStart(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+
+	cl := fake.NewSimpleClientset()
+	endpointsCache := NewInformerBackedEndpointsCache(logr.Discard(), cl, time.Minute)
+	go func() {
+		_ = endpointsCache.Start(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	r.Empty(cl.Actions())
+
+	_, err := endpointsCache.ReadyAddresses(ctx, "testns", "testsvc", 80)
+	r.Error(err)
+	r.NotEmpty(cl.Actions())
+}
+
+// waiting for the informers to sync should end with the context,
+// e.g. when the endpoints can't be listed
+func TestInformerBackedEndpointsCacheNotSynced(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+
+	cl := fake.NewSimpleClientset()
+	cl.PrependReactor("list", "endpointslices", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("forbidden")
+	})
+	endpointsCache := NewInformerBackedEndpointsCache(logr.Discard(), cl, time.Minute)
+	go func() {
+		_ = endpointsCache.Start(ctx)
+	}()
+
+	r.False(endpointsCache.HasSynced())
+	reqCtx, reqDone := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer reqDone()
+	_, err := endpointsCache.ReadyAddresses(reqCtx, "testns", "testsvc", 80)
+	r.ErrorIs(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,67 @@
+package k8s
+
+import (
+	"encoding/json"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PodCache is a cache of the pods in the cluster, indexed by
+// their IP addresses. It allows callers to identify the pod that
+// a network connection comes from without issuing a network request
+// to the Kubernetes API or to the cluster DNS
+type PodCache interface {
+	// MarshalJSON encodes the PodInfo of every indexed
+	// pod, keyed by IP address
+	json.Marshaler
+	// GetByIP gets the pod that has the given IP address from
+	// the cache.
+	//
+	// Pods that share the network of their node are not indexed,
+	// since their IP address doesn't identify them
+	GetByIP(ip string) (PodInfo, error)
+}
+
+// PodInfo identifies a pod and the workload that owns it
+type PodInfo struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Workload is the workload that owns the pod, if any
+	Workload *Workload `json:"workload,omitempty"`
+}
+
+// Workload is a controller of pods, like a Deployment or a StatefulSet
+type Workload struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// NewPodInfo returns the PodInfo of pod. Pods owned by a ReplicaSet
+// that was created by a Deployment are attributed to the Deployment
+func NewPodInfo(pod *corev1.Pod) PodInfo {
+	info := PodInfo{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+	}
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return info
+	}
+	info.Workload = &Workload{
+		Kind: owner.Kind,
+		Name: owner.Name,
+	}
+	// a Deployment names its ReplicaSets after itself and the
+	// pod-template-hash label of their pods
+	if hash, ok := pod.Labels["pod-template-hash"]; ok && owner.Kind == "ReplicaSet" {
+		if depl := strings.TrimSuffix(owner.Name, "-"+hash); depl != owner.Name {
+			info.Workload = &Workload{
+				Kind: "Deployment",
+				Name: depl,
+			}
+		}
+	}
+	return info
+}
@@ -0,0 +1,25 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// FakePodCache is a fake implementation of PodCache that
+// maps IP addresses to PodInfos, suitable for testing
+// interceptor-level logic without any Kubernetes API interaction
+type FakePodCache map[string]PodInfo
+
+var _ PodCache = FakePodCache{}
+
+func (f FakePodCache) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]PodInfo(f))
+}
+
+func (f FakePodCache) GetByIP(ip string) (PodInfo, error) {
+	pod, ok := f[ip]
+	if !ok {
+		return PodInfo{}, fmt.Errorf("no pod found with IP %q", ip)
+	}
+	return pod, nil
+}
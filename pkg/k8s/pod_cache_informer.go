@@ -0,0 +1,142 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	infcorev1 "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// podIPIndex is the name of the informer index of pods by IP address
+const podIPIndex = "podIP"
+
+type InformerBackedPodCache struct {
+	lggr        logr.Logger
+	podInformer infcorev1.PodInformer
+}
+
+var _ PodCache = &InformerBackedPodCache{}
+
+func (i *InformerBackedPodCache) Start(ctx context.Context) error {
+	i.podInformer.Informer().Run(ctx.Done())
+	return errors.Wrap(
+		ctx.Err(), "pod cache informer was stopped",
+	)
+}
+
+// MarshalJSON encodes the PodInfo of every pod in the cache, keyed
+// by IP address
+func (i *InformerBackedPodCache) MarshalJSON() ([]byte, error) {
+	pods := make(map[string]PodInfo)
+	for _, obj := range i.podInformer.Informer().GetStore().List() {
+		ips, err := podIPs(obj)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			pods[ip] = NewPodInfo(obj.(*corev1.Pod))
+		}
+	}
+	return json.Marshal(pods)
+}
+
+func (i *InformerBackedPodCache) GetByIP(ip string) (PodInfo, error) {
+	objs, err := i.podInformer.Informer().GetIndexer().ByIndex(podIPIndex, ip)
+	if err != nil {
+		return PodInfo{}, err
+	}
+	switch len(objs) {
+	case 0:
+		return PodInfo{}, fmt.Errorf("no pod found with IP %q", ip)
+	case 1:
+		return NewPodInfo(objs[0].(*corev1.Pod)), nil
+	default:
+		return PodInfo{}, fmt.Errorf("%d pods found with IP %q", len(objs), ip)
+	}
+}
+
+// podIPs is the informer index function of podIPIndex. Pods on the
+// host network and pods that have terminated are left out, since
+// their IP addresses may be shared with or reused by other pods
+func podIPs(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return nil, fmt.Errorf("informer expected pod, got %v", obj)
+	}
+	if pod.Spec.HostNetwork ||
+		pod.Status.Phase == corev1.PodSucceeded ||
+		pod.Status.Phase == corev1.PodFailed {
+		return nil, nil
+	}
+	ips := make([]string, 0, len(pod.Status.PodIPs))
+	for _, podIP := range pod.Status.PodIPs {
+		ips = append(ips, podIP.IP)
+	}
+	if len(ips) == 0 && pod.Status.PodIP != "" {
+		ips = append(ips, pod.Status.PodIP)
+	}
+	return ips, nil
+}
+
+// trimPod is the informer transform function of the pod cache. It drops
+// everything from pods that the cache doesn't use, since a cache of all
+// the pods in the cluster would otherwise hold on to a lot of memory
+func trimPod(obj interface{}) (interface{}, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		// e.g. cache.DeletedFinalStateUnknown
+		return obj, nil
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            pod.Name,
+			Namespace:       pod.Namespace,
+			UID:             pod.UID,
+			ResourceVersion: pod.ResourceVersion,
+			Labels:          pod.Labels,
+			OwnerReferences: pod.OwnerReferences,
+		},
+		Spec: corev1.PodSpec{
+			HostNetwork: pod.Spec.HostNetwork,
+		},
+		Status: corev1.PodStatus{
+			Phase:  pod.Status.Phase,
+			PodIP:  pod.Status.PodIP,
+			PodIPs: pod.Status.PodIPs,
+		},
+	}, nil
+}
+
+func NewInformerBackedPodCache(
+	lggr logr.Logger,
+	cl kubernetes.Interface,
+	defaultResync time.Duration,
+) *InformerBackedPodCache {
+	factory := informers.NewSharedInformerFactory(
+		cl,
+		defaultResync,
+	)
+	ret := &InformerBackedPodCache{
+		lggr:        lggr,
+		podInformer: factory.Core().V1().Pods(),
+	}
+	if err := ret.podInformer.Informer().SetTransform(trimPod); err != nil {
+		lggr.Error(err, "error creating pod informer")
+	}
+	err := ret.podInformer.Informer().AddIndexers(cache.Indexers{
+		podIPIndex: podIPs,
+	})
+	if err != nil {
+		lggr.Error(err, "error creating pod informer")
+	}
+	return ret
+}
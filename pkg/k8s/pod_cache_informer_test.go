@@ -0,0 +1,95 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/pointer"
+)
+
+func TestInformerBackedPodCacheGetByIP(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+
+	newPod := func(name, ip string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "testns",
+				Labels:    map[string]string{"pod-template-hash": "5d8f7c9b6"},
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						Kind:       "ReplicaSet",
+						Name:       "testdepl-5d8f7c9b6",
+						Controller: pointer.Bool(true),
+					},
+				},
+			},
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{{Name: "app", Image: "app"}},
+			},
+			Status: v1.PodStatus{
+				Phase:  v1.PodRunning,
+				PodIP:  ip,
+				PodIPs: []v1.PodIP{{IP: ip}},
+			},
+		}
+	}
+	hostNetPod := newPod("hostnet", "10.0.0.1")
+	hostNetPod.Spec.HostNetwork = true
+	completedPod := newPod("completed", "1.2.3.5")
+	completedPod.Status.Phase = v1.PodSucceeded
+	cl := fake.NewSimpleClientset(
+		newPod("testpod", "1.2.3.4"),
+		completedPod,
+		newPod("reused", "1.2.3.5"),
+		hostNetPod,
+	)
+
+	podCache := NewInformerBackedPodCache(logr.Discard(), cl, time.Minute)
+	go func() {
+		_ = podCache.Start(ctx)
+	}()
+	r.True(cache.WaitForCacheSync(ctx.Done(), podCache.podInformer.Informer().HasSynced))
+
+	pod, err := podCache.GetByIP("1.2.3.4")
+	r.NoError(err)
+	r.Equal(PodInfo{
+		Namespace: "testns",
+		Name:      "testpod",
+		Workload:  &Workload{Kind: "Deployment", Name: "testdepl"},
+	}, pod)
+
+	// only what the cache needs of a pod is kept
+	obj, exists, err := podCache.podInformer.Informer().GetStore().GetByKey("testns/testpod")
+	r.NoError(err)
+	r.True(exists)
+	r.Empty(obj.(*v1.Pod).Spec.Containers)
+
+	// terminated pods don't hold on to their IP
+	pod, err = podCache.GetByIP("1.2.3.5")
+	r.NoError(err)
+	r.Equal("reused", pod.Name)
+
+	_, err = podCache.GetByIP("10.0.0.1")
+	r.Error(err)
+	_, err = podCache.GetByIP("4.3.2.1")
+	r.Error(err)
+
+	b, err := podCache.MarshalJSON()
+	r.NoError(err)
+	var pods map[string]PodInfo
+	r.NoError(json.Unmarshal(b, &pods))
+	r.Len(pods, 2)
+	r.Equal("testpod", pods["1.2.3.4"].Name)
+	r.Equal("reused", pods["1.2.3.5"].Name)
+}
@@ -0,0 +1,56 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestNewPodInfo(t *testing.T) {
+	r := require.New(t)
+
+	newPod := func(ownerKind, ownerName string, labels map[string]string) *v1.Pod {
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "testpod",
+				Namespace: "testns",
+				Labels:    labels,
+			},
+		}
+		if ownerKind != "" {
+			pod.OwnerReferences = []metav1.OwnerReference{
+				{Kind: ownerKind, Name: ownerName, Controller: pointer.Bool(true)},
+			}
+		}
+		return pod
+	}
+
+	testCases := []struct {
+		pod      *v1.Pod
+		workload *Workload
+	}{
+		{newPod("", "", nil), nil},
+		{
+			newPod("ReplicaSet", "testdepl-5d8f7c9b6", map[string]string{"pod-template-hash": "5d8f7c9b6"}),
+			&Workload{Kind: "Deployment", Name: "testdepl"},
+		},
+		// a ReplicaSet that no Deployment created
+		{
+			newPod("ReplicaSet", "testrs", nil),
+			&Workload{Kind: "ReplicaSet", Name: "testrs"},
+		},
+		{
+			newPod("StatefulSet", "teststs", map[string]string{"controller-revision-hash": "teststs-7b9c"}),
+			&Workload{Kind: "StatefulSet", Name: "teststs"},
+		},
+	}
+	for _, tc := range testCases {
+		info := NewPodInfo(tc.pod)
+		r.Equal("testns", info.Namespace)
+		r.Equal("testpod", info.Name)
+		r.Equal(tc.workload, info.Workload)
+	}
+}
@@ -0,0 +1,56 @@
+package k8s
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/scale"
+)
+
+// ScaleFromZero scales the workload with the given API version, kind,
+// namespace and name to one replica through its scale subresource, if
+// it has no replicas. It returns false if the workload already had
+// replicas.
+//
+// The update carries the resource version of the scale that was read,
+// so when several clients scale the same workload at once only one of
+// them succeeds. The others return false rather than a conflict error
+func ScaleFromZero(
+	ctx context.Context,
+	scales scale.ScalesGetter,
+	mapper meta.RESTMapper,
+	apiVersion,
+	kind,
+	namespace,
+	name string,
+) (bool, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return false, err
+	}
+	mapping, err := mapper.RESTMapping(gv.WithKind(kind).GroupKind(), gv.Version)
+	if err != nil {
+		return false, err
+	}
+	gr := mapping.Resource.GroupResource()
+	scaleClient := scales.Scales(namespace)
+
+	current, err := scaleClient.Get(ctx, gr, name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	if current.Spec.Replicas > 0 {
+		return false, nil
+	}
+	current.Spec.Replicas = 1
+	if _, err := scaleClient.Update(ctx, gr, current, metav1.UpdateOptions{}); err != nil {
+		if errors.IsConflict(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,57 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// ScaleTargetCache is a cache of the workloads of any kind that
+// implements the scale subresource, such as StatefulSets or Argo
+// Rollouts. It allows callers to get the readiness of a workload,
+// or watch for changes to it, without issuing a network request to
+// the Kubernetes API
+type ScaleTargetCache interface {
+	// MarshalJSON encodes the cached workloads,
+	// keyed by their resource
+	json.Marshaler
+	// ReadyReplicas returns the number of ready replicas of the
+	// workload with the given API version, kind, namespace and name.
+	//
+	// The first call for a kind of workload starts caching the workloads
+	// of that kind, and waits for the cache to sync until ctx is done
+	ReadyReplicas(ctx context.Context, apiVersion, kind, namespace, name string) (int32, error)
+	// Replicas returns the number of desired replicas of the workload
+	// with the given API version, kind, namespace and name. Like
+	// ReadyReplicas, it starts caching the workloads of the kind
+	Replicas(ctx context.Context, apiVersion, kind, namespace, name string) (int32, error)
+	// Watch opens a watch stream for the workload with the given API
+	// version, kind, namespace and name. Callers should get the
+	// ReadyReplicas again on every event
+	Watch(apiVersion, kind, namespace, name string) (watch.Interface, error)
+}
+
+// readyReplicas returns the status.readyReplicas of obj. Workloads
+// that don't report it, or report no ready replicas, have none
+func readyReplicas(obj *unstructured.Unstructured) (int32, error) {
+	replicas, _, err := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+	if err != nil {
+		return 0, err
+	}
+	return int32(replicas), nil
+}
+
+// specReplicas returns the spec.replicas of obj. Workloads that don't
+// set it have the single replica that Kubernetes defaults it to
+func specReplicas(obj *unstructured.Unstructured) (int32, error) {
+	replicas, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 1, nil
+	}
+	return int32(replicas), nil
+}
@@ -0,0 +1,127 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// FakeScaleTargetCache is a fake implementation of ScaleTargetCache,
+// suitable for testing interceptor-level logic without any
+// Kubernetes API interaction
+type FakeScaleTargetCache struct {
+	mut      sync.RWMutex
+	current  map[string]int32
+	replicas map[string]int32
+	bcaster  *watch.Broadcaster
+}
+
+var _ ScaleTargetCache = &FakeScaleTargetCache{}
+
+func NewFakeScaleTargetCache() *FakeScaleTargetCache {
+	return &FakeScaleTargetCache{
+		current:  map[string]int32{},
+		replicas: map[string]int32{},
+		bcaster:  watch.NewBroadcaster(0, watch.WaitIfChannelFull),
+	}
+}
+
+func fakeScaleTargetCacheKey(apiVersion, kind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", apiVersion, kind, namespace, name)
+}
+
+// SetReadyReplicas sets the ready replicas of a workload, and
+// sends an event to the watchers of the workload
+func (f *FakeScaleTargetCache) SetReadyReplicas(
+	apiVersion,
+	kind,
+	namespace,
+	name string,
+	replicas int32,
+) {
+	f.mut.Lock()
+	f.current[fakeScaleTargetCacheKey(apiVersion, kind, namespace, name)] = replicas
+	f.mut.Unlock()
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	_ = f.bcaster.Action(watch.Modified, obj)
+}
+
+// SetReplicas sets the desired replicas of a workload without
+// sending an event to any of the watchers
+func (f *FakeScaleTargetCache) SetReplicas(
+	apiVersion,
+	kind,
+	namespace,
+	name string,
+	replicas int32,
+) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.replicas[fakeScaleTargetCacheKey(apiVersion, kind, namespace, name)] = replicas
+}
+
+func (f *FakeScaleTargetCache) MarshalJSON() ([]byte, error) {
+	f.mut.RLock()
+	defer f.mut.RUnlock()
+	return json.Marshal(f.current)
+}
+
+func (f *FakeScaleTargetCache) ReadyReplicas(
+	_ context.Context,
+	apiVersion,
+	kind,
+	namespace,
+	name string,
+) (int32, error) {
+	f.mut.RLock()
+	defer f.mut.RUnlock()
+	replicas, ok := f.current[fakeScaleTargetCacheKey(apiVersion, kind, namespace, name)]
+	if !ok {
+		return 0, fmt.Errorf("%s %s/%s not found", kind, namespace, name)
+	}
+	return replicas, nil
+}
+
+func (f *FakeScaleTargetCache) Replicas(
+	_ context.Context,
+	apiVersion,
+	kind,
+	namespace,
+	name string,
+) (int32, error) {
+	f.mut.RLock()
+	defer f.mut.RUnlock()
+	replicas, ok := f.replicas[fakeScaleTargetCacheKey(apiVersion, kind, namespace, name)]
+	if !ok {
+		return 0, fmt.Errorf("%s %s/%s not found", kind, namespace, name)
+	}
+	return replicas, nil
+}
+
+func (f *FakeScaleTargetCache) Watch(
+	apiVersion,
+	kind,
+	namespace,
+	name string,
+) (watch.Interface, error) {
+	watched, err := f.bcaster.Watch()
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(watched, func(e watch.Event) (watch.Event, bool) {
+		obj := e.Object.(*unstructured.Unstructured)
+		return e, obj.GetAPIVersion() == apiVersion &&
+			obj.GetKind() == kind &&
+			obj.GetNamespace() == namespace &&
+			obj.GetName() == name
+	}), nil
+}
@@ -0,0 +1,221 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// InformerBackedScaleTargetCache is a ScaleTargetCache that runs a
+// dynamic informer for every resource it is asked about. The informers
+// are only started on first use, so that the interceptor doesn't need
+// to list and watch kinds of workloads that no HTTPScaledObject scales
+type InformerBackedScaleTargetCache struct {
+	lggr    logr.Logger
+	mapper  meta.RESTMapper
+	factory dynamicinformer.DynamicSharedInformerFactory
+	stopCh  chan struct{}
+
+	mut       sync.Mutex
+	informers map[schema.GroupVersionResource]*scaleTargetInformer
+}
+
+// scaleTargetInformer is the informer of a resource, along with the
+// broadcaster that forwards its events to the watchers of the resource
+type scaleTargetInformer struct {
+	informer informers.GenericInformer
+	bcaster  *watch.Broadcaster
+}
+
+var _ ScaleTargetCache = &InformerBackedScaleTargetCache{}
+
+func (i *InformerBackedScaleTargetCache) MarshalJSON() ([]byte, error) {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+	ret := make(map[string][]runtime.Object, len(i.informers))
+	for gvr, inf := range i.informers {
+		objs, err := inf.informer.Lister().List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		ret[gvr.String()] = objs
+	}
+	return json.Marshal(ret)
+}
+
+func (i *InformerBackedScaleTargetCache) Start(ctx context.Context) error {
+	<-ctx.Done()
+	close(i.stopCh)
+	return errors.Wrap(
+		ctx.Err(), "scale target cache informers were stopped",
+	)
+}
+
+func (i *InformerBackedScaleTargetCache) ReadyReplicas(
+	ctx context.Context,
+	apiVersion,
+	kind,
+	namespace,
+	name string,
+) (int32, error) {
+	obj, err := i.get(ctx, apiVersion, kind, namespace, name)
+	if err != nil {
+		return 0, err
+	}
+	return readyReplicas(obj)
+}
+
+func (i *InformerBackedScaleTargetCache) Replicas(
+	ctx context.Context,
+	apiVersion,
+	kind,
+	namespace,
+	name string,
+) (int32, error) {
+	obj, err := i.get(ctx, apiVersion, kind, namespace, name)
+	if err != nil {
+		return 0, err
+	}
+	return specReplicas(obj)
+}
+
+// get gets the workload with the given API version, kind, namespace
+// and name from the informer of its resource, once the informer synced
+func (i *InformerBackedScaleTargetCache) get(
+	ctx context.Context,
+	apiVersion,
+	kind,
+	namespace,
+	name string,
+) (*unstructured.Unstructured, error) {
+	inf, err := i.informerFor(apiVersion, kind)
+	if err != nil {
+		return nil, err
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), inf.informer.Informer().HasSynced) {
+		return nil, fmt.Errorf("cache of %s %s not synced (%w)", apiVersion, kind, ctx.Err())
+	}
+	obj, err := inf.informer.Lister().ByNamespace(namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("informer expected unstructured object, got %v", obj)
+	}
+	return u, nil
+}
+
+func (i *InformerBackedScaleTargetCache) Watch(
+	apiVersion,
+	kind,
+	namespace,
+	name string,
+) (watch.Interface, error) {
+	inf, err := i.informerFor(apiVersion, kind)
+	if err != nil {
+		return nil, err
+	}
+	watched, err := inf.bcaster.Watch()
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(watched, func(e watch.Event) (watch.Event, bool) {
+		obj := e.Object.(*unstructured.Unstructured)
+		return e, obj.GetNamespace() == namespace && obj.GetName() == name
+	}), nil
+}
+
+// informerFor returns the informer of the resource of the given
+// API version and kind, and starts it if it isn't running yet
+func (i *InformerBackedScaleTargetCache) informerFor(
+	apiVersion,
+	kind string,
+) (*scaleTargetInformer, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := i.mapper.RESTMapping(gv.WithKind(kind).GroupKind(), gv.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	i.mut.Lock()
+	defer i.mut.Unlock()
+	if inf, ok := i.informers[mapping.Resource]; ok {
+		return inf, nil
+	}
+	inf := &scaleTargetInformer{
+		informer: i.factory.ForResource(mapping.Resource),
+		bcaster:  watch.NewBroadcaster(0, watch.WaitIfChannelFull),
+	}
+	_, err = inf.informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			i.forwardEvent(inf.bcaster, watch.Added, obj)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			i.forwardEvent(inf.bcaster, watch.Modified, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			i.forwardEvent(inf.bcaster, watch.Deleted, obj)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	i.informers[mapping.Resource] = inf
+	i.lggr.Info("starting scale target informer", "resource", mapping.Resource.String())
+	i.factory.Start(i.stopCh)
+	return inf, nil
+}
+
+// forwardEvent sends an event for obj to the watchers of bcaster
+func (i *InformerBackedScaleTargetCache) forwardEvent(
+	bcaster *watch.Broadcaster,
+	action watch.EventType,
+	obj interface{},
+) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		i.lggr.Error(
+			fmt.Errorf("informer expected unstructured object, got %v", obj),
+			"not forwarding event",
+		)
+		return
+	}
+
+	if err := bcaster.Action(action, u); err != nil {
+		i.lggr.Error(err, "informer expected unstructured object")
+	}
+}
+
+func NewInformerBackedScaleTargetCache(
+	lggr logr.Logger,
+	cl dynamic.Interface,
+	mapper meta.RESTMapper,
+	defaultResync time.Duration,
+) *InformerBackedScaleTargetCache {
+	return &InformerBackedScaleTargetCache{
+		lggr:      lggr,
+		mapper:    mapper,
+		factory:   dynamicinformer.NewDynamicSharedInformerFactory(cl, defaultResync),
+		stopCh:    make(chan struct{}),
+		informers: make(map[schema.GroupVersionResource]*scaleTargetInformer),
+	}
+}
@@ -0,0 +1,89 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestInformerBackedScaleTargetCache(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+
+	stsGVR := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"}, meta.RESTScopeNamespace)
+
+	newSts := func(name string, readyReplicas int64) *unstructured.Unstructured {
+		sts := &unstructured.Unstructured{}
+		sts.SetAPIVersion("apps/v1")
+		sts.SetKind("StatefulSet")
+		sts.SetNamespace("testns")
+		sts.SetName(name)
+		r.NoError(unstructured.SetNestedField(sts.Object, readyReplicas, "spec", "replicas"))
+		if readyReplicas > 0 {
+			r.NoError(unstructured.SetNestedField(sts.Object, readyReplicas, "status", "readyReplicas"))
+		}
+		return sts
+	}
+	cl := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{stsGVR: "StatefulSetList"},
+		newSts("ready", 2),
+		newSts("scaledtozero", 0),
+	)
+	scaleTargetCache := NewInformerBackedScaleTargetCache(logr.Discard(), cl, mapper, time.Minute)
+	go func() {
+		_ = scaleTargetCache.Start(ctx)
+	}()
+
+	replicas, err := scaleTargetCache.ReadyReplicas(ctx, "apps/v1", "StatefulSet", "testns", "ready")
+	r.NoError(err)
+	r.EqualValues(2, replicas)
+
+	replicas, err = scaleTargetCache.ReadyReplicas(ctx, "apps/v1", "StatefulSet", "testns", "scaledtozero")
+	r.NoError(err)
+	r.EqualValues(0, replicas)
+	replicas, err = scaleTargetCache.Replicas(ctx, "apps/v1", "StatefulSet", "testns", "scaledtozero")
+	r.NoError(err)
+	r.EqualValues(0, replicas)
+
+	_, err = scaleTargetCache.ReadyReplicas(ctx, "apps/v1", "StatefulSet", "testns", "nosuchsts")
+	r.Error(err, "unknown workload")
+
+	_, err = scaleTargetCache.ReadyReplicas(ctx, "argoproj.io/v1alpha1", "Rollout", "testns", "ready")
+	r.Error(err, "unknown kind")
+
+	watcher, err := scaleTargetCache.Watch("apps/v1", "StatefulSet", "testns", "scaledtozero")
+	r.NoError(err)
+	defer watcher.Stop()
+
+	// events of other workloads are filtered out
+	stsClient := cl.Resource(stsGVR).Namespace("testns")
+	_, err = stsClient.Update(ctx, newSts("ready", 3), metav1.UpdateOptions{})
+	r.NoError(err)
+	_, err = stsClient.Update(ctx, newSts("scaledtozero", 1), metav1.UpdateOptions{})
+	r.NoError(err)
+
+	select {
+	case evt := <-watcher.ResultChan():
+		r.Equal(watch.Modified, evt.Type)
+		r.Equal("scaledtozero", evt.Object.(*unstructured.Unstructured).GetName())
+	case <-time.After(5 * time.Second):
+		r.Fail("no event for the workload")
+	}
+	replicas, err = scaleTargetCache.ReadyReplicas(ctx, "apps/v1", "StatefulSet", "testns", "scaledtozero")
+	r.NoError(err)
+	r.EqualValues(1, replicas)
+}
@@ -0,0 +1,70 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakescale "k8s.io/client-go/scale/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestScaleFromZero(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"}, meta.RESTScopeNamespace)
+
+	newScaleClient := func(replicas int32, updateErr error) (*fakescale.FakeScaleClient, *[]int32) {
+		var updates []int32
+		cl := &fakescale.FakeScaleClient{}
+		cl.AddReactor("get", "statefulsets", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &autoscalingv1.Scale{
+				ObjectMeta: metav1.ObjectMeta{Name: "teststs", Namespace: "testns"},
+				Spec:       autoscalingv1.ScaleSpec{Replicas: replicas},
+			}, nil
+		})
+		cl.AddReactor("update", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			obj := action.(k8stesting.UpdateAction).GetObject().(*autoscalingv1.Scale)
+			updates = append(updates, obj.Spec.Replicas)
+			return true, obj, updateErr
+		})
+		return cl, &updates
+	}
+
+	// a workload without replicas is scaled to one
+	cl, updates := newScaleClient(0, nil)
+	scaled, err := ScaleFromZero(ctx, cl, mapper, "apps/v1", "StatefulSet", "testns", "teststs")
+	r.NoError(err)
+	r.True(scaled)
+	r.Equal([]int32{1}, *updates)
+
+	// a workload with replicas is left alone
+	cl, updates = newScaleClient(2, nil)
+	scaled, err = ScaleFromZero(ctx, cl, mapper, "apps/v1", "StatefulSet", "testns", "teststs")
+	r.NoError(err)
+	r.False(scaled)
+	r.Empty(*updates)
+
+	// another client scaled the workload first
+	conflict := errors.NewConflict(
+		schema.GroupResource{Group: "apps", Resource: "statefulsets"},
+		"teststs",
+		nil,
+	)
+	cl, _ = newScaleClient(0, conflict)
+	scaled, err = ScaleFromZero(ctx, cl, mapper, "apps/v1", "StatefulSet", "testns", "teststs")
+	r.NoError(err)
+	r.False(scaled)
+
+	// unknown kinds can't be scaled
+	cl, _ = newScaleClient(0, nil)
+	_, err = ScaleFromZero(ctx, cl, mapper, "argoproj.io/v1alpha1", "Rollout", "testns", "teststs")
+	r.Error(err)
+}
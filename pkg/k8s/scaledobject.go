@@ -4,7 +4,6 @@ import (
 	"strings"
 
 	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
-	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/pointer"
 )
@@ -17,11 +16,14 @@ const (
 	mkHosts         = "hosts"
 )
 
-// NewScaledObject creates a new ScaledObject in memory
+// NewScaledObject creates a new ScaledObject in memory that scales
+// the workload with the given API version, kind and name
 func NewScaledObject(
 	namespace string,
 	name string,
-	deploymentName string,
+	scaleTargetAPIVersion string,
+	scaleTargetKind string,
+	scaleTargetName string,
 	scalerAddress string,
 	hosts []string,
 	minReplicas *int32,
@@ -40,9 +42,9 @@ func NewScaledObject(
 		},
 		Spec: kedav1alpha1.ScaledObjectSpec{
 			ScaleTargetRef: &kedav1alpha1.ScaleTarget{
-				APIVersion: appsv1.SchemeGroupVersion.Identifier(),
-				Kind:       ObjectKind(&appsv1.Deployment{}),
-				Name:       deploymentName,
+				APIVersion: scaleTargetAPIVersion,
+				Kind:       scaleTargetKind,
+				Name:       scaleTargetName,
 			},
 			PollingInterval: pointer.Int32(soPollingInterval),
 			CooldownPeriod:  cooldownPeriod,
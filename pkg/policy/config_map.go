@@ -0,0 +1,154 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/kedacore/http-add-on/pkg/k8s"
+)
+
+const (
+	// the name of the ConfigMap that stores the access policies
+	ConfigMapAccessPoliciesName = "keda-http-add-on-access-policies"
+	// the key in the ConfigMap data that stores the JSON access policies
+	configMapAccessPoliciesKey = "access-policies"
+)
+
+// SaveTableToConfigMap saves the contents of table to the Data field in
+// configMap
+func SaveTableToConfigMap(table *Table, configMap *corev1.ConfigMap) error {
+	tableAsJSON, err := table.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[configMapAccessPoliciesKey] = string(tableAsJSON)
+	return nil
+}
+
+// FetchTableFromConfigMap fetches the Data field from configMap, converts it
+// to a policy table, and returns it. A ConfigMap without policies yields
+// an empty table
+func FetchTableFromConfigMap(configMap *corev1.ConfigMap) (*Table, error) {
+	ret := NewTable()
+	data, found := configMap.Data[configMapAccessPoliciesKey]
+	if !found {
+		return ret, nil
+	}
+	if err := ret.UnmarshalJSON([]byte(data)); err != nil {
+		return nil, errors.Wrap(
+			err,
+			fmt.Sprintf(
+				"error decoding '%s' key in %s ConfigMap",
+				configMapAccessPoliciesKey,
+				ConfigMapAccessPoliciesName,
+			),
+		)
+	}
+	return ret, nil
+}
+
+// GetTable fetches the ConfigMap that stores the access policies, decodes
+// it and calls table.Replace(newTable). If the ConfigMap doesn't exist,
+// there are no access policies and table is left empty
+func GetTable(
+	ctx context.Context,
+	lggr logr.Logger,
+	getter k8s.ConfigMapGetter,
+	table *Table,
+) error {
+	lggr = lggr.WithName("pkg.policy.GetTable")
+
+	cm, err := getter.Get(
+		ctx,
+		ConfigMapAccessPoliciesName,
+		metav1.GetOptions{},
+	)
+	if k8serrors.IsNotFound(err) {
+		lggr.Info(
+			"access policies ConfigMap not found, allowing all calls until it is created",
+			"configMapName",
+			ConfigMapAccessPoliciesName,
+		)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(
+			err,
+			fmt.Sprintf(
+				"failed to fetch ConfigMap %s",
+				ConfigMapAccessPoliciesName,
+			),
+		)
+	}
+	newTable, err := FetchTableFromConfigMap(cm)
+	if err != nil {
+		return err
+	}
+	table.Replace(newTable)
+	return nil
+}
+
+// StartConfigMapPolicyUpdater watches the ConfigMap called
+// ConfigMapAccessPoliciesName in the given namespace ns with cmInformer,
+// and stores its policies into table whenever it changes. If the
+// ConfigMap is deleted, table keeps the last known policies until it is
+// created again, so that losing it doesn't open the services that the
+// policies govern to every namespace.
+//
+// cmInformer is shared with the routing table updater, which starts it.
+// This function returns an appropriate non-nil error if ctx.Done() receives
+func StartConfigMapPolicyUpdater(
+	ctx context.Context,
+	lggr logr.Logger,
+	cmInformer *k8s.InformerConfigMapUpdater,
+	ns string,
+	table *Table,
+) error {
+	lggr = lggr.WithName("pkg.policy.StartConfigMapPolicyUpdater")
+
+	watcher, err := cmInformer.Watch(ns, ConfigMapAccessPoliciesName)
+	if err != nil {
+		return err
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case event := <-watcher.ResultChan():
+			cm, ok := event.Object.(*corev1.ConfigMap)
+			// Theoretically this will not happen
+			if !ok {
+				lggr.Info(
+					"The event object observed is not a configmap",
+				)
+				continue
+			}
+			if event.Type == watch.Deleted {
+				lggr.Info(
+					"access policies ConfigMap was deleted, keeping the last known policies",
+					"configMapName",
+					ConfigMapAccessPoliciesName,
+				)
+				continue
+			}
+			newTable, err := FetchTableFromConfigMap(cm)
+			if err != nil {
+				lggr.Error(err, "failed decoding access policies, keeping the previous ones")
+				continue
+			}
+			table.Replace(newTable)
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "context is done")
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package policy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kedacore/http-add-on/pkg/k8s"
+)
+
+func TestGetTable(t *testing.T) {
+	ctx := context.Background()
+	r := require.New(t)
+
+	saved := NewTable()
+	saved.Set(Key("payments", "ledger"), Policy{
+		Namespace:         "payments",
+		AllowedNamespaces: []string{"billing"},
+	})
+	cm := &corev1.ConfigMap{}
+	r.NoError(SaveTableToConfigMap(saved, cm))
+
+	table := NewTable()
+	r.NoError(GetTable(ctx, logr.Discard(), k8s.FakeConfigMapGetter{ConfigMap: cm}, table))
+	r.Equal(saved.m, table.m)
+
+	// a missing ConfigMap means that there are no policies
+	notFound := k8serrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, ConfigMapAccessPoliciesName)
+	r.NoError(GetTable(ctx, logr.Discard(), k8s.FakeConfigMapGetter{Err: notFound}, table))
+	r.Equal(saved.m, table.m)
+
+	// a ConfigMap without policies, too
+	r.NoError(GetTable(ctx, logr.Discard(), k8s.FakeConfigMapGetter{ConfigMap: &corev1.ConfigMap{}}, table))
+	r.Empty(table.m)
+}
+
+// deleting the ConfigMap shouldn't open the
+// services that the policies govern
+func TestStartConfigMapPolicyUpdaterDelete(t *testing.T) {
+	const ns = "testns"
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+
+	saved := NewTable()
+	saved.Set(Key("payments", "ledger"), Policy{
+		Namespace:         "payments",
+		AllowedNamespaces: []string{"billing"},
+	})
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigMapAccessPoliciesName,
+			Namespace: ns,
+		},
+	}
+	r.NoError(SaveTableToConfigMap(saved, cm))
+	cl := fake.NewSimpleClientset()
+	cmInformer := k8s.NewInformerConfigMapUpdater(logr.Discard(), cl, time.Minute, ns)
+	go func() {
+		_ = cmInformer.Start(ctx)
+	}()
+	table := NewTable()
+	go func() {
+		_ = StartConfigMapPolicyUpdater(ctx, logr.Discard(), cmInformer, ns, table)
+	}()
+
+	denied := func() bool {
+		return table.Check("shop", "payments", "ledger") != nil
+	}
+	// the informer may only start watching after the ConfigMap
+	// was created, so it is created until its policies arrive
+	r.Eventually(func() bool {
+		_, _ = cl.CoreV1().ConfigMaps(ns).Create(ctx, cm, metav1.CreateOptions{})
+		return denied()
+	}, 5*time.Second, 50*time.Millisecond)
+
+	r.NoError(cl.CoreV1().ConfigMaps(ns).Delete(ctx, cm.Name, metav1.DeleteOptions{}))
+	r.Never(func() bool {
+		return !denied()
+	}, 500*time.Millisecond, 10*time.Millisecond)
+}
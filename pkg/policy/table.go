@@ -0,0 +1,165 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrAccessDenied is returned when no access policy allows
+// a caller to reach a service
+var ErrAccessDenied = errors.New("access denied")
+
+// namespacePrefixSuffix marks an allowed namespace as a prefix
+// that matches every namespace starting with it
+const namespacePrefixSuffix = "*"
+
+// Policy allows callers from AllowedNamespaces to reach Services
+// in Namespace
+type Policy struct {
+	Namespace string
+	// Services are the names of the services that the policy applies
+	// to. A policy without Services applies to every service in Namespace
+	Services []string `json:",omitempty"`
+	// AllowedNamespaces are the namespaces that callers may come from.
+	// An entry ending with "*" matches every namespace that starts with
+	// the rest of the entry, and "*" on its own matches any namespace
+	AllowedNamespaces []string
+}
+
+// appliesTo returns true if p governs access to service in namespace
+func (p Policy) appliesTo(namespace, service string) bool {
+	if p.Namespace != namespace {
+		return false
+	}
+	if len(p.Services) == 0 {
+		return true
+	}
+	for _, svc := range p.Services {
+		if svc == service {
+			return true
+		}
+	}
+	return false
+}
+
+// allows returns true if p lets callers from callerNs through
+func (p Policy) allows(callerNs string) bool {
+	if callerNs == "" {
+		return false
+	}
+	for _, allowed := range p.AllowedNamespaces {
+		if strings.HasSuffix(allowed, namespacePrefixSuffix) {
+			if strings.HasPrefix(callerNs, strings.TrimSuffix(allowed, namespacePrefixSuffix)) {
+				return true
+			}
+		} else if allowed == callerNs {
+			return true
+		}
+	}
+	return false
+}
+
+// Table holds the access policies of the cluster, keyed by
+// the namespace and name of the object that declared them
+type Table struct {
+	m map[string]Policy
+	l *sync.RWMutex
+}
+
+func NewTable() *Table {
+	return &Table{
+		m: make(map[string]Policy),
+		l: new(sync.RWMutex),
+	}
+}
+
+// Key returns the key of the policy declared by the object with
+// the given name in the given namespace
+func Key(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// Check returns nil if callerNs may reach service in namespace, and an
+// error wrapping ErrAccessDenied that explains why not otherwise.
+//
+// Services that no policy applies to may be reached from anywhere, and
+// every service may be reached from its own namespace. Otherwise, one of
+// the policies of the service must allow callerNs. An empty callerNs
+// stands for a caller whose namespace is unknown, which no policy allows
+func (t *Table) Check(callerNs, namespace, service string) error {
+	if callerNs != "" && callerNs == namespace {
+		return nil
+	}
+	t.l.RLock()
+	defer t.l.RUnlock()
+	governed := false
+	for _, p := range t.m {
+		if !p.appliesTo(namespace, service) {
+			continue
+		}
+		if p.allows(callerNs) {
+			return nil
+		}
+		governed = true
+	}
+	if !governed {
+		return nil
+	}
+	if callerNs == "" {
+		return fmt.Errorf(
+			"%w: service %s.%s has access policies, but the namespace of the caller is unknown",
+			ErrAccessDenied,
+			service,
+			namespace,
+		)
+	}
+	return fmt.Errorf(
+		"%w: no access policy of service %s.%s allows callers from namespace %s",
+		ErrAccessDenied,
+		service,
+		namespace,
+		callerNs,
+	)
+}
+
+// Set stores p under key in t, replacing the policy
+// that was stored under key, if any
+func (t *Table) Set(key string, p Policy) {
+	t.l.Lock()
+	defer t.l.Unlock()
+	t.m[key] = p
+}
+
+// Replace replaces t's policies with newTable's.
+//
+// This function is concurrency safe for t, but not for newTable.
+// The caller must ensure that no other goroutine is writing to
+// newTable at the time at which they call this function.
+func (t *Table) Replace(newTable *Table) {
+	t.l.Lock()
+	defer t.l.Unlock()
+	t.m = newTable.m
+}
+
+func (t *Table) MarshalJSON() ([]byte, error) {
+	t.l.RLock()
+	defer t.l.RUnlock()
+	var b bytes.Buffer
+	err := json.NewEncoder(&b).Encode(t.m)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (t *Table) UnmarshalJSON(data []byte) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+	t.m = map[string]Policy{}
+	b := bytes.NewBuffer(data)
+	return json.NewDecoder(b).Decode(&t.m)
+}
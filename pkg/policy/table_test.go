@@ -0,0 +1,73 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableCheck(t *testing.T) {
+	r := require.New(t)
+	table := NewTable()
+	table.Set(Key("payments", "ledger"), Policy{
+		Namespace:         "payments",
+		Services:          []string{"ledger"},
+		AllowedNamespaces: []string{"billing", "dp-team-a-*"},
+	})
+	table.Set(Key("payments", "all"), Policy{
+		Namespace:         "payments",
+		AllowedNamespaces: []string{"audit"},
+	})
+	table.Set(Key("public", "all"), Policy{
+		Namespace:         "public",
+		AllowedNamespaces: []string{"*"},
+	})
+
+	testCases := []struct {
+		callerNs  string
+		namespace string
+		service   string
+		allowed   bool
+	}{
+		{"billing", "payments", "ledger", true},
+		{"dp-team-a-dev", "payments", "ledger", true},
+		{"dp-team-b-dev", "payments", "ledger", false},
+		// policies for the whole namespace apply too
+		{"audit", "payments", "ledger", true},
+		{"billing", "payments", "cards", false},
+		{"audit", "payments", "cards", true},
+		// calls within a namespace are always allowed
+		{"payments", "payments", "cards", true},
+		// unless the namespace of the caller is unknown, as
+		// it always is with the header host resolver
+		{"", "payments", "cards", false},
+		{"anywhere", "public", "site", true},
+		// services without policies are open
+		{"anywhere", "other", "svc", true},
+		{"", "other", "svc", true},
+	}
+	for _, tc := range testCases {
+		err := table.Check(tc.callerNs, tc.namespace, tc.service)
+		if tc.allowed {
+			r.NoError(err, "%+v", tc)
+		} else {
+			r.ErrorIs(err, ErrAccessDenied, "%+v", tc)
+		}
+	}
+}
+
+func TestTableJSONRoundTrip(t *testing.T) {
+	r := require.New(t)
+	table := NewTable()
+	table.Set(Key("payments", "ledger"), Policy{
+		Namespace:         "payments",
+		Services:          []string{"ledger"},
+		AllowedNamespaces: []string{"billing"},
+	})
+
+	b, err := table.MarshalJSON()
+	r.NoError(err)
+	decoded := NewTable()
+	r.NoError(decoded.UnmarshalJSON(b))
+	r.Equal(table.m, decoded.m)
+}
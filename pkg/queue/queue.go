@@ -35,6 +35,11 @@ type Counter interface {
 	CountReader
 	// Resize resizes the queue size by delta for the given host.
 	Resize(host string, delta int) error
+	// ResizeWithin resizes the queue size by delta for the given host,
+	// unless that would take it over max, in which case it leaves the
+	// queue size unchanged and returns false. A max of 0 or less means
+	// there is no max.
+	ResizeWithin(host string, delta, max int) (bool, error)
 	// Ensure ensures that host is represented in this counter.
 	// If host already has a nonzero value, then it is unchanged. If
 	// it is missing, it is set to 0.
@@ -44,55 +49,143 @@ type Counter interface {
 	// false otherwise.
 	Remove(host string) bool
 
-	// PostponeResize sets the last request time for the given host
+	// PostponeResize keeps the last pending request of the given host
+	// counted until time. The next increment of the host takes its
+	// place, rather than counting as another pending request
 	PostponeResize(host string, time time.Time)
 
 	// ProcessPostponedResizes processes the postponed resizes
 	ProcessPostponedResizes(sleep time.Duration)
 }
 
+// WaitRecorder records how long requests waited in the interceptor
+// before they were forwarded.
+//
+// It is concurrency safe
+type WaitRecorder interface {
+	// RecordWait records that a request to host waited for d
+	RecordWait(host string, d time.Duration)
+}
+
 // Memory is a Counter implementation that
 // holds the HTTP queue in memory only. Always use
 // NewMemory to create one of these.
+//
+// Besides the pending requests, it tracks the rate of the
+// requests to each host over a sliding window, which Current
+// returns as the RPS of the host, and the 95th percentile of
+// the times that they waited in the interceptor over the same
+// window, which Current returns as the WaitP95 of the host
 type Memory struct {
 	countMap         map[string]int
+	rates            map[string]*requestRate
+	waits            map[string]*waitTimes
+	rateWindow       time.Duration
 	postponedResizes map[string]time.Time
 	postponeDuration time.Duration
 	shouldPostpone   bool
 	mut              *sync.RWMutex
 	logger           logr.Logger
+	changed          chan struct{}
 }
 
-// NewMemoryQueue creates a new empty in-memory queue
-func NewMemory(postponeDuration time.Duration, shouldPostpone bool, logger logr.Logger) *Memory {
+// NewMemoryQueue creates a new empty in-memory queue. The request
+// rates and wait times of its hosts are tracked over rateWindow,
+// in whole seconds
+func NewMemory(
+	postponeDuration time.Duration,
+	shouldPostpone bool,
+	rateWindow time.Duration,
+	logger logr.Logger,
+) *Memory {
 	lock := new(sync.RWMutex)
 
 	return &Memory{
 		countMap:         make(map[string]int),
+		rates:            make(map[string]*requestRate),
+		waits:            make(map[string]*waitTimes),
+		rateWindow:       rateWindow,
 		postponedResizes: make(map[string]time.Time),
 		postponeDuration: postponeDuration,
 		shouldPostpone:   shouldPostpone,
 		mut:              lock,
 		logger:           logger,
+		changed:          make(chan struct{}, 1),
+	}
+}
+
+// Changed returns a channel that receives a value after the counts
+// changed. Changes that happen before the value is received are
+// coalesced into it, so receivers should read all counts again
+func (r *Memory) Changed() <-chan struct{} {
+	return r.changed
+}
+
+func (r *Memory) notifyChanged() {
+	select {
+	case r.changed <- struct{}{}:
+	default:
 	}
 }
 
 // Resize changes the size of the queue. Further calls to Current() return
 // the newly calculated size if no other Resize() calls were made in the
-// interim.
+// interim. A positive delta counts as that many new requests towards
+// the request rate of host, and takes the place of the postponed
+// request of host if there is one
 func (r *Memory) Resize(host string, delta int) error {
 	r.mut.Lock()
 	defer r.mut.Unlock()
-	r.countMap[host] += delta
+	r.resize(host, delta)
 	return nil
 }
 
+// ResizeWithin is like Resize, but it checks and changes the size of
+// the queue at once, so that concurrent calls can't take the queue
+// over max. Resizes that would are dropped and don't count towards
+// the request rate of host
+func (r *Memory) ResizeWithin(host string, delta, max int) (bool, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	pending := r.countMap[host]
+	if _, ok := r.postponedResizes[host]; ok && delta > 0 {
+		// the postponed request isn't pending anymore
+		pending--
+	}
+	if max > 0 && pending+delta > max {
+		return false, nil
+	}
+	r.resize(host, delta)
+	return true, nil
+}
+
+// resize does the work of Resize. It must be
+// called with r.mut held for writing
+func (r *Memory) resize(host string, delta int) {
+	if _, ok := r.postponedResizes[host]; ok && delta > 0 {
+		// the new request takes the place of the postponed one
+		delete(r.postponedResizes, host)
+		r.countMap[host]--
+	}
+	r.countMap[host] += delta
+	if delta > 0 {
+		rate, ok := r.rates[host]
+		if !ok {
+			rate = newRequestRate(r.rateWindow)
+			r.rates[host] = rate
+		}
+		rate.add(time.Now(), delta)
+	}
+	r.notifyChanged()
+}
+
 func (r *Memory) Ensure(host string) {
 	r.mut.Lock()
 	defer r.mut.Unlock()
 	_, ok := r.countMap[host]
 	if !ok {
 		r.countMap[host] = 0
+		r.notifyChanged()
 	}
 }
 
@@ -101,15 +194,55 @@ func (r *Memory) Remove(host string) bool {
 	defer r.mut.Unlock()
 	_, ok := r.countMap[host]
 	delete(r.countMap, host)
+	delete(r.postponedResizes, host)
+	delete(r.rates, host)
+	delete(r.waits, host)
+	if ok {
+		r.notifyChanged()
+	}
 	return ok
 }
 
-// Current returns the current size of the queue.
+// RecordWait records that a request to host waited for d before
+// it was forwarded. It doesn't change the counts of the queue
+func (r *Memory) RecordWait(host string, d time.Duration) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	waits, ok := r.waits[host]
+	if !ok {
+		waits = newWaitTimes(r.rateWindow)
+		r.waits[host] = waits
+	}
+	waits.add(time.Now(), d)
+}
+
+// Current returns the current size of the queue, along with the
+// request rates and 95th percentile wait times of the hosts that
+// had requests within the window. Rates and wait times without
+// requests in the window are forgotten
 func (r *Memory) Current() (*Counts, error) {
-	r.mut.RLock()
-	defer r.mut.RUnlock()
+	r.mut.Lock()
+	defer r.mut.Unlock()
 	cts := NewCounts()
-	cts.Counts = r.countMap
+	for host, count := range r.countMap {
+		cts.Counts[host] = count
+	}
+	now := time.Now()
+	for host, rate := range r.rates {
+		if rate.empty(now) {
+			delete(r.rates, host)
+			continue
+		}
+		cts.RPS[host] = rate.perSecond(now)
+	}
+	for host, waits := range r.waits {
+		p95, ok := waits.percentile(now, 0.95)
+		if !ok {
+			delete(r.waits, host)
+			continue
+		}
+		cts.WaitP95[host] = p95
+	}
 	return cts, nil
 }
 
@@ -154,6 +287,9 @@ func (r *Memory) ProcessPostponedResizes(sleep time.Duration) {
 			r.countMap[host] = 0
 			delete(r.postponedResizes, host)
 		}
+		if len(hostsToModify) > 0 {
+			r.notifyChanged()
+		}
 		r.mut.Unlock()
 	}
 }
@@ -3,12 +3,13 @@ package queue
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 )
 
 // Counts is a snapshot of the HTTP pending request queue counts
 // for each host.
 // This is a json.Marshaler, json.Unmarshaler, and fmt.Stringer
-// implementation.
+// implementation. Its JSON only holds the counts.
 //
 // Use NewQueueCounts to create a new one of these.
 type Counts struct {
@@ -16,12 +17,21 @@ type Counts struct {
 	json.Unmarshaler
 	fmt.Stringer
 	Counts map[string]int
+	// RPS holds the requests per second of each host over a
+	// sliding window. Hosts without recent requests are missing
+	RPS map[string]float64
+	// WaitP95 holds the 95th percentile of the times that the
+	// requests to each host waited before they were forwarded,
+	// over the same window as RPS
+	WaitP95 map[string]time.Duration
 }
 
 // NewQueueCounts creates a new empty QueueCounts struct
 func NewCounts() *Counts {
 	return &Counts{
-		Counts: map[string]int{},
+		Counts:  map[string]int{},
+		RPS:     map[string]float64{},
+		WaitP95: map[string]time.Duration{},
 	}
 }
 
@@ -48,3 +58,37 @@ func (q *Counts) UnmarshalJSON(data []byte) error {
 func (q *Counts) String() string {
 	return fmt.Sprintf("%v", q.Counts)
 }
+
+// Usage is the pending request count of a single host along
+// with the maximum number of pending requests that it accepts
+type Usage struct {
+	Count int `json:"count"`
+	// MaxPendingRequests is zero if the host has no limit
+	MaxPendingRequests int `json:"maxPendingRequests,omitempty"`
+	// RPS is the recent rate of requests per second to the host
+	RPS float64 `json:"rps,omitempty"`
+	// WaitP95Millis is the recent 95th percentile of the times that
+	// the requests to the host waited before they were forwarded
+	WaitP95Millis int64 `json:"waitP95Ms,omitempty"`
+}
+
+// Usages returns the Usage of every host in q. limit returns the
+// maximum number of pending requests of a host, and false if it
+// has none. A nil limit reports no limits
+func (q *Counts) Usages(limit LimitFunc) map[string]Usage {
+	ret := make(map[string]Usage, len(q.Counts))
+	for host, count := range q.Counts {
+		usage := Usage{
+			Count:         count,
+			RPS:           q.RPS[host],
+			WaitP95Millis: q.WaitP95[host].Milliseconds(),
+		}
+		if limit != nil {
+			if max, ok := limit(host); ok {
+				usage.MaxPendingRequests = max
+			}
+		}
+		ret[host] = usage
+	}
+	return ret
+}
@@ -20,18 +20,21 @@ type FakeCounter struct {
 }
 
 // ShouldPostponeResize implements Counter.
+// FakeCounter never postpones resizes
 func (f *FakeCounter) ShouldPostponeResize() bool {
-	panic("unimplemented")
+	return false
 }
 
 // Count implements Counter.
 func (f *FakeCounter) Count(host string) int {
-	panic("unimplemented")
+	f.mapMut.RLock()
+	defer f.mapMut.RUnlock()
+	return f.RetMap[host]
 }
 
 // PostponeDuration implements Counter.
 func (f *FakeCounter) PostponeDuration() time.Duration {
-	panic("unimplemented")
+	return 0
 }
 
 // PostponeResize implements Counter.
@@ -68,6 +71,27 @@ func (f *FakeCounter) Resize(host string, i int) error {
 	return nil
 }
 
+// ResizeWithin implements Counter. It only sends
+// on ResizedCh if the resize is within max
+func (f *FakeCounter) ResizeWithin(host string, delta, max int) (bool, error) {
+	f.mapMut.Lock()
+	if max > 0 && f.RetMap[host]+delta > max {
+		f.mapMut.Unlock()
+		return false, nil
+	}
+	f.RetMap[host] += delta
+	f.mapMut.Unlock()
+	select {
+	case f.ResizedCh <- HostAndCount{Host: host, Count: delta}:
+	case <-time.After(f.ResizeTimeout):
+		return true, fmt.Errorf(
+			"FakeCounter.ResizeWithin timeout after %s",
+			f.ResizeTimeout,
+		)
+	}
+	return true, nil
+}
+
 func (f *FakeCounter) Ensure(host string) {
 	f.mapMut.Lock()
 	defer f.mapMut.Unlock()
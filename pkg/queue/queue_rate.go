@@ -0,0 +1,67 @@
+package queue
+
+import (
+	"time"
+)
+
+// requestRate counts the requests to a single host in one second
+// buckets, so that their rate over a sliding window can be computed.
+// It is not concurrency safe
+type requestRate struct {
+	// buckets is a ring of the request counts of the seconds in
+	// the window. The bucket of the second s is buckets[s%len(buckets)]
+	buckets []int
+	// last is the unix second of the latest bucket
+	last int64
+}
+
+func newRequestRate(window time.Duration) *requestRate {
+	size := int(window / time.Second)
+	if size < 1 {
+		size = 1
+	}
+	return &requestRate{
+		buckets: make([]int, size),
+	}
+}
+
+// advance clears the buckets of the seconds between the
+// latest bucket and now, so that the bucket of now can be used
+func (r *requestRate) advance(now int64) {
+	if now <= r.last {
+		return
+	}
+	if now-r.last >= int64(len(r.buckets)) {
+		for i := range r.buckets {
+			r.buckets[i] = 0
+		}
+	} else {
+		for s := r.last + 1; s <= now; s++ {
+			r.buckets[s%int64(len(r.buckets))] = 0
+		}
+	}
+	r.last = now
+}
+
+// add counts n requests at now
+func (r *requestRate) add(now time.Time, n int) {
+	sec := now.Unix()
+	r.advance(sec)
+	r.buckets[sec%int64(len(r.buckets))] += n
+}
+
+// perSecond returns the average number of requests
+// per second in the window that ends at now
+func (r *requestRate) perSecond(now time.Time) float64 {
+	r.advance(now.Unix())
+	total := 0
+	for _, n := range r.buckets {
+		total += n
+	}
+	return float64(total) / float64(len(r.buckets))
+}
+
+// empty returns true if no requests were counted in the window
+func (r *requestRate) empty(now time.Time) bool {
+	return r.perSecond(now) == 0
+}
@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestRate(t *testing.T) {
+	r := require.New(t)
+	start := time.Unix(1000, 0)
+	rate := newRequestRate(10 * time.Second)
+
+	r.True(rate.empty(start))
+	rate.add(start, 5)
+	rate.add(start.Add(500*time.Millisecond), 5)
+	rate.add(start.Add(3*time.Second), 10)
+	r.Equal(2.0, rate.perSecond(start.Add(3*time.Second)))
+
+	// the requests of the first second slide out of the window
+	r.Equal(1.0, rate.perSecond(start.Add(10*time.Second)))
+	// and all of them do after a whole window without requests
+	r.True(rate.empty(start.Add(time.Minute)))
+
+	// windows shorter than a second have a single bucket
+	rate = newRequestRate(0)
+	rate.add(start, 3)
+	r.Equal(3.0, rate.perSecond(start))
+	r.True(rate.empty(start.Add(time.Second)))
+}
+
+func TestMemoryRPS(t *testing.T) {
+	r := require.New(t)
+	q := NewMemory(time.Second, false, 10*time.Second, logr.Discard())
+	q.Ensure("host1")
+	r.NoError(q.Resize("host2", 5))
+	r.NoError(q.Resize("host2", -5))
+	r.NoError(q.Resize("host2", 5))
+
+	cur, err := q.Current()
+	r.NoError(err)
+	r.Equal(map[string]int{"host1": 0, "host2": 5}, cur.Counts)
+	// finished requests don't lower the rate
+	r.Equal(map[string]float64{"host2": 1.0}, cur.RPS)
+
+	r.True(q.Remove("host2"))
+	cur, err = q.Current()
+	r.NoError(err)
+	r.Empty(cur.RPS)
+}
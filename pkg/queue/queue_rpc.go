@@ -6,17 +6,30 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 )
 
-const countsPath = "/queue"
+const (
+	countsPath = "/queue"
+	// detailsParam is the query parameter that makes the counts
+	// route return a Usage for each host instead of its count
+	detailsParam = "details"
+)
+
+// LimitFunc returns the maximum number of pending requests
+// for host, or false if host has no limit
+type LimitFunc func(host string) (int, bool)
 
-func AddCountsRoute(lggr logr.Logger, mux *http.ServeMux, q CountReader) {
+// AddCountsRoute adds the route that serves the counts of q to mux.
+// Requests with the details query parameter set to true get the
+// Usage of each host, with its limit found by limit, instead
+func AddCountsRoute(lggr logr.Logger, mux *http.ServeMux, q CountReader, limit LimitFunc) {
 	lggr = lggr.WithName("pkg.queue.AddCountsRoute")
 	lggr.Info("adding queue counts route", "path", countsPath)
-	mux.Handle(countsPath, newSizeHandler(lggr, q))
+	mux.Handle(countsPath, newSizeHandler(lggr, q, limit))
 }
 
 // newForwardingHandler takes in the service URL for the app backend
@@ -25,6 +38,7 @@ func AddCountsRoute(lggr logr.Logger, mux *http.ServeMux, q CountReader) {
 func newSizeHandler(
 	lggr logr.Logger,
 	q CountReader,
+	limit LimitFunc,
 ) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cur, err := q.Current()
@@ -41,7 +55,11 @@ func newSizeHandler(
 			}
 			return
 		}
-		if err := json.NewEncoder(w).Encode(cur); err != nil {
+		var resp interface{} = cur
+		if r.URL.Query().Get(detailsParam) == "true" {
+			resp = cur.Usages(limit)
+		}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
 			lggr.Error(err, "encoding QueueCounts")
 			w.WriteHeader(500)
 			if _, err := w.Write([]byte(
@@ -60,6 +78,11 @@ func newSizeHandler(
 // GetQueueCounts issues an RPC call to get the queue counts
 // from the given hostAndPort. Note that the hostAndPort should
 // not end with a "/" and shouldn't include a path.
+//
+// The counts are requested with their details,
+// so that they include the request rates too.
+// Interceptors that don't know about the details
+// return the plain counts, which are accepted too
 func GetCounts(
 	ctx context.Context,
 	lggr logr.Logger,
@@ -67,7 +90,12 @@ func GetCounts(
 	interceptorURL url.URL,
 ) (*Counts, error) {
 	interceptorURL.Path = countsPath
-	resp, err := httpCl.Get(interceptorURL.String())
+	interceptorURL.RawQuery = url.Values{detailsParam: {"true"}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, interceptorURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpCl.Do(req)
 	if err != nil {
 		errMsg := fmt.Sprintf(
 			"requesting the queue counts from %s",
@@ -76,8 +104,25 @@ func GetCounts(
 		return nil, errors.Wrap(err, errMsg)
 	}
 	defer resp.Body.Close()
-	counts := NewCounts()
-	if err := json.NewDecoder(resp.Body).Decode(counts); err != nil {
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(
+			"requesting the queue counts from %s: unexpected status %d",
+			interceptorURL.String(),
+			resp.StatusCode,
+		)
+	}
+	raw := map[string]json.RawMessage{}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, errors.Wrap(
+			err,
+			fmt.Sprintf(
+				"decoding response from the interceptor at %s",
+				interceptorURL.String(),
+			),
+		)
+	}
+	usages, err := decodeUsages(raw)
+	if err != nil {
 		return nil, errors.Wrap(
 			err,
 			fmt.Sprintf(
@@ -87,5 +132,32 @@ func GetCounts(
 		)
 	}
 
+	counts := NewCounts()
+	for host, usage := range usages {
+		counts.Counts[host] = usage.Count
+		if usage.RPS > 0 {
+			counts.RPS[host] = usage.RPS
+		}
+		if usage.WaitP95Millis > 0 {
+			counts.WaitP95[host] = time.Duration(usage.WaitP95Millis) * time.Millisecond
+		}
+	}
 	return counts, nil
 }
+
+// decodeUsages decodes the Usage of each host from raw. The
+// values of raw are either a Usage or, from interceptors that
+// don't serve the details, a plain count
+func decodeUsages(raw map[string]json.RawMessage) (map[string]Usage, error) {
+	usages := make(map[string]Usage, len(raw))
+	for host, val := range raw {
+		var usage Usage
+		if err := json.Unmarshal(val, &usage); err != nil {
+			if err := json.Unmarshal(val, &usage.Count); err != nil {
+				return nil, fmt.Errorf("decoding the counts of host %s: %w", host, err)
+			}
+		}
+		usages[host] = usage
+	}
+	return usages, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"net/http"
 	"testing"
 
 	"github.com/go-logr/logr"
@@ -21,7 +22,7 @@ func TestQueueSizeHandlerSuccess(t *testing.T) {
 		err:     nil,
 	}
 
-	handler := newSizeHandler(lggr, reader)
+	handler := newSizeHandler(lggr, reader, nil)
 	req, rec := pkghttp.NewTestCtx("GET", "/queue")
 	handler.ServeHTTP(rec, req)
 	r.Equal(200, rec.Code, "response code")
@@ -47,7 +48,7 @@ func TestQueueSizeHandlerFail(t *testing.T) {
 		err:     errors.New("test error"),
 	}
 
-	handler := newSizeHandler(lggr, reader)
+	handler := newSizeHandler(lggr, reader, nil)
 	req, rec := pkghttp.NewTestCtx("GET", "/queue")
 	handler.ServeHTTP(rec, req)
 	r.Equal(500, rec.Code, "response code")
@@ -62,7 +63,7 @@ func TestQueueSizeHandlerIntegration(t *testing.T) {
 		err:     nil,
 	}
 
-	hdl := kedanet.NewTestHTTPHandlerWrapper(newSizeHandler(lggr, reader))
+	hdl := kedanet.NewTestHTTPHandlerWrapper(newSizeHandler(lggr, reader, nil))
 	srv, url, err := kedanet.StartTestServer(hdl)
 	r.NoError(err)
 	defer srv.Close()
@@ -76,3 +77,56 @@ func TestQueueSizeHandlerIntegration(t *testing.T) {
 	reqs := hdl.IncomingRequests()
 	r.Equal(1, len(reqs))
 }
+
+func TestQueueSizeHandlerDetails(t *testing.T) {
+	lggr := logr.Discard()
+	r := require.New(t)
+	reader := &FakeCountReader{
+		current: 7,
+		err:     nil,
+	}
+	limit := func(host string) (int, bool) {
+		if host == "sample.com" {
+			return 10, true
+		}
+		return 0, false
+	}
+
+	handler := newSizeHandler(lggr, reader, limit)
+	req, rec := pkghttp.NewTestCtx("GET", "/queue?details=true")
+	handler.ServeHTTP(rec, req)
+	r.Equal(200, rec.Code, "response code")
+	respMap := map[string]Usage{}
+	r.NoError(json.NewDecoder(rec.Body).Decode(&respMap))
+	r.Equal(
+		map[string]Usage{"sample.com": {Count: 7, MaxPendingRequests: 10}},
+		respMap,
+	)
+
+	// without the details parameter, the response stays
+	// a plain map of counts
+	req, rec = pkghttp.NewTestCtx("GET", "/queue")
+	handler.ServeHTTP(rec, req)
+	r.Equal(200, rec.Code, "response code")
+	countsMap := map[string]int{}
+	r.NoError(json.NewDecoder(rec.Body).Decode(&countsMap))
+	r.Equal(map[string]int{"sample.com": 7}, countsMap)
+}
+
+// the plain counts of interceptors that don't
+// serve the details should still be accepted
+func TestGetCountsWithoutDetails(t *testing.T) {
+	ctx := context.Background()
+	lggr := logr.Discard()
+	r := require.New(t)
+	hdl := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"sample.com":7}`))
+	})
+	srv, url, err := kedanet.StartTestServer(hdl)
+	r.NoError(err)
+	defer srv.Close()
+	counts, err := GetCounts(ctx, lggr, srv.Client(), *url)
+	r.NoError(err)
+	r.Equal(map[string]int{"sample.com": 7}, counts.Counts)
+	r.Empty(counts.RPS)
+}
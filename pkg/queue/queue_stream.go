@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+
+	externalscaler "github.com/kedacore/http-add-on/proto"
+)
+
+// ChangeNotifier is a CountReader that signals when its counts change
+type ChangeNotifier interface {
+	CountReader
+	// Changed returns a channel that receives a value after the counts
+	// changed. A single value may stand for any number of changes
+	Changed() <-chan struct{}
+}
+
+// StreamCounts streams the counts of q to the scaler through client
+// on behalf of the interceptor named interceptor. The first update
+// holds all counts of q, and every update after it only holds the
+// hosts whose counts changed since the previous one, with hosts that
+// were removed sent as 0.
+//
+// StreamCounts returns when ctx is done, or with a non-nil error
+// as soon as the stream fails
+func StreamCounts(
+	ctx context.Context,
+	lggr logr.Logger,
+	client externalscaler.QueueCountsClient,
+	interceptor string,
+	q ChangeNotifier,
+) error {
+	lggr = lggr.WithName("pkg.queue.StreamCounts")
+	stream, err := client.StreamCounts(ctx)
+	if err != nil {
+		return errors.Wrap(err, "opening queue counts stream")
+	}
+
+	prev := map[string]int{}
+	full := true
+	for {
+		cur, err := q.Current()
+		if err != nil {
+			return errors.Wrap(err, "getting queue counts")
+		}
+		update := &externalscaler.QueueCountsUpdate{
+			Interceptor: interceptor,
+			Counts:      map[string]int64{},
+			Full:        full,
+		}
+		for host, count := range cur.Counts {
+			if prevCount, ok := prev[host]; full || !ok || prevCount != count {
+				update.Counts[host] = int64(count)
+			}
+		}
+		for host := range prev {
+			if _, ok := cur.Counts[host]; !ok {
+				update.Counts[host] = 0
+			}
+		}
+		if full || len(update.Counts) > 0 {
+			if err := stream.Send(update); err != nil {
+				return errors.Wrap(err, "sending queue counts")
+			}
+			lggr.V(1).Info("sent queue counts", "counts", update.Counts, "full", full)
+		}
+		prev = cur.Counts
+		full = false
+
+		select {
+		case <-ctx.Done():
+			_, _ = stream.CloseAndRecv()
+			return nil
+		case <-q.Changed():
+		}
+	}
+}
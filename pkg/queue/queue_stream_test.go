@@ -0,0 +1,95 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	externalscaler "github.com/kedacore/http-add-on/proto"
+)
+
+type fakeCountsStream struct {
+	grpc.ClientStream
+	updates chan *externalscaler.QueueCountsUpdate
+}
+
+func (f *fakeCountsStream) Send(update *externalscaler.QueueCountsUpdate) error {
+	f.updates <- update
+	return nil
+}
+
+func (f *fakeCountsStream) CloseAndRecv() (*externalscaler.QueueCountsAck, error) {
+	return &externalscaler.QueueCountsAck{}, nil
+}
+
+type fakeCountsClient struct {
+	stream *fakeCountsStream
+}
+
+func (f *fakeCountsClient) StreamCounts(
+	context.Context,
+	...grpc.CallOption,
+) (externalscaler.QueueCounts_StreamCountsClient, error) {
+	return f.stream, nil
+}
+
+func TestStreamCounts(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+
+	q := NewMemory(time.Second, false, time.Minute, logr.Discard())
+	r.NoError(q.Resize("host1", 1))
+	q.Ensure("host2")
+	// drain the change of the setup, so that the
+	// first update only holds the initial counts
+	<-q.Changed()
+
+	client := &fakeCountsClient{
+		stream: &fakeCountsStream{
+			updates: make(chan *externalscaler.QueueCountsUpdate, 10),
+		},
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- StreamCounts(ctx, logr.Discard(), client, "interceptor1", q)
+	}()
+	next := func() *externalscaler.QueueCountsUpdate {
+		select {
+		case update := <-client.stream.updates:
+			return update
+		case <-time.After(5 * time.Second):
+			r.FailNow("no update was sent")
+			return nil
+		}
+	}
+
+	// the first update holds all counts
+	update := next()
+	r.True(update.Full)
+	r.Equal("interceptor1", update.Interceptor)
+	r.Equal(map[string]int64{"host1": 1, "host2": 0}, update.Counts)
+
+	// later updates only hold the changed counts
+	r.NoError(q.Resize("host2", 2))
+	update = next()
+	r.False(update.Full)
+	r.Equal(map[string]int64{"host2": 2}, update.Counts)
+
+	// removed hosts are sent as zero
+	r.True(q.Remove("host1"))
+	update = next()
+	r.Equal(map[string]int64{"host1": 0}, update.Counts)
+
+	done()
+	select {
+	case err := <-errCh:
+		r.NoError(err)
+	case <-time.After(5 * time.Second):
+		r.Fail("StreamCounts did not return")
+	}
+}
@@ -0,0 +1,82 @@
+package queue
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryResizeWithin(t *testing.T) {
+	r := require.New(t)
+	q := NewMemory(time.Second, false, 10*time.Second, logr.Discard())
+
+	ok, err := q.ResizeWithin("host1", 2, 2)
+	r.NoError(err)
+	r.True(ok)
+	ok, err = q.ResizeWithin("host1", 1, 2)
+	r.NoError(err)
+	r.False(ok)
+	r.Equal(2, q.Count("host1"))
+
+	// a max of 0 means there is no max
+	ok, err = q.ResizeWithin("host1", 1, 0)
+	r.NoError(err)
+	r.True(ok)
+	r.Equal(3, q.Count("host1"))
+
+	// dropped resizes don't count towards the rate
+	cur, err := q.Current()
+	r.NoError(err)
+	r.Equal(map[string]float64{"host1": 0.3}, cur.RPS)
+}
+
+// concurrent resizes should never take the queue over max
+func TestMemoryResizeWithinConcurrent(t *testing.T) {
+	r := require.New(t)
+	const max = 10
+	q := NewMemory(time.Second, false, 10*time.Second, logr.Discard())
+
+	var wg sync.WaitGroup
+	var mut sync.Mutex
+	admitted := 0
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := q.ResizeWithin("host1", 1, max)
+			if err == nil && ok {
+				mut.Lock()
+				admitted++
+				mut.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	r.Equal(max, admitted)
+	r.Equal(max, q.Count("host1"))
+}
+
+// the postponed request of a host shouldn't count towards
+// the max, and the next request should take its place
+func TestMemoryResizeWithinPostponed(t *testing.T) {
+	r := require.New(t)
+	q := NewMemory(time.Minute, true, 10*time.Second, logr.Discard())
+
+	ok, err := q.ResizeWithin("host1", 1, 1)
+	r.NoError(err)
+	r.True(ok)
+	q.PostponeResize("host1", time.Now().Add(q.PostponeDuration()))
+	r.Equal(1, q.Count("host1"))
+
+	ok, err = q.ResizeWithin("host1", 1, 1)
+	r.NoError(err)
+	r.True(ok)
+	r.Equal(1, q.Count("host1"))
+	ok, err = q.ResizeWithin("host1", 1, 1)
+	r.NoError(err)
+	r.False(ok)
+	r.Equal(1, q.Count("host1"))
+}
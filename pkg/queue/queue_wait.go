@@ -0,0 +1,119 @@
+package queue
+
+import (
+	"math"
+	"time"
+)
+
+// waitBounds are the upper bounds of the buckets of waitTimes. Waits
+// longer than the last bound count towards an extra, last bucket
+var waitBounds = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	20 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	2 * time.Minute,
+}
+
+// waitTimes is a histogram of the times that the requests to a
+// single host waited in the interceptor, over a sliding window of
+// one second slots, so that its percentiles can be computed.
+// It is not concurrency safe
+type waitTimes struct {
+	// slots is a ring of the histograms of the seconds in the
+	// window. The histogram of the second s is slots[s%len(slots)]
+	slots [][]int
+	// last is the unix second of the latest slot
+	last int64
+}
+
+func newWaitTimes(window time.Duration) *waitTimes {
+	size := int(window / time.Second)
+	if size < 1 {
+		size = 1
+	}
+	slots := make([][]int, size)
+	for i := range slots {
+		slots[i] = make([]int, len(waitBounds)+1)
+	}
+	return &waitTimes{
+		slots: slots,
+	}
+}
+
+// advance clears the slots of the seconds between the
+// latest slot and now, so that the slot of now can be used
+func (w *waitTimes) advance(now int64) {
+	if now <= w.last {
+		return
+	}
+	reset := func(slot []int) {
+		for i := range slot {
+			slot[i] = 0
+		}
+	}
+	if now-w.last >= int64(len(w.slots)) {
+		for _, slot := range w.slots {
+			reset(slot)
+		}
+	} else {
+		for s := w.last + 1; s <= now; s++ {
+			reset(w.slots[s%int64(len(w.slots))])
+		}
+	}
+	w.last = now
+}
+
+// add counts a request that waited for d at now
+func (w *waitTimes) add(now time.Time, d time.Duration) {
+	sec := now.Unix()
+	w.advance(sec)
+	bucket := len(waitBounds)
+	for i, bound := range waitBounds {
+		if d <= bound {
+			bucket = i
+			break
+		}
+	}
+	w.slots[sec%int64(len(w.slots))][bucket]++
+}
+
+// percentile returns the upper bound of the bucket that holds the
+// p-th percentile, 0 < p <= 1, of the waits in the window that ends
+// at now. Waits beyond the last bound count as the last bound. It
+// returns false if no waits were counted in the window
+func (w *waitTimes) percentile(now time.Time, p float64) (time.Duration, bool) {
+	w.advance(now.Unix())
+	buckets := make([]int, len(waitBounds)+1)
+	total := 0
+	for _, slot := range w.slots {
+		for i, n := range slot {
+			buckets[i] += n
+			total += n
+		}
+	}
+	if total == 0 {
+		return 0, false
+	}
+	rank := int(math.Ceil(p * float64(total)))
+	seen := 0
+	for i, n := range buckets {
+		seen += n
+		if seen >= rank && i < len(waitBounds) {
+			return waitBounds[i], true
+		}
+	}
+	return waitBounds[len(waitBounds)-1], true
+}
@@ -0,0 +1,60 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitTimes(t *testing.T) {
+	r := require.New(t)
+	start := time.Unix(1000, 0)
+	waits := newWaitTimes(10 * time.Second)
+
+	_, ok := waits.percentile(start, 0.95)
+	r.False(ok)
+	for i := 0; i < 19; i++ {
+		waits.add(start, 3*time.Millisecond)
+	}
+	waits.add(start.Add(2*time.Second), 4*time.Second)
+	// the percentile is the upper bound of its bucket
+	p95, ok := waits.percentile(start.Add(2*time.Second), 0.95)
+	r.True(ok)
+	r.Equal(5*time.Millisecond, p95)
+	p99, ok := waits.percentile(start.Add(2*time.Second), 0.99)
+	r.True(ok)
+	r.Equal(5*time.Second, p99)
+
+	// the short waits of the first second slide out of the window
+	p95, ok = waits.percentile(start.Add(10*time.Second), 0.95)
+	r.True(ok)
+	r.Equal(5*time.Second, p95)
+	_, ok = waits.percentile(start.Add(time.Minute), 0.95)
+	r.False(ok)
+
+	// waits beyond the last bound count as the last bound
+	waits.add(start.Add(time.Hour), time.Hour)
+	p95, ok = waits.percentile(start.Add(time.Hour), 0.95)
+	r.True(ok)
+	r.Equal(waitBounds[len(waitBounds)-1], p95)
+}
+
+func TestMemoryWaitP95(t *testing.T) {
+	r := require.New(t)
+	q := NewMemory(time.Second, false, 10*time.Second, logr.Discard())
+	q.Ensure("host1")
+	r.NoError(q.Resize("host2", 1))
+	q.RecordWait("host2", 700*time.Millisecond)
+
+	cur, err := q.Current()
+	r.NoError(err)
+	r.Equal(map[string]time.Duration{"host2": time.Second}, cur.WaitP95)
+	r.Equal(int64(1000), cur.Usages(nil)["host2"].WaitP95Millis)
+
+	r.True(q.Remove("host2"))
+	cur, err = q.Current()
+	r.NoError(err)
+	r.Empty(cur.WaitP95)
+}
@@ -0,0 +1,41 @@
+package routing
+
+import (
+	"strings"
+)
+
+// wildcardHostPrefix starts every wildcard host. A wildcard host
+// such as *.example.com matches any host that ends in .example.com,
+// but not example.com itself
+const wildcardHostPrefix = "*."
+
+// IsWildcardHost returns true if host is a wildcard host
+func IsWildcardHost(host string) bool {
+	return strings.HasPrefix(host, wildcardHostPrefix)
+}
+
+// hostKeys returns the routing table keys to try, in order, when
+// looking up host:
+//
+//   - host itself
+//   - host without its port, if it has one
+//   - every wildcard host that matches host, most specific first
+func hostKeys(host string) []string {
+	keys := []string{host}
+	hostname := host
+	if i := strings.LastIndex(host, ":"); i != -1 {
+		hostname = host[:i]
+		keys = append(keys, hostname)
+	}
+	if IsWildcardHost(hostname) {
+		return keys
+	}
+	for {
+		i := strings.Index(hostname, ".")
+		if i == -1 || i == len(hostname)-1 {
+			return keys
+		}
+		hostname = hostname[i+1:]
+		keys = append(keys, wildcardHostPrefix+hostname)
+	}
+}
@@ -0,0 +1,56 @@
+package routing
+
+import (
+	"strings"
+)
+
+// Key returns the routing table key for requests to host whose path
+// starts with pathPrefix. A Target without a path prefix is keyed by the
+// bare host, so routing tables written before path prefixes existed
+// remain valid.
+func Key(host, pathPrefix string) string {
+	return host + normalizePathPrefix(pathPrefix)
+}
+
+// ruleKeySeparator separates the key of a Target from the name of
+// one of its HeaderRules in the key of the rule's Target
+const ruleKeySeparator = "#"
+
+// RuleKey returns the routing table key for the Target of the
+// HeaderRule named rule, that belongs to the Target stored under key
+func RuleKey(key, rule string) string {
+	return key + ruleKeySeparator + rule
+}
+
+// backendKeySeparator separates the key of a Target from the name of
+// one of its Backends in the key of the backend's Target
+const backendKeySeparator = "@"
+
+// BackendKey returns the routing table key for the Target of the
+// Backend named backend, that belongs to the Target stored under key
+func BackendKey(key, backend string) string {
+	return key + backendKeySeparator + backend
+}
+
+// normalizePathPrefix returns p with a leading slash and without
+// trailing slashes. The root path normalizes to the empty string
+func normalizePathPrefix(p string) string {
+	p = strings.TrimRight(p, "/")
+	if p == "" {
+		return ""
+	}
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return p
+}
+
+// parentPathPrefix returns the prefix one path segment shorter than
+// prefix, which must already be normalized
+func parentPathPrefix(prefix string) string {
+	i := strings.LastIndex(prefix, "/")
+	if i == -1 {
+		return ""
+	}
+	return prefix[:i]
+}
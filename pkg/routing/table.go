@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"strings"
+	"net/http"
 	"sync"
 )
 
@@ -12,6 +12,7 @@ type TableReader interface {
 	Lookup(string) (*Target, error)
 	Hosts() []string
 	HasHost(string) bool
+	HostKey(string) (string, bool)
 }
 type Table struct {
 	fmt.Stringer
@@ -39,11 +40,24 @@ func (t Table) Hosts() []string {
 	return ret
 }
 
+// HasHost returns true if host has an entry in t, either under
+// its own name or through a wildcard host that matches it
 func (t Table) HasHost(host string) bool {
+	_, exists := t.HostKey(host)
+	return exists
+}
+
+// HostKey returns the key of the most specific entry in t that
+// host matches. See Lookup for the order in which keys are tried
+func (t Table) HostKey(host string) (string, bool) {
 	t.l.RLock()
 	defer t.l.RUnlock()
-	_, exists := t.m[host]
-	return exists
+	for _, key := range hostKeys(host) {
+		if _, exists := t.m[key]; exists {
+			return key, true
+		}
+	}
+	return "", false
 }
 
 func (t *Table) String() string {
@@ -71,16 +85,14 @@ func (t *Table) UnmarshalJSON(data []byte) error {
 	return json.NewDecoder(b).Decode(&t.m)
 }
 
+// Lookup returns the Target for host. An exact match on host is
+// preferred, then host without its port, and then wildcard hosts
+// (e.g. *.example.com) from the most to the least specific
 func (t *Table) Lookup(host string) (*Target, error) {
 	t.l.RLock()
 	defer t.l.RUnlock()
 
-	keys := []string{host}
-	if i := strings.LastIndex(host, ":"); i != -1 {
-		keys = append(keys, host[:i])
-	}
-
-	for _, key := range keys {
+	for _, key := range hostKeys(host) {
 		if target, ok := t.m[key]; ok {
 			return &target, nil
 		}
@@ -89,6 +101,71 @@ func (t *Table) Lookup(host string) (*Target, error) {
 	return nil, ErrTargetNotFound
 }
 
+// MaxPendingRequests returns the MaxPendingRequests of the Target
+// stored under key. It returns false if there is no such Target or
+// if it has no limit
+func (t *Table) MaxPendingRequests(key string) (int, bool) {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	target, ok := t.m[key]
+	if !ok || target.MaxPendingRequests <= 0 {
+		return 0, false
+	}
+	return int(target.MaxPendingRequests), true
+}
+
+// Route finds the Target that should serve a request for the given
+// host, path and header. Targets registered with a path prefix (see Key)
+// are preferred over the host-only Target, and the longest matching
+// prefix wins. If the matched Target has HeaderRules, the Target of the
+// first rule that header matches is used instead. Otherwise, if it has
+// Backends, the request may be sent to one of them by weight.
+//
+// Route returns the key under which the Target was found along with the
+// Target itself, or ErrTargetNotFound if nothing matched
+func (t *Table) Route(host, path string, header http.Header) (string, *Target, error) {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	for _, hostKey := range hostKeys(host) {
+		for prefix := normalizePathPrefix(path); ; prefix = parentPathPrefix(prefix) {
+			key := hostKey + prefix
+			if target, ok := t.m[key]; ok {
+				key, target := t.resolve(key, target, header)
+				return key, &target, nil
+			}
+			if prefix == "" {
+				break
+			}
+		}
+	}
+
+	return "", nil, ErrTargetNotFound
+}
+
+// resolve returns the key and Target that a request matched to target,
+// stored under key, should be sent to according to the HeaderRules and
+// Backends of target. The caller must hold t.l
+func (t *Table) resolve(key string, target Target, header http.Header) (string, Target) {
+	for _, rule := range target.HeaderRules {
+		if !rule.Matches(header) {
+			continue
+		}
+		ruleKey := RuleKey(key, rule.Name)
+		if ruleTarget, ok := t.m[ruleKey]; ok {
+			return ruleKey, ruleTarget
+		}
+	}
+	if backend, ok := pickBackend(target.Backends); ok {
+		backendKey := BackendKey(key, backend)
+		if backendTarget, ok := t.m[backendKey]; ok {
+			return backendKey, backendTarget
+		}
+	}
+	return key, target
+}
+
 // AddTarget registers target for host in the routing table t
 // if it didn't already exist.
 //
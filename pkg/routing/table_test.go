@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"math"
 	"math/rand"
+	"net/http"
 	"strconv"
 	"testing"
 
@@ -102,6 +103,204 @@ func TestTableReplace(t *testing.T) {
 	r.Equal(tbl1, tbl2)
 }
 
+func TestTableRoutePathPrefixes(t *testing.T) {
+	const host = "api.example.com"
+	r := require.New(t)
+	tbl := NewTable()
+
+	hostTgt := NewTarget("testns", "frontend", 8080, "frontend", 100)
+	ordersTgt := NewTarget("testns", "orders", 8080, "orders", 100)
+	ordersTgt.PathPrefix = "/orders"
+	ordersV2Tgt := NewTarget("testns", "orders-v2", 8080, "orders-v2", 100)
+	ordersV2Tgt.PathPrefix = "/orders/v2/"
+	ordersV2Tgt.PathRewrite = "/"
+	r.NoError(tbl.AddTarget(host, hostTgt))
+	r.NoError(tbl.AddTarget(Key(host, ordersTgt.PathPrefix), ordersTgt))
+	r.NoError(tbl.AddTarget(Key(host, ordersV2Tgt.PathPrefix), ordersV2Tgt))
+
+	testCases := []struct {
+		host    string
+		path    string
+		key     string
+		target  Target
+		fwdPath string
+	}{
+		{host, "/", host, hostTgt, "/"},
+		{host, "/ordersfoo", host, hostTgt, "/ordersfoo"},
+		{host, "/orders", host + "/orders", ordersTgt, "/orders"},
+		{host, "/orders/1", host + "/orders", ordersTgt, "/orders/1"},
+		{host + ":8080", "/orders/1", host + "/orders", ordersTgt, "/orders/1"},
+		{host, "/orders/v2", host + "/orders/v2", ordersV2Tgt, "/"},
+		{host, "/orders/v2/1", host + "/orders/v2", ordersV2Tgt, "/1"},
+	}
+	for _, tc := range testCases {
+		key, target, err := tbl.Route(tc.host, tc.path, nil)
+		r.NoError(err, "host %s, path %s", tc.host, tc.path)
+		r.Equal(tc.key, key, "host %s, path %s", tc.host, tc.path)
+		r.Equal(tc.target, *target, "host %s, path %s", tc.host, tc.path)
+		r.Equal(tc.fwdPath, target.ForwardPath(tc.path), "host %s, path %s", tc.host, tc.path)
+	}
+
+	// without the host-only target, unmatched paths are not found
+	r.NoError(tbl.RemoveTarget(host))
+	_, target, err := tbl.Route(host, "/billing", nil)
+	r.Equal(ErrTargetNotFound, err)
+	r.Nil(target)
+
+	// path prefixes survive a JSON round trip
+	b, err := json.Marshal(tbl)
+	r.NoError(err)
+	retTbl := NewTable()
+	r.NoError(json.Unmarshal(b, retTbl))
+	_, target, err = retTbl.Route(host, "/orders/v2/1", nil)
+	r.NoError(err)
+	r.Equal(ordersV2Tgt, *target)
+}
+
+func TestTableWildcardHosts(t *testing.T) {
+	r := require.New(t)
+	tbl := NewTable()
+
+	previewTgt := NewTarget("testns", "preview", 8080, "preview", 100)
+	prTgt := NewTarget("testns", "pr-1", 8080, "pr-1", 100)
+	prAPITgt := NewTarget("testns", "pr-1-api", 8080, "pr-1-api", 100)
+	prAPITgt.PathPrefix = "/api"
+	r.NoError(tbl.AddTarget("*.preview.example.com", previewTgt))
+	r.NoError(tbl.AddTarget("pr-1.preview.example.com", prTgt))
+	r.NoError(tbl.AddTarget(Key("*.pr-1.preview.example.com", prAPITgt.PathPrefix), prAPITgt))
+
+	testCases := []struct {
+		host string
+		path string
+		key  string
+		tgt  Target
+	}{
+		// exact hosts win over wildcards
+		{"pr-1.preview.example.com", "/", "pr-1.preview.example.com", prTgt},
+		{"pr-1.preview.example.com:8080", "/", "pr-1.preview.example.com", prTgt},
+		{"pr-2.preview.example.com", "/", "*.preview.example.com", previewTgt},
+		{"pr-2.preview.example.com:8080", "/api", "*.preview.example.com", previewTgt},
+		{"a.b.preview.example.com", "/", "*.preview.example.com", previewTgt},
+		// the most specific wildcard wins
+		{"web.pr-1.preview.example.com", "/api/v1", "*.pr-1.preview.example.com/api", prAPITgt},
+		{"web.pr-1.preview.example.com", "/", "*.preview.example.com", previewTgt},
+	}
+	for _, tc := range testCases {
+		key, tgt, err := tbl.Route(tc.host, tc.path, nil)
+		r.NoError(err, "host %s", tc.host)
+		r.Equal(tc.key, key, "host %s", tc.host)
+		r.Equal(tc.tgt, *tgt, "host %s", tc.host)
+	}
+
+	// wildcards don't match the bare domain
+	_, _, err := tbl.Route("preview.example.com", "/", nil)
+	r.Equal(ErrTargetNotFound, err)
+	r.False(tbl.HasHost("preview.example.com"))
+
+	r.True(tbl.HasHost("*.preview.example.com"))
+	r.True(tbl.HasHost("pr-3.preview.example.com"))
+	key, ok := tbl.HostKey("pr-3.preview.example.com")
+	r.True(ok)
+	r.Equal("*.preview.example.com", key)
+	tgt, err := tbl.Lookup("pr-3.preview.example.com")
+	r.NoError(err)
+	r.Equal(previewTgt, *tgt)
+}
+
+func TestTableRouteHeaderRules(t *testing.T) {
+	const host = "api.example.com"
+	r := require.New(t)
+	tbl := NewTable()
+
+	hostTgt := NewTarget("testns", "api", 8080, "api", 100)
+	hostTgt.HeaderRules = []HeaderRule{
+		{Name: "tenant-a-v2", Headers: map[string]string{"X-Tenant": "a", "X-Api-Version": "2"}},
+		{Name: "tenant-a", Headers: map[string]string{"X-Tenant": "a"}},
+		{Name: "no-target", Headers: map[string]string{"X-Tenant": "b"}},
+	}
+	tenantATgt := NewTarget("testns", "api-a", 8080, "api-a", 100)
+	tenantAV2Tgt := NewTarget("testns", "api-a-v2", 8080, "api-a-v2", 100)
+	r.NoError(tbl.AddTarget(host, hostTgt))
+	r.NoError(tbl.AddTarget(RuleKey(host, "tenant-a"), tenantATgt))
+	r.NoError(tbl.AddTarget(RuleKey(host, "tenant-a-v2"), tenantAV2Tgt))
+
+	testCases := []struct {
+		header http.Header
+		key    string
+		target Target
+	}{
+		{nil, host, hostTgt},
+		{http.Header{"X-Tenant": {"c"}}, host, hostTgt},
+		// rules without a target fall back to the host
+		{http.Header{"X-Tenant": {"b"}}, host, hostTgt},
+		{http.Header{"X-Tenant": {"a"}}, host + "#tenant-a", tenantATgt},
+		{http.Header{"X-Tenant": {"a"}, "X-Api-Version": {"1"}}, host + "#tenant-a", tenantATgt},
+		{http.Header{"X-Tenant": {"a"}, "X-Api-Version": {"2"}}, host + "#tenant-a-v2", tenantAV2Tgt},
+	}
+	for _, tc := range testCases {
+		key, target, err := tbl.Route(host, "/", tc.header)
+		r.NoError(err, "header %v", tc.header)
+		r.Equal(tc.key, key, "header %v", tc.header)
+		r.Equal(tc.target, *target, "header %v", tc.header)
+	}
+
+	// rule targets can be looked up by their own key
+	r.True(tbl.HasHost(RuleKey(host, "tenant-a")))
+	target, err := tbl.Lookup(RuleKey(host, "tenant-a"))
+	r.NoError(err)
+	r.Equal(tenantATgt, *target)
+}
+
+func TestTableRouteBackends(t *testing.T) {
+	const (
+		host  = "api.example.com"
+		iters = 10000
+	)
+	r := require.New(t)
+	tbl := NewTable()
+
+	stableTgt := NewTarget("testns", "api", 8080, "api", 100)
+	stableTgt.HeaderRules = []HeaderRule{
+		{Name: "beta", Headers: map[string]string{"X-Beta": "true"}},
+	}
+	stableTgt.Backends = []Backend{
+		{Name: "canary", Weight: 20},
+		{Name: "never", Weight: 0},
+	}
+	canaryTgt := NewTarget("testns", "api-canary", 8080, "api-canary", 100)
+	neverTgt := NewTarget("testns", "api-never", 8080, "api-never", 100)
+	betaTgt := NewTarget("testns", "api-beta", 8080, "api-beta", 100)
+	r.NoError(tbl.AddTarget(host, stableTgt))
+	r.NoError(tbl.AddTarget(BackendKey(host, "canary"), canaryTgt))
+	r.NoError(tbl.AddTarget(BackendKey(host, "never"), neverTgt))
+	r.NoError(tbl.AddTarget(RuleKey(host, "beta"), betaTgt))
+
+	counts := map[string]int{}
+	for i := 0; i < iters; i++ {
+		key, target, err := tbl.Route(host, "/", nil)
+		r.NoError(err)
+		switch key {
+		case host:
+			r.Equal(stableTgt, *target)
+		case BackendKey(host, "canary"):
+			r.Equal(canaryTgt, *target)
+		default:
+			r.Failf("unexpected key", "key %q", key)
+		}
+		counts[key]++
+	}
+	// the canary should receive roughly 20% of the requests
+	r.InDelta(iters*20/100, counts[BackendKey(host, "canary")], iters*5/100)
+
+	// header rules take precedence over backends
+	for i := 0; i < 100; i++ {
+		key, target, err := tbl.Route(host, "/", http.Header{"X-Beta": {"true"}})
+		r.NoError(err)
+		r.Equal(RuleKey(host, "beta"), key)
+		r.Equal(betaTgt, *target)
+	}
+}
+
 var _ = Describe("Table", func() {
 	Describe("Lookup", func() {
 		var (
@@ -214,3 +413,27 @@ func newTableLookupTestCases(count uint) tableLookupTestCases {
 	}
 	return tltcs
 }
+
+func TestTableMaxPendingRequests(t *testing.T) {
+	const host = "api.example.com"
+	r := require.New(t)
+	tbl := NewTable()
+
+	limitedTgt := NewTarget("testns", "api", 8080, "api", 100)
+	limitedTgt.MaxPendingRequests = 25
+	r.NoError(tbl.AddTarget(host, limitedTgt))
+	r.NoError(tbl.AddTarget(
+		BackendKey(host, "canary"),
+		NewTarget("testns", "api-canary", 8080, "api-canary", 100),
+	))
+
+	max, ok := tbl.MaxPendingRequests(host)
+	r.True(ok)
+	r.Equal(25, max)
+
+	_, ok = tbl.MaxPendingRequests(BackendKey(host, "canary"))
+	r.False(ok, "target without a limit")
+
+	_, ok = tbl.MaxPendingRequests("missing.example.com")
+	r.False(ok, "missing target")
+}
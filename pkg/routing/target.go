@@ -3,13 +3,34 @@ package routing
 import (
 	"errors"
 	"fmt"
+	"math/rand"
+	"net/http"
 	"net/url"
+	"strings"
+	"time"
 )
 
 // ErrTargetNotFound is returned when a target is not
 // found in the table.
 var ErrTargetNotFound = errors.New("Target not found")
 
+// ReadinessCheckEndpoints is the ReadinessCheck of the Targets that
+// wait for their Service to have a ready endpoint
+const ReadinessCheckEndpoints = "Endpoints"
+
+const (
+	// ScalingMetricConcurrency is the metric of the
+	// pending requests to the workload of a Target
+	ScalingMetricConcurrency = "concurrency"
+	// ScalingMetricRate is the metric of the rate of
+	// requests per second to the workload of a Target
+	ScalingMetricRate = "rate"
+	// ScalingMetricWaitTimeP95 is the metric of the 95th percentile,
+	// in milliseconds, of the times that the requests to the workload
+	// of a Target waited in the interceptors before they were forwarded
+	ScalingMetricWaitTimeP95 = "waitTimeP95"
+)
+
 // Target is a single target in the routing table.
 type Target struct {
 	Service               string
@@ -17,6 +38,130 @@ type Target struct {
 	Deployment            string
 	Namespace             string
 	TargetPendingRequests int32
+	// APIVersion and Kind are the type of the workload named
+	// Deployment that serves this Target. Both are empty
+	// if the workload is a Deployment
+	APIVersion string `json:",omitempty"`
+	Kind       string `json:",omitempty"`
+	// PathPrefix is the request path prefix that this Target serves.
+	// An empty PathPrefix serves every path on its host
+	PathPrefix string `json:",omitempty"`
+	// PathRewrite, if set, replaces PathPrefix in the request path
+	// before the request is forwarded
+	PathRewrite string `json:",omitempty"`
+	// MaxPendingRequests is the number of pending requests above
+	// which new requests to this Target are rejected. Zero means
+	// there is no limit
+	MaxPendingRequests int32 `json:",omitempty"`
+	// RateLimit, if set, limits the rate of requests
+	// forwarded to this Target
+	RateLimit *RateLimit `json:",omitempty"`
+	// RetryPolicy, if set, changes how requests
+	// to this Target are retried
+	RetryPolicy *RetryPolicy `json:",omitempty"`
+	// ReadinessCheck is what the interceptor waits for before it
+	// forwards a request to this Target. ReadinessCheckEndpoints waits
+	// for the Service to have a ready endpoint. Otherwise, it waits
+	// for the workload to have a ready replica
+	ReadinessCheck string `json:",omitempty"`
+	// Metrics, if set, are the metrics that the workload of this
+	// Target is scaled by, each against its own target value. Without
+	// them, it is scaled by its pending requests, against
+	// TargetPendingRequests
+	Metrics []Metric `json:",omitempty"`
+	// HeaderRules are evaluated in order before this Target is used.
+	// A request that matches a rule is routed to the Target stored under
+	// RuleKey(key, rule.Name), where key is the key of this Target
+	HeaderRules []HeaderRule `json:",omitempty"`
+	// Backends receive a share of the requests that match none of
+	// the HeaderRules. A request sent to a backend is routed to the
+	// Target stored under BackendKey(key, backend.Name), where key is
+	// the key of this Target. This Target receives the rest
+	Backends []Backend `json:",omitempty"`
+}
+
+// Metric is a metric that the workload of a Target is scaled
+// by, along with its target value for each replica
+type Metric struct {
+	// Type is one of the ScalingMetric constants
+	Type        string
+	TargetValue int32
+}
+
+// RateLimit is a token bucket rate limit
+type RateLimit struct {
+	RequestsPerSecond int32
+	Burst             int32
+}
+
+// RetryPolicy is how the requests to a Target are retried
+type RetryPolicy struct {
+	// StatusCodes and BodyPatterns select the responses to retry.
+	// BodyPatterns only apply along with StatusCodes. If both are
+	// empty, the default responses are retried
+	StatusCodes  []int    `json:",omitempty"`
+	BodyPatterns []string `json:",omitempty"`
+	// ResponseHeaders are the names of the headers
+	// that mark a response to retry
+	ResponseHeaders []string `json:",omitempty"`
+	// ConnectionErrors allows retrying requests that
+	// failed to reach the Target
+	ConnectionErrors bool `json:",omitempty"`
+	// MaxAttempts is the number of times a request is sent,
+	// including the first. Zero keeps the default
+	MaxAttempts int `json:",omitempty"`
+	// PerTryTimeout, if set, is the timeout of each attempt
+	PerTryTimeout time.Duration `json:",omitempty"`
+	// RetryNonIdempotent allows retrying requests with
+	// non-idempotent methods, such as POST
+	RetryNonIdempotent bool `json:",omitempty"`
+}
+
+// Backend is a Target that receives Weight percent of the
+// requests of another Target
+type Backend struct {
+	Name   string
+	Weight int32
+}
+
+// pickBackend randomly picks one of backends according to their
+// weights. It returns false if the requests should stay with the
+// Target that backends belong to
+func pickBackend(backends []Backend) (string, bool) {
+	n := rand.Int31n(100)
+	for _, backend := range backends {
+		if n < backend.Weight {
+			return backend.Name, true
+		}
+		n -= backend.Weight
+	}
+	return "", false
+}
+
+// HeaderRule matches requests by their headers
+type HeaderRule struct {
+	Name string
+	// Headers holds the header names and values that a request must
+	// all carry to match the rule
+	Headers map[string]string
+}
+
+// Matches returns true if header has every header in h.Headers
+// with the expected value
+func (h HeaderRule) Matches(header http.Header) bool {
+	for name, expected := range h.Headers {
+		found := false
+		for _, val := range header.Values(name) {
+			if val == expected {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
 }
 
 // NewTarget creates a new Target from the given parameters.
@@ -36,6 +181,31 @@ func NewTarget(
 	}
 }
 
+// ScalingMetrics returns the metrics that the workload of t is scaled
+// by. These are the Metrics of t if it has any, and otherwise its
+// pending requests
+func (t Target) ScalingMetrics() []Metric {
+	if len(t.Metrics) > 0 {
+		return t.Metrics
+	}
+	return []Metric{{Type: ScalingMetricConcurrency, TargetValue: t.TargetPendingRequests}}
+}
+
+// ForwardPath returns the path that a request for path should be
+// forwarded to the backend with. If t has a PathRewrite, the PathPrefix
+// part of path is replaced with it. Otherwise, path is returned unchanged
+func (t Target) ForwardPath(path string) string {
+	if t.PathRewrite == "" {
+		return path
+	}
+	rest := strings.TrimPrefix(path, normalizePathPrefix(t.PathPrefix))
+	fwdPath := strings.TrimRight(t.PathRewrite, "/") + rest
+	if !strings.HasPrefix(fwdPath, "/") {
+		fwdPath = "/" + fwdPath
+	}
+	return fwdPath
+}
+
 // ServiceURLFunc is a function that returns the full in-cluster
 // URL for the given Target.
 //
@@ -24,3 +24,20 @@ func TestTargetServiceURL(t *testing.T) {
 		svcURL.Host,
 	)
 }
+
+func TestTargetScalingMetrics(t *testing.T) {
+	r := require.New(t)
+
+	target := NewTarget("testns", "testsvc", 8080, "testdepl", 123)
+	r.Equal(
+		[]Metric{{Type: ScalingMetricConcurrency, TargetValue: 123}},
+		target.ScalingMetrics(),
+	)
+
+	// the metrics replace the pending requests
+	target.Metrics = []Metric{
+		{Type: ScalingMetricConcurrency, TargetValue: 10},
+		{Type: ScalingMetricWaitTimeP95, TargetValue: 500},
+	}
+	r.Equal(target.Metrics, target.ScalingMetrics())
+}
@@ -36,6 +36,16 @@ type config struct {
 	DeploymentCacheRsyncPeriod time.Duration `envconfig:"KEDA_HTTP_SCALER_DEPLOYMENT_INFORMER_RSYNC_PERIOD" default:"60m"`
 	// QueueTickDuration is the duration between queue requests
 	QueueTickDuration time.Duration `envconfig:"KEDA_HTTP_QUEUE_TICK_DURATION" default:"1s"`
+	// QueueStaleCountsTTL is how long the last known counts of an interceptor
+	// are still used after its last successful queue request, while its queue
+	// requests fail or after it was removed from the endpoints of the
+	// interceptor Service with pending requests, e.g. while it drains. Zero
+	// drops the counts of an interceptor on its first failed queue request
+	QueueStaleCountsTTL time.Duration `envconfig:"KEDA_HTTP_QUEUE_STALE_COUNTS_TTL" default:"30s"`
+	// QueueFetchTimeout is the timeout of the queue requests to all
+	// interceptors, so that an unreachable interceptor doesn't hold up the
+	// counts of the others
+	QueueFetchTimeout time.Duration `envconfig:"KEDA_HTTP_QUEUE_FETCH_TIMEOUT" default:"2s"`
 	// This will be the 'Target Pending Requests' for the interceptor
 	TargetPendingRequestsInterceptor int `envconfig:"KEDA_HTTP_SCALER_TARGET_PENDING_REQUESTS_INTERCEPTOR" default:"100"`
 }
@@ -7,6 +7,7 @@ package main
 import (
 	context "context"
 	"fmt"
+	"math"
 	"math/rand"
 	"time"
 
@@ -22,8 +23,10 @@ func init() {
 }
 
 const (
-	interceptor  = "interceptor"
-	httpRequests = "http-requests"
+	interceptor     = "interceptor"
+	httpRequests    = "http-requests"
+	httpRequestRate = "http-request-rate"
+	httpWaitTimeP95 = "http-wait-time-p95"
 )
 
 type impl struct {
@@ -32,6 +35,7 @@ type impl struct {
 	routingTable            routing.TableReader
 	targetMetric            int64
 	targetMetricInterceptor int64
+	metrics                 *scalerMetrics
 	externalscaler.UnimplementedExternalScalerServer
 }
 
@@ -41,6 +45,7 @@ func newImpl(
 	routingTable routing.TableReader,
 	defaultTargetMetric int64,
 	defaultTargetMetricInterceptor int64,
+	metrics *scalerMetrics,
 ) *impl {
 	return &impl{
 		lggr:                    lggr,
@@ -48,6 +53,7 @@ func newImpl(
 		routingTable:            routingTable,
 		targetMetric:            defaultTargetMetric,
 		targetMetricInterceptor: defaultTargetMetricInterceptor,
+		metrics:                 metrics,
 	}
 }
 
@@ -67,6 +73,7 @@ func (e *impl) IsActive(
 	}
 
 	totalHostCount := 0
+	hadRequests := false
 	for _, host := range hosts {
 		if host == interceptor {
 			return &externalscaler.IsActiveResponse{
@@ -89,9 +96,15 @@ func (e *impl) IsActive(
 		}
 
 		totalHostCount += hostCount
+		// hosts that are scaled by their recent requests are also
+		// active while they had requests within the rate window
+		if e.scaledByRecentRequests(host) {
+			hostRate, _ := getHostRate(host, e.pinger.rates(), e.routingTable)
+			hadRequests = hadRequests || hostRate > 0
+		}
 	}
 
-	active := totalHostCount > 0
+	active := totalHostCount > 0 || hadRequests
 	return &externalscaler.IsActiveResponse{
 		Result: active,
 	}, nil
@@ -102,33 +115,42 @@ func (e *impl) StreamIsActive(
 	server externalscaler.ExternalScaler_StreamIsActiveServer,
 ) error {
 	// this function communicates with KEDA via the 'server' parameter.
-	// we call server.Send (below) every 200ms, which tells it to immediately
-	// ping our IsActive RPC
+	// we call server.Send (below) every 2 seconds, and whenever the counts
+	// change the active status, which tells it to immediately ping our
+	// IsActive RPC
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
+	lastActive := false
 	for {
+		onlyIfChanged := false
 		select {
 		case <-server.Context().Done():
 			return nil
 		case <-ticker.C:
-			active, err := e.IsActive(server.Context(), scaledObject)
-			if err != nil {
-				e.lggr.Error(
-					err,
-					"error getting active status in stream",
-				)
-				return err
-			}
-			err = server.Send(&externalscaler.IsActiveResponse{
-				Result: active.Result,
-			})
-			if err != nil {
-				e.lggr.Error(
-					err,
-					"error sending the active result in stream",
-				)
-				return err
-			}
+		case <-e.pinger.countsChanged():
+			onlyIfChanged = true
+		}
+		active, err := e.IsActive(server.Context(), scaledObject)
+		if err != nil {
+			e.lggr.Error(
+				err,
+				"error getting active status in stream",
+			)
+			return err
+		}
+		if onlyIfChanged && active.Result == lastActive {
+			continue
+		}
+		lastActive = active.Result
+		err = server.Send(&externalscaler.IsActiveResponse{
+			Result: active.Result,
+		})
+		if err != nil {
+			e.lggr.Error(
+				err,
+				"error sending the active result in stream",
+			)
+			return err
 		}
 	}
 }
@@ -144,13 +166,11 @@ func (e *impl) GetMetricSpec(
 		return nil, err
 	}
 
-	var targetPendingRequests int64
-	var host = hosts[0] // We are only interested in the first host to get the targetPendingRequests
-
-	if host == interceptor {
-		targetPendingRequests = e.targetMetricInterceptor
-	} else {
-		target, err := e.routingTable.Lookup(host)
+	// KEDA only takes one set of metrics for the hosts, which GetMetrics
+	// then sums up, so every host must be scaled by the same metrics
+	var metricSpecs []*externalscaler.MetricSpec
+	for i, host := range hosts {
+		hostSpecs, err := e.hostMetricSpecs(host)
 		if err != nil {
 			lggr.Error(
 				err,
@@ -160,21 +180,63 @@ func (e *impl) GetMetricSpec(
 			)
 			return nil, err
 		}
-		host = httpRequests
-		targetPendingRequests = int64(target.TargetPendingRequests)
-	}
-
-	metricSpecs := []*externalscaler.MetricSpec{
-		{
-			MetricName: host,
-			TargetSize: targetPendingRequests,
-		},
+		if i == 0 {
+			metricSpecs = hostSpecs
+			continue
+		}
+		if !sameMetricSpecs(metricSpecs, hostSpecs) {
+			err := fmt.Errorf("hosts '%s' and '%s' are scaled by different metrics", hosts[0], host)
+			lggr.Error(err, "not returning metric specs", "hosts", hosts)
+			return nil, err
+		}
 	}
 	return &externalscaler.GetMetricSpecResponse{
 		MetricSpecs: metricSpecs,
 	}, nil
 }
 
+// hostMetricSpecs returns the specs of the metrics that the target
+// of host is scaled by. Each metric gets its own name, so that the
+// HPA scales to the highest replica count of all of them
+func (e *impl) hostMetricSpecs(host string) ([]*externalscaler.MetricSpec, error) {
+	if host == interceptor {
+		return []*externalscaler.MetricSpec{
+			{
+				MetricName: interceptor,
+				TargetSize: e.targetMetricInterceptor,
+			},
+		}, nil
+	}
+
+	target, err := e.routingTable.Lookup(host)
+	if err != nil {
+		return nil, err
+	}
+	metrics := target.ScalingMetrics()
+	metricSpecs := make([]*externalscaler.MetricSpec, 0, len(metrics))
+	for _, metric := range metrics {
+		metricSpecs = append(metricSpecs, &externalscaler.MetricSpec{
+			MetricName: metricName(metric.Type),
+			TargetSize: int64(metric.TargetValue),
+		})
+	}
+	return metricSpecs, nil
+}
+
+// sameMetricSpecs returns true if a and b have the
+// same metric names and target sizes, in order
+func sameMetricSpecs(a, b []*externalscaler.MetricSpec) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].MetricName != b[i].MetricName || a[i].TargetSize != b[i].TargetSize {
+			return false
+		}
+	}
+	return true
+}
+
 func (e *impl) GetMetrics(
 	_ context.Context,
 	metricRequest *externalscaler.GetMetricsRequest,
@@ -186,8 +248,15 @@ func (e *impl) GetMetrics(
 		return nil, err
 	}
 
+	switch e.requestedMetric(hosts[0], metricRequest.MetricName) {
+	case httpRequestRate:
+		return e.getRateMetrics(lggr, hosts)
+	case httpWaitTimeP95:
+		return e.getWaitTimeMetrics(lggr, hosts)
+	}
+
 	var totalCount int64
-	var metricName = httpRequests
+	var countsMetric = httpRequests
 	for _, host := range hosts {
 		hostCount, ok := getHostCount(
 			host,
@@ -202,14 +271,15 @@ func (e *impl) GetMetrics(
 				return nil, err
 			}
 			hostCount = e.pinger.aggregate()
-			metricName = interceptor
+			countsMetric = interceptor
 		}
+		e.metrics.reportPending(host, hostCount)
 		totalCount += int64(hostCount)
 	}
 
 	metricValues := []*externalscaler.MetricValue{
 		{
-			MetricName:  metricName,
+			MetricName:  countsMetric,
 			MetricValue: totalCount,
 		},
 	}
@@ -218,3 +288,115 @@ func (e *impl) GetMetrics(
 		MetricValues: metricValues,
 	}, nil
 }
+
+// metricName returns the name of the metric of the given type
+func metricName(metricType string) string {
+	switch metricType {
+	case routing.ScalingMetricRate:
+		return httpRequestRate
+	case routing.ScalingMetricWaitTimeP95:
+		return httpWaitTimeP95
+	default:
+		return httpRequests
+	}
+}
+
+// requestedMetric returns the name of the metric of host that KEDA
+// requested as name. KEDA requests the metrics by the names that
+// GetMetricSpec returned. If name isn't one of the metrics of host,
+// it returns the first metric of host, like GetMetricSpec did before
+// it returned more than one
+func (e *impl) requestedMetric(host, name string) string {
+	if host == interceptor {
+		return interceptor
+	}
+	target, err := e.routingTable.Lookup(host)
+	if err != nil {
+		return httpRequests
+	}
+	metrics := target.ScalingMetrics()
+	for _, metric := range metrics {
+		if metricName(metric.Type) == name {
+			return name
+		}
+	}
+	return metricName(metrics[0].Type)
+}
+
+// scaledByRecentRequests returns true if the target of host is
+// scaled by a metric of its recent requests rather than of its
+// pending requests, which are its request rate and wait time
+func (e *impl) scaledByRecentRequests(host string) bool {
+	if host == interceptor {
+		return false
+	}
+	target, err := e.routingTable.Lookup(host)
+	if err != nil {
+		return false
+	}
+	for _, metric := range target.ScalingMetrics() {
+		if metric.Type == routing.ScalingMetricRate ||
+			metric.Type == routing.ScalingMetricWaitTimeP95 {
+			return true
+		}
+	}
+	return false
+}
+
+// getRateMetrics returns the total rate of requests to hosts, rounded
+// up so that any requests keep the workload of the hosts scaled up
+func (e *impl) getRateMetrics(
+	lggr logr.Logger,
+	hosts []string,
+) (*externalscaler.GetMetricsResponse, error) {
+	var totalRate float64
+	for _, host := range hosts {
+		if _, ok := getHostCount(host, e.pinger.counts(), e.routingTable); !ok {
+			err := fmt.Errorf("host '%s' not found in counts", host)
+			allCounts := e.pinger.mergeCountsWithRoutingTable(e.routingTable)
+			lggr.Error(err, "allCounts", allCounts)
+			return nil, err
+		}
+		hostRate, _ := getHostRate(host, e.pinger.rates(), e.routingTable)
+		totalRate += hostRate
+	}
+
+	return &externalscaler.GetMetricsResponse{
+		MetricValues: []*externalscaler.MetricValue{
+			{
+				MetricName:  httpRequestRate,
+				MetricValue: int64(math.Ceil(totalRate)),
+			},
+		},
+	}, nil
+}
+
+// getWaitTimeMetrics returns the highest 95th percentile wait
+// time of hosts, in milliseconds
+func (e *impl) getWaitTimeMetrics(
+	lggr logr.Logger,
+	hosts []string,
+) (*externalscaler.GetMetricsResponse, error) {
+	var maxWait time.Duration
+	for _, host := range hosts {
+		if _, ok := getHostCount(host, e.pinger.counts(), e.routingTable); !ok {
+			err := fmt.Errorf("host '%s' not found in counts", host)
+			allCounts := e.pinger.mergeCountsWithRoutingTable(e.routingTable)
+			lggr.Error(err, "allCounts", allCounts)
+			return nil, err
+		}
+		hostWait, _ := getHostWaitTime(host, e.pinger.waitTimes(), e.routingTable)
+		if hostWait > maxWait {
+			maxWait = hostWait
+		}
+	}
+
+	return &externalscaler.GetMetricsResponse{
+		MetricValues: []*externalscaler.MetricValue{
+			{
+				MetricName:  httpWaitTimeP95,
+				MetricValue: maxWait.Milliseconds(),
+			},
+		},
+	}, nil
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -117,6 +118,7 @@ func TestStreamIsActive(t *testing.T) {
 				table,
 				123,
 				200,
+				newScalerMetrics(prometheus.NewRegistry()),
 			)
 
 			bufSize := 1024 * 1024
@@ -236,6 +238,34 @@ func TestIsActive(t *testing.T) {
 				r.NoError(table.AddTarget(t.Name(), standardTarget()))
 			},
 		},
+		{
+			name:        "Host scaled by rate with recent requests",
+			hosts:       t.Name(),
+			expected:    true,
+			expectedErr: false,
+			setup: func(table *routing.Table, q *queuePinger) {
+				target := standardTarget()
+				target.Metrics = []routing.Metric{{Type: routing.ScalingMetricRate, TargetValue: 1}}
+				r.NoError(table.AddTarget(t.Name(), target))
+				q.pingMut.Lock()
+				defer q.pingMut.Unlock()
+				q.allCounts[t.Name()] = 0
+				q.allRates[t.Name()] = 0.5
+			},
+		},
+		{
+			name:        "Host scaled by concurrency with recent requests",
+			hosts:       t.Name(),
+			expected:    false,
+			expectedErr: false,
+			setup: func(table *routing.Table, q *queuePinger) {
+				r.NoError(table.AddTarget(t.Name(), standardTarget()))
+				q.pingMut.Lock()
+				defer q.pingMut.Unlock()
+				q.allCounts[t.Name()] = 0
+				q.allRates[t.Name()] = 0.5
+			},
+		},
 		{
 			name:        "Host doesn't exist",
 			hosts:       t.Name(),
@@ -261,6 +291,7 @@ func TestIsActive(t *testing.T) {
 				table,
 				123,
 				200,
+				newScalerMetrics(prometheus.NewRegistry()),
 			)
 
 			res, err := hdl.IsActive(
@@ -363,6 +394,59 @@ func TestGetMetricSpecTable(t *testing.T) {
 				r.Equal(int64(123), spec.TargetSize)
 			},
 		},
+		{
+			name:                           "hosts scaled by different metrics",
+			defaultTargetMetric:            0,
+			defaultTargetMetricInterceptor: 123,
+			scalerMetadata: map[string]string{
+				"hosts": "validHost1,validHost2",
+			},
+			newRoutingTableFn: func() *routing.Table {
+				ret := routing.NewTable()
+				r.NoError(ret.AddTarget("validHost1", routing.NewTarget(
+					ns,
+					"testsrv",
+					8080,
+					"testdepl",
+					123,
+				)))
+				target := routing.NewTarget(ns, "testsrv", 8080, "testdepl", 123)
+				target.Metrics = []routing.Metric{{Type: routing.ScalingMetricRate, TargetValue: 50}}
+				r.NoError(ret.AddTarget("validHost2", target))
+				return ret
+			},
+			checker: func(t *testing.T, res *externalscaler.GetMetricSpecResponse, err error) {
+				t.Helper()
+				r := require.New(t)
+				r.Error(err)
+				r.Nil(res)
+			},
+		},
+		{
+			name:                           "host missing from the routing table after the first host",
+			defaultTargetMetric:            0,
+			defaultTargetMetricInterceptor: 123,
+			scalerMetadata: map[string]string{
+				"hosts": "validHost,missingHost",
+			},
+			newRoutingTableFn: func() *routing.Table {
+				ret := routing.NewTable()
+				r.NoError(ret.AddTarget("validHost", routing.NewTarget(
+					ns,
+					"testsrv",
+					8080,
+					"testdepl",
+					123,
+				)))
+				return ret
+			},
+			checker: func(t *testing.T, res *externalscaler.GetMetricSpecResponse, err error) {
+				t.Helper()
+				r := require.New(t)
+				r.Error(err)
+				r.Nil(res)
+			},
+		},
 		{
 			name:                           "interceptor as host in scaler metadata",
 			defaultTargetMetric:            1000,
@@ -393,6 +477,63 @@ func TestGetMetricSpecTable(t *testing.T) {
 				r.Equal(int64(2000), spec.TargetSize)
 			},
 		},
+		{
+			name:                           "host scaled by request rate",
+			defaultTargetMetric:            0,
+			defaultTargetMetricInterceptor: 123,
+			scalerMetadata: map[string]string{
+				"hosts": "validHost",
+			},
+			newRoutingTableFn: func() *routing.Table {
+				ret := routing.NewTable()
+				target := routing.NewTarget(ns, "testsrv", 8080, "testdepl", 123)
+				target.Metrics = []routing.Metric{{Type: routing.ScalingMetricRate, TargetValue: 50}}
+				r.NoError(ret.AddTarget("validHost", target))
+				return ret
+			},
+			checker: func(t *testing.T, res *externalscaler.GetMetricSpecResponse, err error) {
+				t.Helper()
+				r := require.New(t)
+				r.NoError(err)
+				r.NotNil(res)
+				r.Equal(1, len(res.MetricSpecs))
+				spec := res.MetricSpecs[0]
+				r.Equal(httpRequestRate, spec.MetricName)
+				r.Equal(int64(50), spec.TargetSize)
+			},
+		},
+		{
+			name:                           "host scaled by several metrics",
+			defaultTargetMetric:            0,
+			defaultTargetMetricInterceptor: 123,
+			scalerMetadata: map[string]string{
+				"hosts": "validHost",
+			},
+			newRoutingTableFn: func() *routing.Table {
+				ret := routing.NewTable()
+				target := routing.NewTarget(ns, "testsrv", 8080, "testdepl", 123)
+				target.Metrics = []routing.Metric{
+					{Type: routing.ScalingMetricConcurrency, TargetValue: 10},
+					{Type: routing.ScalingMetricRate, TargetValue: 50},
+					{Type: routing.ScalingMetricWaitTimeP95, TargetValue: 500},
+				}
+				r.NoError(ret.AddTarget("validHost", target))
+				return ret
+			},
+			checker: func(t *testing.T, res *externalscaler.GetMetricSpecResponse, err error) {
+				t.Helper()
+				r := require.New(t)
+				r.NoError(err)
+				r.NotNil(res)
+				r.Equal(3, len(res.MetricSpecs))
+				r.Equal(httpRequests, res.MetricSpecs[0].MetricName)
+				r.Equal(int64(10), res.MetricSpecs[0].TargetSize)
+				r.Equal(httpRequestRate, res.MetricSpecs[1].MetricName)
+				r.Equal(int64(50), res.MetricSpecs[1].TargetSize)
+				r.Equal(httpWaitTimeP95, res.MetricSpecs[2].MetricName)
+				r.Equal(int64(500), res.MetricSpecs[2].TargetSize)
+			},
+		},
 	}
 
 	for i, c := range cases {
@@ -419,6 +560,7 @@ func TestGetMetricSpecTable(t *testing.T) {
 				table,
 				testCase.defaultTargetMetric,
 				testCase.defaultTargetMetricInterceptor,
+				newScalerMetrics(prometheus.NewRegistry()),
 			)
 			scaledObjectRef := externalscaler.ScaledObjectRef{
 				ScalerMetadata: testCase.scalerMetadata,
@@ -433,6 +575,7 @@ func TestGetMetrics(t *testing.T) {
 	type testCase struct {
 		name           string
 		scalerMetadata map[string]string
+		metricName     string
 		setupFn        func(
 			context.Context,
 			logr.Logger,
@@ -685,6 +828,127 @@ func TestGetMetrics(t *testing.T) {
 			defaultTargetMetric:            int64(500),
 			defaultTargetMetricInterceptor: int64(600),
 		},
+		{
+			name: "hosts scaled by request rate add up their rates",
+			scalerMetadata: map[string]string{
+				"hosts": "validHost1,validHost2",
+			},
+			setupFn: func(
+				ctx context.Context,
+				lggr logr.Logger,
+			) (*routing.Table, *queuePinger, func(), error) {
+				table := routing.NewTable()
+				target := standardTarget()
+				target.Metrics = []routing.Metric{{Type: routing.ScalingMetricRate, TargetValue: 10}}
+				if err := table.AddTarget("validHost1", target); err != nil {
+					return nil, nil, nil, err
+				}
+				ticker, pinger, err := newFakeQueuePinger(ctx, lggr)
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				pinger.allCounts = map[string]int{"validHost1": 1, "validHost2": 0}
+				pinger.allRates = map[string]float64{"validHost1": 2.5, "validHost2": 0.2}
+				return table, pinger, func() { ticker.Stop() }, nil
+			},
+			checkFn: func(t *testing.T, res *externalscaler.GetMetricsResponse, err error) {
+				t.Helper()
+				r := require.New(t)
+				r.NoError(err)
+				r.NotNil(res)
+				r.Equal(1, len(res.MetricValues))
+				metricVal := res.MetricValues[0]
+				r.Equal(httpRequestRate, metricVal.MetricName)
+				// the rates are rounded up
+				r.Equal(int64(3), metricVal.MetricValue)
+			},
+			defaultTargetMetric:            int64(500),
+			defaultTargetMetricInterceptor: int64(600),
+		},
+		{
+			name: "hosts scaled by several metrics return the requested one",
+			scalerMetadata: map[string]string{
+				"hosts": "validHost1,validHost2",
+			},
+			metricName: httpWaitTimeP95,
+			setupFn: func(
+				ctx context.Context,
+				lggr logr.Logger,
+			) (*routing.Table, *queuePinger, func(), error) {
+				table := routing.NewTable()
+				target := standardTarget()
+				target.Metrics = []routing.Metric{
+					{Type: routing.ScalingMetricConcurrency, TargetValue: 10},
+					{Type: routing.ScalingMetricWaitTimeP95, TargetValue: 500},
+				}
+				if err := table.AddTarget("validHost1", target); err != nil {
+					return nil, nil, nil, err
+				}
+				ticker, pinger, err := newFakeQueuePinger(ctx, lggr)
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				pinger.allCounts = map[string]int{"validHost1": 1, "validHost2": 0}
+				pinger.allWaitP95 = map[string]time.Duration{
+					"validHost1": 250 * time.Millisecond,
+					"validHost2": 2500 * time.Millisecond,
+				}
+				return table, pinger, func() { ticker.Stop() }, nil
+			},
+			checkFn: func(t *testing.T, res *externalscaler.GetMetricsResponse, err error) {
+				t.Helper()
+				r := require.New(t)
+				r.NoError(err)
+				r.NotNil(res)
+				r.Equal(1, len(res.MetricValues))
+				metricVal := res.MetricValues[0]
+				r.Equal(httpWaitTimeP95, metricVal.MetricName)
+				// the highest wait time of the hosts, in milliseconds
+				r.Equal(int64(2500), metricVal.MetricValue)
+			},
+			defaultTargetMetric:            int64(500),
+			defaultTargetMetricInterceptor: int64(600),
+		},
+		{
+			name: "unknown metric names fall back to the first metric",
+			scalerMetadata: map[string]string{
+				"hosts": "validHost1",
+			},
+			metricName: httpRequestRate,
+			setupFn: func(
+				ctx context.Context,
+				lggr logr.Logger,
+			) (*routing.Table, *queuePinger, func(), error) {
+				table := routing.NewTable()
+				target := standardTarget()
+				target.Metrics = []routing.Metric{
+					{Type: routing.ScalingMetricConcurrency, TargetValue: 10},
+					{Type: routing.ScalingMetricWaitTimeP95, TargetValue: 500},
+				}
+				if err := table.AddTarget("validHost1", target); err != nil {
+					return nil, nil, nil, err
+				}
+				ticker, pinger, err := newFakeQueuePinger(ctx, lggr)
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				pinger.allCounts = map[string]int{"validHost1": 4}
+				pinger.allRates = map[string]float64{"validHost1": 20}
+				return table, pinger, func() { ticker.Stop() }, nil
+			},
+			checkFn: func(t *testing.T, res *externalscaler.GetMetricsResponse, err error) {
+				t.Helper()
+				r := require.New(t)
+				r.NoError(err)
+				r.NotNil(res)
+				r.Equal(1, len(res.MetricValues))
+				metricVal := res.MetricValues[0]
+				r.Equal(httpRequests, metricVal.MetricName)
+				r.Equal(int64(4), metricVal.MetricValue)
+			},
+			defaultTargetMetric:            int64(500),
+			defaultTargetMetricInterceptor: int64(600),
+		},
 	}
 
 	for i, c := range testCases {
@@ -706,11 +970,13 @@ func TestGetMetrics(t *testing.T) {
 				table,
 				tc.defaultTargetMetric,
 				tc.defaultTargetMetricInterceptor,
+				newScalerMetrics(prometheus.NewRegistry()),
 			)
 			res, err := hdl.GetMetrics(ctx, &externalscaler.GetMetricsRequest{
 				ScaledObjectRef: &externalscaler.ScaledObjectRef{
 					ScalerMetadata: tc.scalerMetadata,
 				},
+				MetricName: tc.metricName,
 			})
 			tc.checkFn(t, res, err)
 		})
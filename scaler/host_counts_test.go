@@ -90,6 +90,38 @@ func TestGetHostCount(t *testing.T) {
 	}
 }
 
+func TestGetHostCountWildcard(t *testing.T) {
+	r := require.New(t)
+	table := newRoutingTable(r, []hostAndTarget{
+		{
+			host:   "*.preview.example.com",
+			target: routing.Target{},
+		},
+		{
+			host:   "pr-1.preview.example.com",
+			target: routing.Target{},
+		},
+	})
+	counts := map[string]int{
+		"*.preview.example.com":    3,
+		"pr-1.preview.example.com": 1,
+	}
+	retCounts := map[string]int{
+		"*.preview.example.com":    3,
+		"pr-1.preview.example.com": 1,
+		// hosts only covered by the wildcard get its count
+		"pr-2.preview.example.com": 3,
+	}
+	for host, retCount := range retCounts {
+		ret, exists := getHostCount(host, counts, table)
+		r.True(exists, "host %s", host)
+		r.Equal(retCount, ret, "host %s", host)
+	}
+
+	_, exists := getHostCount("preview.example.com", counts, table)
+	r.False(exists)
+}
+
 type hostAndTarget struct {
 	host   string
 	target routing.Target
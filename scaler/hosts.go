@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 
@@ -11,7 +12,11 @@ import (
 )
 
 // getHostCount gets proper count for given host regardless whether
-// host is in counts or only in routerTable
+// host is in counts or only in routerTable.
+//
+// Interceptors count requests under the routing table key that they
+// were routed by, so a host that is only served by a wildcard entry
+// gets the count of that wildcard entry
 func getHostCount(
 	host string,
 	counts map[string]int,
@@ -22,8 +27,50 @@ func getHostCount(
 		return count, exists
 	}
 
-	exists = table.HasHost(host)
-	return 0, exists
+	key, exists := table.HostKey(host)
+	if !exists {
+		return 0, false
+	}
+	return counts[key], true
+}
+
+// getHostRate gets the request rate of host the same way that
+// getHostCount gets its count. Hosts without recent requests
+// are missing from rates, and have a rate of zero
+func getHostRate(
+	host string,
+	rates map[string]float64,
+	table routing.TableReader,
+) (float64, bool) {
+	rate, exists := rates[host]
+	if exists {
+		return rate, exists
+	}
+
+	key, exists := table.HostKey(host)
+	if !exists {
+		return 0, false
+	}
+	return rates[key], true
+}
+
+// getHostWaitTime gets the 95th percentile wait time of host the
+// same way that getHostRate gets its rate
+func getHostWaitTime(
+	host string,
+	waits map[string]time.Duration,
+	table routing.TableReader,
+) (time.Duration, bool) {
+	wait, exists := waits[host]
+	if exists {
+		return wait, exists
+	}
+
+	key, exists := table.HostKey(host)
+	if !exists {
+		return 0, false
+	}
+	return waits[key], true
 }
 
 // gets hosts from scaledobjectref
@@ -15,6 +15,9 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
@@ -56,6 +59,13 @@ func main() {
 		done()
 		os.Exit(1)
 	}
+	metricsRegistry := prometheus.NewRegistry()
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	metrics := newScalerMetrics(metricsRegistry)
+
 	pinger, err := newQueuePinger(
 		context.Background(),
 		lggr,
@@ -64,6 +74,9 @@ func main() {
 		svcName,
 		deplName,
 		targetPortStr,
+		cfg.QueueStaleCountsTTL,
+		cfg.QueueFetchTimeout,
+		metrics,
 	)
 	if err != nil {
 		lggr.Error(err, "creating a queue pinger")
@@ -72,18 +85,20 @@ func main() {
 	}
 	defer done()
 
+	table := routing.NewTable()
+
 	// This callback function is used to fetch and save
 	// the current queue counts from the interceptor immediately
-	// after updating the routingTable information.
+	// after updating the routingTable information. It also
+	// forgets the metrics of the hosts that were removed.
 	callbackWhenRoutingTableUpdate := func() error {
+		metrics.forgetRemovedHosts(table)
 		if err := pinger.fetchAndSaveCounts(ctx); err != nil {
 			return err
 		}
 		return nil
 	}
 
-	table := routing.NewTable()
-
 	// Create the informer of ConfigMap resource,
 	// the resynchronization period of the informer should be not less than 1s,
 	// refer to: https://github.com/kubernetes/client-go/blob/v0.22.2/tools/cache/shared_informer.go#L475
@@ -127,6 +142,7 @@ func main() {
 			table,
 			int64(targetPendingRequests),
 			int64(targetPendingRequestsInterceptor),
+			metrics,
 		)
 	})
 
@@ -150,6 +166,7 @@ func main() {
 			cfg,
 			healthPort,
 			pinger,
+			metricsRegistry,
 		)
 	})
 	build.PrintComponentInfo(lggr, "Scaler")
@@ -164,6 +181,7 @@ func startGrpcServer(
 	routingTable *routing.Table,
 	targetPendingRequests int64,
 	targetPendingRequestsInterceptor int64,
+	metrics *scalerMetrics,
 ) error {
 	addr := fmt.Sprintf("0.0.0.0:%d", port)
 	lggr.Info("starting grpc server", "address", addr)
@@ -172,7 +190,10 @@ func startGrpcServer(
 		return err
 	}
 
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(metrics.unaryServerInterceptor),
+		grpc.ChainStreamInterceptor(metrics.streamServerInterceptor),
+	)
 	externalscaler.RegisterExternalScalerServer(
 		grpcServer,
 		newImpl(
@@ -181,8 +202,13 @@ func startGrpcServer(
 			routingTable,
 			targetPendingRequests,
 			targetPendingRequestsInterceptor,
+			metrics,
 		),
 	)
+	externalscaler.RegisterQueueCountsServer(
+		grpcServer,
+		newQueueCountsServer(lggr, pinger),
+	)
 	reflection.Register(grpcServer)
 	go func() {
 		<-ctx.Done()
@@ -197,6 +223,7 @@ func startAdminServer(
 	cfg *config,
 	port int,
 	pinger *queuePinger,
+	metricsGatherer prometheus.Gatherer,
 ) error {
 	lggr = lggr.WithName("startHealthcheckServer")
 
@@ -216,6 +243,13 @@ func startAdminServer(
 			w.WriteHeader(500)
 		}
 	})
+	mux.HandleFunc("/queue_endpoints", func(w http.ResponseWriter, r *http.Request) {
+		lggr := lggr.WithName("route.queue_endpoints")
+		if err := json.NewEncoder(w).Encode(pinger.endpoints()); err != nil {
+			lggr.Error(err, "writing endpoint counts to client")
+			w.WriteHeader(500)
+		}
+	})
 	mux.HandleFunc("/queue_ping", func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		lggr := lggr.WithName("route.counts_ping")
@@ -236,6 +270,11 @@ func startAdminServer(
 		}
 	})
 
+	mux.Handle(
+		"/metrics",
+		promhttp.HandlerFor(metricsGatherer, promhttp.HandlerOpts{}),
+	)
+
 	kedahttp.AddConfigEndpoint(lggr, mux, cfg)
 	kedahttp.AddVersionEndpoint(lggr.WithName("scalerAdmin"), mux)
 
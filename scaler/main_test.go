@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
 	"k8s.io/apimachinery/pkg/util/rand"
@@ -43,6 +44,7 @@ func TestHealthChecks(t *testing.T) {
 			cfg,
 			port,
 			pinger,
+			prometheus.NewRegistry(),
 		)
 	}
 
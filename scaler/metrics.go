@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/kedacore/http-add-on/pkg/routing"
+)
+
+const metricsNamespace = "scaler"
+
+// scalerMetrics are the Prometheus metrics of the scaler. They
+// tell apart the interceptors failing to report their counts, the
+// counts that the scaler reports to KEDA, and KEDA calling the scaler
+type scalerMetrics struct {
+	fetchDuration   *prometheus.HistogramVec
+	fetchFailures   *prometheus.CounterVec
+	lastPingSuccess prometheus.Gauge
+	pendingRequests *prometheus.GaugeVec
+	grpcCalls       *prometheus.CounterVec
+	grpcDuration    *prometheus.HistogramVec
+
+	// reportedHosts are the hosts that pendingRequests has a
+	// series for, so that the series of removed hosts can be
+	// deleted
+	reportedMut   sync.Mutex
+	reportedHosts map[string]struct{}
+}
+
+// newScalerMetrics creates the metrics of the scaler
+// and registers them with reg
+func newScalerMetrics(reg prometheus.Registerer) *scalerMetrics {
+	m := &scalerMetrics{
+		fetchDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: metricsNamespace,
+				Name:      "interceptor_fetch_duration_seconds",
+				Help:      "Time that fetching the queue counts of an interceptor took",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"interceptor"},
+		),
+		fetchFailures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "interceptor_fetch_failures_total",
+				Help:      "Number of failed fetches of the queue counts of an interceptor",
+			},
+			[]string{"interceptor"},
+		),
+		lastPingSuccess: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricsNamespace,
+				Name:      "last_successful_ping_timestamp_seconds",
+				Help:      "Time of the last ping that fetched the queue counts of every interceptor",
+			},
+		),
+		pendingRequests: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: metricsNamespace,
+				Name:      "reported_pending_requests",
+				Help:      "Pending requests of a host as last reported to KEDA",
+			},
+			[]string{"host"},
+		),
+		grpcCalls: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "grpc_calls_total",
+				Help:      "Number of gRPC calls handled by the scaler, by method and status code",
+			},
+			[]string{"method", "code"},
+		),
+		grpcDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: metricsNamespace,
+				Name:      "grpc_call_duration_seconds",
+				Help:      "Time that gRPC calls took. Streaming calls take as long as their stream is open",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"method"},
+		),
+		reportedHosts: map[string]struct{}{},
+	}
+	reg.MustRegister(
+		m.fetchDuration,
+		m.fetchFailures,
+		m.lastPingSuccess,
+		m.pendingRequests,
+		m.grpcCalls,
+		m.grpcDuration,
+	)
+	return m
+}
+
+// observeFetch records a fetch of the counts of the interceptor
+// at the URL interceptor that took d and failed if err isn't nil
+func (m *scalerMetrics) observeFetch(interceptor string, d time.Duration, err error) {
+	m.fetchDuration.WithLabelValues(interceptor).Observe(d.Seconds())
+	if err != nil {
+		m.fetchFailures.WithLabelValues(interceptor).Inc()
+	}
+}
+
+// observeListFailure records a failed fetch of the counts of each of
+// the interceptors at the URLs interceptors, after their endpoints
+// could not be listed
+func (m *scalerMetrics) observeListFailure(interceptors []string) {
+	for _, interceptor := range interceptors {
+		m.fetchFailures.WithLabelValues(interceptor).Inc()
+	}
+}
+
+// forgetInterceptor deletes the fetch metrics of the
+// interceptor at the URL interceptor, once it is gone
+func (m *scalerMetrics) forgetInterceptor(interceptor string) {
+	m.fetchDuration.DeleteLabelValues(interceptor)
+	m.fetchFailures.DeleteLabelValues(interceptor)
+}
+
+// reportPending records count as the pending
+// requests of host last reported to KEDA
+func (m *scalerMetrics) reportPending(host string, count int) {
+	m.reportedMut.Lock()
+	defer m.reportedMut.Unlock()
+	m.reportedHosts[host] = struct{}{}
+	m.pendingRequests.WithLabelValues(host).Set(float64(count))
+}
+
+// forgetRemovedHosts deletes the pending requests reported for the
+// hosts that table no longer has, except the interceptor pseudo-host
+func (m *scalerMetrics) forgetRemovedHosts(table routing.TableReader) {
+	m.reportedMut.Lock()
+	defer m.reportedMut.Unlock()
+	for host := range m.reportedHosts {
+		if host == interceptor || table.HasHost(host) {
+			continue
+		}
+		m.pendingRequests.DeleteLabelValues(host)
+		delete(m.reportedHosts, host)
+	}
+}
+
+// observeGRPC records a call of the gRPC method fullMethod
+// that took d and failed with err if it isn't nil
+func (m *scalerMetrics) observeGRPC(fullMethod string, d time.Duration, err error) {
+	method := path.Base(fullMethod)
+	m.grpcCalls.WithLabelValues(method, status.Code(err).String()).Inc()
+	m.grpcDuration.WithLabelValues(method).Observe(d.Seconds())
+}
+
+// unaryServerInterceptor is a grpc.UnaryServerInterceptor
+// that records the metrics of unary calls
+func (m *scalerMetrics) unaryServerInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	m.observeGRPC(info.FullMethod, time.Since(start), err)
+	return resp, err
+}
+
+// streamServerInterceptor is a grpc.StreamServerInterceptor
+// that records the metrics of streaming calls
+func (m *scalerMetrics) streamServerInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	m.observeGRPC(info.FullMethod, time.Since(start), err)
+	return err
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/kedacore/http-add-on/pkg/routing"
+	externalscaler "github.com/kedacore/http-add-on/proto"
+)
+
+func TestGRPCMetrics(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	lggr := logr.Discard()
+	table := routing.NewTable()
+	r.NoError(table.AddTarget("validHost", routing.NewTarget("testns", "testsvc", 8080, "testdepl", 123)))
+	ticker, pinger, err := newFakeQueuePinger(ctx, lggr)
+	r.NoError(err)
+	defer ticker.Stop()
+	pinger.allCounts = map[string]int{"validHost": 4}
+
+	metrics := newScalerMetrics(prometheus.NewRegistry())
+	lis := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(metrics.unaryServerInterceptor),
+		grpc.ChainStreamInterceptor(metrics.streamServerInterceptor),
+	)
+	defer grpcServer.Stop()
+	externalscaler.RegisterExternalScalerServer(
+		grpcServer,
+		newImpl(lggr, pinger, table, 123, 200, metrics),
+	)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	conn, err := grpc.DialContext(
+		ctx,
+		"bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	r.NoError(err)
+	defer conn.Close()
+	client := externalscaler.NewExternalScalerClient(conn)
+
+	ref := &externalscaler.ScaledObjectRef{
+		ScalerMetadata: map[string]string{"hosts": "validHost"},
+	}
+	_, err = client.IsActive(ctx, ref)
+	r.NoError(err)
+	_, err = client.GetMetrics(ctx, &externalscaler.GetMetricsRequest{ScaledObjectRef: ref})
+	r.NoError(err)
+	_, err = client.IsActive(ctx, &externalscaler.ScaledObjectRef{
+		ScalerMetadata: map[string]string{"hosts": "invalidHost"},
+	})
+	r.Error(err)
+
+	r.Equal(1.0, testutil.ToFloat64(metrics.grpcCalls.WithLabelValues("IsActive", "OK")))
+	r.Equal(1.0, testutil.ToFloat64(metrics.grpcCalls.WithLabelValues("IsActive", "Unknown")))
+	r.Equal(1.0, testutil.ToFloat64(metrics.grpcCalls.WithLabelValues("GetMetrics", "OK")))
+	r.Equal(2, testutil.CollectAndCount(metrics.grpcDuration))
+	// the pending requests are the ones reported to KEDA
+	r.Equal(4.0, testutil.ToFloat64(metrics.pendingRequests.WithLabelValues("validHost")))
+}
+
+// the pending requests of hosts that were removed from
+// the routing table should no longer be reported
+func TestForgetRemovedHosts(t *testing.T) {
+	r := require.New(t)
+	metrics := newScalerMetrics(prometheus.NewRegistry())
+	table := routing.NewTable()
+	r.NoError(table.AddTarget("validHost", routing.NewTarget("testns", "testsvc", 8080, "testdepl", 123)))
+	metrics.reportPending("validHost", 1)
+	metrics.reportPending("removedHost", 2)
+	metrics.reportPending(interceptor, 3)
+
+	metrics.forgetRemovedHosts(table)
+	r.Equal(2, testutil.CollectAndCount(metrics.pendingRequests))
+	r.Equal(1.0, testutil.ToFloat64(metrics.pendingRequests.WithLabelValues("validHost")))
+	r.Equal(3.0, testutil.ToFloat64(metrics.pendingRequests.WithLabelValues(interceptor)))
+}
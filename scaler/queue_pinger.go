@@ -10,7 +10,6 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
-	"golang.org/x/sync/errgroup"
 
 	"github.com/kedacore/http-add-on/pkg/k8s"
 	"github.com/kedacore/http-add-on/pkg/queue"
@@ -26,7 +25,7 @@ import (
 //
 // Sample usage:
 //
-//	pinger, err := newQueuePinger(ctx, lggr, getEndpointsFn, ns, svcName, adminPort)
+//	pinger, err := newQueuePinger(ctx, lggr, getEndpointsFn, ns, svcName, deplName, adminPort, staleCountsTTL, fetchTimeout, metrics)
 //	if err != nil {
 //		panic(err)
 //	}
@@ -40,11 +39,33 @@ type queuePinger struct {
 	interceptorSvcName  string
 	interceptorDeplName string
 	adminPort           string
-	pingMut             *sync.RWMutex
-	lastPingTime        time.Time
-	allCounts           map[string]int
-	aggregateCount      int
-	lggr                logr.Logger
+	// staleCountsTTL is how long the counts of an interceptor endpoint
+	// are still trusted after its last successful fetch, while its
+	// fetches fail
+	staleCountsTTL time.Duration
+	// fetchTimeout bounds the fetches of the counts of all endpoints
+	fetchTimeout   time.Duration
+	pingMut        *sync.RWMutex
+	lastPingTime   time.Time
+	allCounts      map[string]int
+	aggregateCount int
+	// allRates holds the request rate of each host,
+	// summed across all interceptor endpoints
+	allRates map[string]float64
+	// allWaitP95 holds the 95th percentile wait time of each host,
+	// as the highest of those of all interceptor endpoints. Percentiles
+	// can't be combined exactly, and the highest one is conservative
+	allWaitP95 map[string]time.Duration
+	// endpointCounts holds the last known counts of each
+	// interceptor endpoint, keyed by its URL
+	endpointCounts map[string]*interceptorCounts
+	// streamedCounts holds the counts that each interceptor
+	// last streamed, keyed by the name of the interceptor
+	streamedCounts map[string]map[string]int
+	// changedCh is closed and replaced whenever the counts change
+	changedCh chan struct{}
+	metrics   *scalerMetrics
+	lggr      logr.Logger
 }
 
 func newQueuePinger(
@@ -55,6 +76,9 @@ func newQueuePinger(
 	svcName,
 	deplName,
 	adminPort string,
+	staleCountsTTL,
+	fetchTimeout time.Duration,
+	metrics *scalerMetrics,
 ) (*queuePinger, error) {
 	pingMut := new(sync.RWMutex)
 	pinger := &queuePinger{
@@ -63,10 +87,18 @@ func newQueuePinger(
 		interceptorSvcName:  svcName,
 		interceptorDeplName: deplName,
 		adminPort:           adminPort,
+		staleCountsTTL:      staleCountsTTL,
+		fetchTimeout:        fetchTimeout,
 		pingMut:             pingMut,
 		lggr:                lggr,
 		allCounts:           map[string]int{},
 		aggregateCount:      0,
+		allRates:            map[string]float64{},
+		allWaitP95:          map[string]time.Duration{},
+		endpointCounts:      map[string]*interceptorCounts{},
+		streamedCounts:      map[string]map[string]int{},
+		changedCh:           make(chan struct{}),
+		metrics:             metrics,
 	}
 	return pinger, pinger.fetchAndSaveCounts(ctx)
 }
@@ -98,15 +130,13 @@ func (q *queuePinger) start(
 				ctx.Err(),
 				"context marked done. stopping queuePinger loop",
 			)
-		// do our regularly scheduled work
+		// do our regularly scheduled work. failed fetches
+		// keep the last known counts, so they don't stop
+		// the loop
 		case <-ticker.C:
 			err := q.fetchAndSaveCounts(ctx)
 			if err != nil {
 				lggr.Error(err, "getting request counts")
-				return errors.Wrap(
-					err,
-					"error getting request counts",
-				)
 			}
 		// handle changes to the interceptor fleet
 		// Deployment
@@ -128,6 +158,22 @@ func (q *queuePinger) counts() map[string]int {
 	return q.allCounts
 }
 
+// rates returns the request rate of each host as of the last
+// ping. Hosts without recent requests are missing
+func (q *queuePinger) rates() map[string]float64 {
+	q.pingMut.RLock()
+	defer q.pingMut.RUnlock()
+	return q.allRates
+}
+
+// waitTimes returns the 95th percentile wait time of each host as
+// of the last ping. Hosts without recent requests are missing
+func (q *queuePinger) waitTimes() map[string]time.Duration {
+	q.pingMut.RLock()
+	defer q.pingMut.RUnlock()
+	return q.allWaitP95
+}
+
 // mergeCountsWithRoutingTable ensures that all hosts in routing table
 // are present in combined counts, if count is not present value is set to 0
 func (q *queuePinger) mergeCountsWithRoutingTable(
@@ -145,45 +191,280 @@ func (q *queuePinger) mergeCountsWithRoutingTable(
 	return mergedCounts
 }
 
+// countsChanged returns a channel that is closed
+// the next time that the counts change
+func (q *queuePinger) countsChanged() <-chan struct{} {
+	q.pingMut.RLock()
+	defer q.pingMut.RUnlock()
+	return q.changedCh
+}
+
+// notifyCountsChanged closes the channel returned by countsChanged.
+// It must be called with pingMut locked
+func (q *queuePinger) notifyCountsChanged() {
+	close(q.changedCh)
+	q.changedCh = make(chan struct{})
+}
+
+// saveStreamedCounts applies the counts that the interceptor named
+// interceptor streamed to the saved counts, by the difference to the
+// counts that it streamed before. Full updates only replace the counts
+// that the differences are taken from, since the saved counts already
+// hold the counts of the interceptor from the last time it was pinged.
+//
+// The next ping overwrites the saved counts either way, which
+// corrects any update that was missed or raced with it
+func (q *queuePinger) saveStreamedCounts(
+	interceptor string,
+	counts map[string]int,
+	full bool,
+) {
+	q.pingMut.Lock()
+	defer q.pingMut.Unlock()
+	prevCounts, ok := q.streamedCounts[interceptor]
+	if full || !ok {
+		prevCounts = map[string]int{}
+		q.streamedCounts[interceptor] = prevCounts
+	}
+	// counts hands out allCounts to be read without pingMut
+	// locked, so it is copied rather than changed in place
+	var allCounts map[string]int
+	for host, count := range counts {
+		delta := count - prevCounts[host]
+		prevCounts[host] = count
+		if full || delta == 0 {
+			continue
+		}
+		if allCounts == nil {
+			allCounts = make(map[string]int, len(q.allCounts))
+			for h, c := range q.allCounts {
+				allCounts[h] = c
+			}
+		}
+		// the saved counts may be behind the stream if the last
+		// ping raced with it, and never go below zero
+		if allCounts[host]+delta < 0 {
+			delta = -allCounts[host]
+		}
+		allCounts[host] += delta
+		q.aggregateCount += delta
+	}
+	if allCounts != nil {
+		q.allCounts = allCounts
+		q.notifyCountsChanged()
+	}
+}
+
+// removeStreamedCounts forgets the counts that the interceptor
+// named interceptor streamed, after its stream closed
+func (q *queuePinger) removeStreamedCounts(interceptor string) {
+	q.pingMut.Lock()
+	defer q.pingMut.Unlock()
+	delete(q.streamedCounts, interceptor)
+}
+
 func (q *queuePinger) aggregate() int {
 	q.pingMut.RLock()
 	defer q.pingMut.RUnlock()
 	return q.aggregateCount
 }
 
-// fetchAndSaveCounts calls fetchCounts, and then
-// saves them to internal state in q
+// endpoints returns the last known counts
+// of each interceptor endpoint
+func (q *queuePinger) endpoints() map[string]interceptorCounts {
+	q.pingMut.RLock()
+	defer q.pingMut.RUnlock()
+	ret := make(map[string]interceptorCounts, len(q.endpointCounts))
+	for u, cts := range q.endpointCounts {
+		ret[u] = *cts
+	}
+	return ret
+}
+
+// fetchAndSaveCounts calls fetchCounts, and then saves the counts
+// to internal state in q. The endpoints whose fetch failed keep
+// their last known counts for up to staleCountsTTL after their
+// last successful fetch, and count as zero after that. So do the
+// endpoints that were removed while they had pending requests,
+// like those of interceptors that are draining and no longer
+// ready, whose requests would stop counting otherwise.
+//
+// It returns a non-nil error only if the endpoints could not be
+// listed, in which case every known endpoint counts as failed
 func (q *queuePinger) fetchAndSaveCounts(ctx context.Context) error {
-	q.pingMut.Lock()
-	defer q.pingMut.Unlock()
-	counts, agg, err := fetchCounts(
-		ctx,
+	fetchCtx, done := context.WithTimeout(ctx, q.fetchTimeout)
+	defer done()
+	// fetching can take up to fetchTimeout, so pingMut is only
+	// locked after, to save the results, and the counts can
+	// still be read in the meantime
+	results, err := fetchCounts(
+		fetchCtx,
 		q.lggr,
 		q.getEndpointsFn,
 		q.interceptorNS,
 		q.interceptorSvcName,
 		q.adminPort,
 	)
+	q.pingMut.Lock()
+	defer q.pingMut.Unlock()
 	if err != nil {
 		q.lggr.Error(err, "getting request counts")
-		return err
+		results = make([]endpointCounts, 0, len(q.endpointCounts))
+		known := make([]string, 0, len(q.endpointCounts))
+		for u := range q.endpointCounts {
+			results = append(results, endpointCounts{url: u, err: err})
+			known = append(known, u)
+		}
+		q.metrics.observeListFailure(known)
+	} else {
+		allFetched := true
+		for _, res := range results {
+			q.metrics.observeFetch(res.url, res.duration, res.err)
+			allFetched = allFetched && res.err == nil
+		}
+		if allFetched {
+			q.metrics.lastPingSuccess.SetToCurrentTime()
+		}
+	}
+
+	now := time.Now()
+	listed := make(map[string]bool, len(results))
+	for _, res := range results {
+		listed[res.url] = true
 	}
-	q.allCounts = counts
+	for u, cts := range q.endpointCounts {
+		if !listed[u] && hasPending(cts.Counts) && now.Sub(cts.LastFetched) <= q.staleCountsTTL {
+			results = append(results, endpointCounts{url: u, err: errEndpointRemoved})
+		}
+	}
+
+	endpointCts := make(map[string]*interceptorCounts, len(results))
+	totalCounts := make(map[string]int)
+	totalRates := make(map[string]float64)
+	maxWaits := make(map[string]time.Duration)
+	agg := 0
+	for _, res := range results {
+		cts, ok := endpointCts[res.url]
+		if !ok {
+			cts = q.endpointCounts[res.url]
+		}
+		if res.err == nil {
+			cts = &interceptorCounts{
+				Counts:        res.counts,
+				RPS:           res.rates,
+				WaitP95Millis: durationsToMillis(res.waits),
+				LastFetched:   now,
+			}
+		} else if cts != nil {
+			cts = &interceptorCounts{
+				Counts:        cts.Counts,
+				RPS:           cts.RPS,
+				WaitP95Millis: cts.WaitP95Millis,
+				LastFetched:   cts.LastFetched,
+				LastError:     res.err.Error(),
+				Stale:         true,
+			}
+		} else {
+			cts = &interceptorCounts{
+				LastError: res.err.Error(),
+				Stale:     true,
+			}
+		}
+		endpointCts[res.url] = cts
+		if cts.Stale && now.Sub(cts.LastFetched) > q.staleCountsTTL {
+			continue
+		}
+		for host, val := range cts.Counts {
+			agg += val
+			totalCounts[host] += val
+		}
+		for host, val := range cts.RPS {
+			totalRates[host] += val
+		}
+		for host, ms := range cts.WaitP95Millis {
+			if val := time.Duration(ms) * time.Millisecond; val > maxWaits[host] {
+				maxWaits[host] = val
+			}
+		}
+	}
+
+	for u := range q.endpointCounts {
+		if _, ok := endpointCts[u]; !ok {
+			q.metrics.forgetInterceptor(u)
+		}
+	}
+	q.endpointCounts = endpointCts
+	q.allCounts = totalCounts
 	q.aggregateCount = agg
-	q.lastPingTime = time.Now()
+	q.allRates = totalRates
+	q.allWaitP95 = maxWaits
+	q.lastPingTime = now
+	q.notifyCountsChanged()
+
+	return err
+}
+
+// errEndpointRemoved is the error of the endpoints that are
+// no longer listed, but whose last known counts are kept
+var errEndpointRemoved = errors.New("endpoint was removed")
 
-	return nil
+// hasPending returns whether any of counts is not zero
+func hasPending(counts map[string]int) bool {
+	for _, count := range counts {
+		if count != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointCounts is the result of fetching
+// the counts of a single interceptor endpoint
+type endpointCounts struct {
+	url    string
+	counts map[string]int
+	rates  map[string]float64
+	waits  map[string]time.Duration
+	err    error
+	// duration is how long the fetch took
+	duration time.Duration
+}
+
+// interceptorCounts are the last known counts of an interceptor
+// endpoint. Stale counts are from a previous fetch, because the
+// latest fetch failed with LastError. The wait times are in
+// milliseconds, like the ones that the interceptors serve
+type interceptorCounts struct {
+	Counts        map[string]int     `json:"counts"`
+	RPS           map[string]float64 `json:"rps,omitempty"`
+	WaitP95Millis map[string]int64   `json:"waitP95Ms,omitempty"`
+	LastFetched   time.Time          `json:"lastFetched"`
+	LastError     string             `json:"lastError,omitempty"`
+	Stale         bool               `json:"stale"`
+}
+
+// durationsToMillis returns durations in milliseconds
+func durationsToMillis(durations map[string]time.Duration) map[string]int64 {
+	if len(durations) == 0 {
+		return nil
+	}
+	ret := make(map[string]int64, len(durations))
+	for key, d := range durations {
+		ret[key] = d.Milliseconds()
+	}
+	return ret
 }
 
 // fetchCounts fetches all counts from every endpoint returned
 // by endpointsFn for the given service named svcName on the
 // port adminPort, in namespace ns.
 //
-// Requests to fetch endpoints are made concurrently and
-// aggregated when all requests return successfully.
+// Requests to fetch endpoints are made concurrently, and the
+// result of each endpoint is returned once all requests are done.
+// A failed request only fails the result of its endpoint.
 //
-// Upon any failure, a non-nil error is returned and the
-// other two return values are nil and 0, respectively.
+// A non-nil error is returned only if the endpoints could not be
+// listed, in which case the returned results are nil.
 func fetchCounts(
 	ctx context.Context,
 	lggr logr.Logger,
@@ -191,7 +472,7 @@ func fetchCounts(
 	ns,
 	svcName,
 	adminPort string,
-) (map[string]int, int, error) {
+) ([]endpointCounts, error) {
 	lggr = lggr.WithName("queuePinger.requestCounts")
 
 	endpointURLs, err := k8s.EndpointsForService(
@@ -202,29 +483,29 @@ func fetchCounts(
 		endpointsFn,
 	)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
-	countsCh := make(chan *queue.Counts)
+	// each goroutine writes to its own index
+	// of results, so they need no locking
+	results := make([]endpointCounts, len(endpointURLs))
 	var wg sync.WaitGroup
-	fetchGrp, ctx := errgroup.WithContext(ctx)
-	for _, endpoint := range endpointURLs {
-		// capture the endpoint in a loop-local
-		// variable so that the goroutine can
-		// use it
-		u := endpoint
-		// have the errgroup goroutine send to
-		// a "private" goroutine, which we'll
-		// then forward on to countsCh
-		ch := make(chan *queue.Counts)
+	for i, endpoint := range endpointURLs {
+		// capture the index and endpoint in loop-local
+		// variables so that the goroutine can use them
+		i, u := i, endpoint
 		wg.Add(1)
-		fetchGrp.Go(func() error {
+		go func() {
+			defer wg.Done()
+			results[i].url = u.String()
+			start := time.Now()
 			counts, err := queue.GetCounts(
 				ctx,
 				lggr,
 				http.DefaultClient,
 				*u,
 			)
+			results[i].duration = time.Since(start)
 			if err != nil {
 				lggr.Error(
 					err,
@@ -232,45 +513,15 @@ func fetchCounts(
 					"interceptorAddress",
 					u.String(),
 				)
-				return err
+				results[i].err = err
+				return
 			}
-			ch <- counts
-			return nil
-		})
-		// forward the "private" goroutine
-		// on to countsCh separately
-		go func() {
-			defer wg.Done()
-			res := <-ch
-			countsCh <- res
+			results[i].counts = counts.Counts
+			results[i].rates = counts.RPS
+			results[i].waits = counts.WaitP95
 		}()
 	}
+	wg.Wait()
 
-	// close countsCh after all goroutines are done sending
-	// to their "private" channels, so that we can range
-	// over countsCh normally below
-	go func() {
-		wg.Wait()
-		close(countsCh)
-	}()
-
-	if err := fetchGrp.Wait(); err != nil {
-		lggr.Error(err, "fetching all counts failed")
-		return nil, 0, err
-	}
-
-	// consume the results of the counts channel
-	agg := 0
-	totalCounts := make(map[string]int)
-	// range through the result of each endpoint
-	for count := range countsCh {
-		// each endpoint returns a map of counts, one count
-		// per host. add up the counts for each host
-		for host, val := range count.Counts {
-			agg += val
-			totalCounts[host] += val
-		}
-	}
-
-	return totalCounts, agg, nil
+	return results, nil
 }
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
 	v1 "k8s.io/api/core/v1"
 
 	"github.com/kedacore/http-add-on/pkg/k8s"
@@ -30,7 +31,7 @@ func startFakeQueueEndpointServer(
 	numEndpoints int,
 ) (*httptest.Server, *url.URL, *v1.Endpoints, error) {
 	hdl := http.NewServeMux()
-	queue.AddCountsRoute(logr.Discard(), hdl, q)
+	queue.AddCountsRoute(logr.Discard(), hdl, q, nil)
 	srv, srvURL, err := kedanet.StartTestServer(hdl)
 	if err != nil {
 		return nil, nil, nil, err
@@ -79,6 +80,9 @@ func newFakeQueuePinger(
 		"testsvc",
 		"testdepl",
 		opts.port,
+		time.Minute,
+		time.Second,
+		newScalerMetrics(prometheus.NewRegistry()),
 	)
 	if err != nil {
 		return nil, nil, err
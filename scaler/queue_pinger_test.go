@@ -2,15 +2,23 @@ package main
 
 import (
 	context "context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
 	v1 "k8s.io/api/core/v1"
 
 	"github.com/kedacore/http-add-on/pkg/k8s"
+	kedanet "github.com/kedacore/http-add-on/pkg/net"
 	"github.com/kedacore/http-add-on/pkg/queue"
 )
 
@@ -34,7 +42,7 @@ func TestCounts(t *testing.T) {
 		"host4": 809,
 	}
 
-	q := queue.NewMemory(time.Second, false, logr.Logger{})
+	q := queue.NewMemory(time.Second, false, time.Minute, logr.Logger{})
 	for host, count := range counts {
 		r.NoError(q.Resize(host, count))
 	}
@@ -56,6 +64,9 @@ func TestCounts(t *testing.T) {
 		svcName,
 		deplName,
 		srvURL.Port(),
+		time.Minute,
+		time.Second,
+		newScalerMetrics(prometheus.NewRegistry()),
 	)
 	r.NoError(err)
 	// the pinger does an initial fetch, so ensure that
@@ -115,7 +126,7 @@ func TestFetchAndSaveCounts(t *testing.T) {
 		"host2": 234,
 		"host3": 345,
 	}
-	q := queue.NewMemory(time.Second, false, logr.Logger{})
+	q := queue.NewMemory(time.Second, false, time.Minute, logr.Logger{})
 	for host, count := range counts.Counts {
 		r.NoError(q.Resize(host, count))
 	}
@@ -140,6 +151,9 @@ func TestFetchAndSaveCounts(t *testing.T) {
 		svcName,
 		deplName,
 		srvURL.Port(),
+		time.Minute,
+		time.Second,
+		newScalerMetrics(prometheus.NewRegistry()),
 		// time.NewTicker(1*time.Millisecond),
 	)
 	r.NoError(err)
@@ -160,6 +174,119 @@ func TestFetchAndSaveCounts(t *testing.T) {
 	r.Equal(expectedCounts, pinger.allCounts)
 }
 
+// the counts should still be readable while
+// an endpoint is slow to serve its counts
+func TestFetchAndSaveCountsSlowEndpoint(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+	const (
+		ns       = "testns"
+		svcName  = "testsvc"
+		deplName = "testdepl"
+	)
+	q := queue.NewMemory(time.Second, false, time.Minute, logr.Discard())
+	r.NoError(q.Resize("host1", 1))
+	mux := http.NewServeMux()
+	queue.AddCountsRoute(logr.Discard(), mux, q, nil)
+	var slow atomic.Bool
+	unblock := make(chan struct{})
+	srv, srvURL, err := kedanet.StartTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slow.Load() {
+			<-unblock
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	r.NoError(err)
+	defer srv.Close()
+	endpoints, err := k8s.FakeEndpointsForURLs([]*url.URL{srvURL}, ns, svcName)
+	r.NoError(err)
+	endpointsFn := func(context.Context, string, string) (*v1.Endpoints, error) {
+		return endpoints, nil
+	}
+
+	pinger, err := newQueuePinger(
+		ctx,
+		logr.Discard(),
+		endpointsFn,
+		ns,
+		svcName,
+		deplName,
+		srvURL.Port(),
+		time.Minute,
+		time.Minute,
+		newScalerMetrics(prometheus.NewRegistry()),
+	)
+	r.NoError(err)
+
+	slow.Store(true)
+	fetched := make(chan error)
+	go func() {
+		fetched <- pinger.fetchAndSaveCounts(ctx)
+	}()
+	read := make(chan map[string]int)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		read <- pinger.counts()
+	}()
+	select {
+	case counts := <-read:
+		r.Equal(map[string]int{"host1": 1}, counts)
+	case <-time.After(time.Second):
+		r.Fail("counts blocked by a slow endpoint")
+	}
+
+	r.NoError(q.Resize("host1", 1))
+	close(unblock)
+	r.NoError(<-fetched)
+	r.Equal(map[string]int{"host1": 2}, pinger.counts())
+}
+
+// the wait times of the endpoints should be
+// served in milliseconds, like the interceptors do
+func TestFetchAndSaveCountsWaitTimes(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+	const (
+		ns       = "testns"
+		svcName  = "testsvc"
+		deplName = "testdepl"
+	)
+	q := queue.NewMemory(time.Second, false, time.Minute, logr.Discard())
+	r.NoError(q.Resize("host1", 1))
+	q.RecordWait("host1", 2500*time.Millisecond)
+	mux := http.NewServeMux()
+	queue.AddCountsRoute(logr.Discard(), mux, q, nil)
+	srv, srvURL, err := kedanet.StartTestServer(mux)
+	r.NoError(err)
+	defer srv.Close()
+	endpoints, err := k8s.FakeEndpointsForURLs([]*url.URL{srvURL}, ns, svcName)
+	r.NoError(err)
+	endpointsFn := func(context.Context, string, string) (*v1.Endpoints, error) {
+		return endpoints, nil
+	}
+
+	pinger, err := newQueuePinger(
+		ctx,
+		logr.Discard(),
+		endpointsFn,
+		ns,
+		svcName,
+		deplName,
+		srvURL.Port(),
+		time.Minute,
+		time.Second,
+		newScalerMetrics(prometheus.NewRegistry()),
+	)
+	r.NoError(err)
+	r.Equal(map[string]time.Duration{"host1": 2500 * time.Millisecond}, pinger.waitTimes())
+
+	encoded, err := json.Marshal(pinger.endpoints()[srvURL.String()])
+	r.NoError(err)
+	r.Contains(string(encoded), `"waitP95Ms":{"host1":2500}`)
+}
+
 func TestFetchCounts(t *testing.T) {
 	r := require.New(t)
 	ctx, done := context.WithCancel(context.Background())
@@ -176,7 +303,7 @@ func TestFetchCounts(t *testing.T) {
 		"host2": 234,
 		"host3": 345,
 	}
-	q := queue.NewMemory(time.Second, false, logr.Logger{})
+	q := queue.NewMemory(time.Second, false, time.Minute, logr.Logger{})
 	for host, count := range counts.Counts {
 		r.NoError(q.Resize(host, count))
 	}
@@ -194,7 +321,7 @@ func TestFetchCounts(t *testing.T) {
 		return endpoints, nil
 	}
 
-	cts, agg, err := fetchCounts(
+	results, err := fetchCounts(
 		ctx,
 		logr.Discard(),
 		endpointsFn,
@@ -204,17 +331,184 @@ func TestFetchCounts(t *testing.T) {
 	)
 	r.NoError(err)
 	// since all endpoints serve the same counts,
-	// expected aggregate is individual count * # endpoints
-	expectedAgg := counts.Aggregate() * numEndpoints
-	r.Equal(expectedAgg, agg)
-	// again, since all endpoints serve the same counts,
-	// the hosts will be the same as the original counts,
-	// but the value is (individual count * # endpoints)
-	expectedCounts := counts.Counts
-	for host, val := range expectedCounts {
-		expectedCounts[host] = val * numEndpoints
+	// each of them returns the original counts
+	r.Len(results, numEndpoints)
+	for _, res := range results {
+		r.NoError(res.err)
+		r.Equal(srvURL.String(), res.url)
+		r.Equal(counts.Counts, res.counts)
 	}
-	r.Equal(expectedCounts, cts)
+}
+
+func TestFetchAndSaveCountsPartialFailure(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+	const (
+		ns       = "testns"
+		svcName  = "testsvc"
+		deplName = "testdepl"
+	)
+	q := queue.NewMemory(time.Second, false, time.Minute, logr.Discard())
+	r.NoError(q.Resize("host1", 2))
+
+	// the first endpoint serves the counts of q until it fails,
+	// and the second endpoint is never reachable
+	var failing atomic.Bool
+	mux := http.NewServeMux()
+	queue.AddCountsRoute(logr.Discard(), mux, q, nil)
+	srv, srvURL, err := kedanet.StartTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	r.NoError(err)
+	defer srv.Close()
+	unreachableURL, err := url.Parse("http://127.0.0.2:" + srvURL.Port())
+	r.NoError(err)
+	endpoints, err := k8s.FakeEndpointsForURLs(
+		[]*url.URL{srvURL, unreachableURL},
+		ns,
+		svcName,
+	)
+	r.NoError(err)
+	var endpointsErr atomic.Bool
+	endpointsFn := func(context.Context, string, string) (*v1.Endpoints, error) {
+		if endpointsErr.Load() {
+			return nil, errors.New("no endpoints")
+		}
+		return endpoints, nil
+	}
+
+	pinger, err := newQueuePinger(
+		ctx,
+		logr.Discard(),
+		endpointsFn,
+		ns,
+		svcName,
+		deplName,
+		srvURL.Port(),
+		time.Minute,
+		time.Second,
+		newScalerMetrics(prometheus.NewRegistry()),
+	)
+	r.NoError(err)
+
+	// the unreachable endpoint doesn't fail the counts
+	r.Equal(map[string]int{"host1": 2}, pinger.counts())
+	endpointCts := pinger.endpoints()
+	r.False(endpointCts[srvURL.String()].Stale)
+	r.True(endpointCts[unreachableURL.String()].Stale)
+	r.NotEmpty(endpointCts[unreachableURL.String()].LastError)
+
+	// a failing endpoint keeps its last known counts
+	failing.Store(true)
+	r.NoError(q.Resize("host1", 1))
+	r.NoError(pinger.fetchAndSaveCounts(ctx))
+	r.Equal(map[string]int{"host1": 2}, pinger.counts())
+	endpointCts = pinger.endpoints()
+	r.True(endpointCts[srvURL.String()].Stale)
+	r.Equal(map[string]int{"host1": 2}, endpointCts[srvURL.String()].Counts)
+
+	// until they are no longer trusted
+	pinger.staleCountsTTL = 0
+	r.NoError(pinger.fetchAndSaveCounts(ctx))
+	r.Empty(pinger.counts())
+
+	// failing to list the endpoints keeps the last known counts too
+	failing.Store(false)
+	pinger.staleCountsTTL = time.Minute
+	r.NoError(pinger.fetchAndSaveCounts(ctx))
+	r.Equal(map[string]int{"host1": 3}, pinger.counts())
+	endpointsErr.Store(true)
+	r.Error(pinger.fetchAndSaveCounts(ctx))
+	r.Equal(map[string]int{"host1": 3}, pinger.counts())
+	r.True(pinger.endpoints()[srvURL.String()].Stale)
+
+	// every fetch is measured, and failing to list
+	// the endpoints fails all of the known ones
+	r.Equal(3.0, testutil.ToFloat64(pinger.metrics.fetchFailures.WithLabelValues(srvURL.String())))
+	r.Equal(5.0, testutil.ToFloat64(pinger.metrics.fetchFailures.WithLabelValues(unreachableURL.String())))
+	r.Equal(2, testutil.CollectAndCount(pinger.metrics.fetchDuration))
+	// and no ping fetched the counts of every endpoint
+	r.Equal(0.0, testutil.ToFloat64(pinger.metrics.lastPingSuccess))
+}
+
+// the endpoints that are removed with pending requests, like
+// those of draining interceptors, should keep their last known
+// counts like failing ones
+func TestFetchAndSaveCountsRemovedEndpoint(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+	const (
+		ns       = "testns"
+		svcName  = "testsvc"
+		deplName = "testdepl"
+	)
+	q := queue.NewMemory(time.Second, false, time.Minute, logr.Discard())
+	r.NoError(q.Resize("host1", 2))
+	mux := http.NewServeMux()
+	queue.AddCountsRoute(logr.Discard(), mux, q, nil)
+	srv, srvURL, err := kedanet.StartTestServer(mux)
+	r.NoError(err)
+	defer srv.Close()
+	endpoints, err := k8s.FakeEndpointsForURLs(
+		[]*url.URL{srvURL},
+		ns,
+		svcName,
+	)
+	r.NoError(err)
+	var removed atomic.Bool
+	endpointsFn := func(context.Context, string, string) (*v1.Endpoints, error) {
+		if removed.Load() {
+			return &v1.Endpoints{}, nil
+		}
+		return endpoints, nil
+	}
+
+	pinger, err := newQueuePinger(
+		ctx,
+		logr.Discard(),
+		endpointsFn,
+		ns,
+		svcName,
+		deplName,
+		srvURL.Port(),
+		time.Minute,
+		time.Second,
+		newScalerMetrics(prometheus.NewRegistry()),
+	)
+	r.NoError(err)
+	r.Equal(map[string]int{"host1": 2}, pinger.counts())
+
+	removed.Store(true)
+	r.NoError(pinger.fetchAndSaveCounts(ctx))
+	r.Equal(map[string]int{"host1": 2}, pinger.counts())
+	endpointCts := pinger.endpoints()
+	r.True(endpointCts[srvURL.String()].Stale)
+	r.Equal(errEndpointRemoved.Error(), endpointCts[srvURL.String()].LastError)
+
+	// until they are no longer trusted, after which they are forgotten
+	pinger.staleCountsTTL = 0
+	r.NoError(pinger.fetchAndSaveCounts(ctx))
+	r.Empty(pinger.counts())
+	r.NoError(pinger.fetchAndSaveCounts(ctx))
+	r.Empty(pinger.endpoints())
+	// and so are their metrics
+	r.Equal(0, testutil.CollectAndCount(pinger.metrics.fetchDuration))
+
+	// removed endpoints without pending requests are forgotten right away
+	pinger.staleCountsTTL = time.Minute
+	removed.Store(false)
+	r.NoError(q.Resize("host1", -2))
+	r.NoError(pinger.fetchAndSaveCounts(ctx))
+	r.Len(pinger.endpoints(), 1)
+	removed.Store(true)
+	r.NoError(pinger.fetchAndSaveCounts(ctx))
+	r.Empty(pinger.endpoints())
 }
 
 func TestMergeCountsWithRoutingTable(t *testing.T) {
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io"
+
+	"github.com/go-logr/logr"
+
+	externalscaler "github.com/kedacore/http-add-on/proto"
+)
+
+// queueCountsServer receives the pending request counts that
+// interceptors stream as they change, and saves them to the pinger
+// between its pings
+type queueCountsServer struct {
+	lggr   logr.Logger
+	pinger *queuePinger
+	externalscaler.UnimplementedQueueCountsServer
+}
+
+func newQueueCountsServer(lggr logr.Logger, pinger *queuePinger) *queueCountsServer {
+	return &queueCountsServer{
+		lggr:   lggr.WithName("queueCountsServer"),
+		pinger: pinger,
+	}
+}
+
+func (s *queueCountsServer) StreamCounts(
+	stream externalscaler.QueueCounts_StreamCountsServer,
+) error {
+	var interceptor string
+	defer func() {
+		if interceptor != "" {
+			s.pinger.removeStreamedCounts(interceptor)
+		}
+	}()
+	for {
+		update, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&externalscaler.QueueCountsAck{})
+		}
+		if err != nil {
+			s.lggr.Error(err, "receiving queue counts", "interceptor", interceptor)
+			return err
+		}
+		interceptor = update.Interceptor
+		counts := make(map[string]int, len(update.Counts))
+		for host, count := range update.Counts {
+			counts[host] = int(count)
+		}
+		s.pinger.saveStreamedCounts(interceptor, counts, update.Full)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	context "context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/kedacore/http-add-on/pkg/queue"
+	"github.com/kedacore/http-add-on/pkg/routing"
+	externalscaler "github.com/kedacore/http-add-on/proto"
+)
+
+func TestSaveStreamedCounts(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	ticker, pinger, err := newFakeQueuePinger(ctx, logr.Discard())
+	r.NoError(err)
+	defer ticker.Stop()
+	pinger.allCounts = map[string]int{"host1": 2, "host2": 1}
+	pinger.aggregateCount = 3
+
+	// a full update only sets the counts that
+	// the following updates are applied to
+	changed := pinger.countsChanged()
+	pinger.saveStreamedCounts("interceptor1", map[string]int{"host1": 1}, true)
+	r.Equal(map[string]int{"host1": 2, "host2": 1}, pinger.counts())
+	select {
+	case <-changed:
+		r.Fail("counts changed by a full update")
+	default:
+	}
+
+	pinger.saveStreamedCounts("interceptor1", map[string]int{"host1": 3, "host2": 1}, false)
+	r.Equal(map[string]int{"host1": 4, "host2": 2}, pinger.counts())
+	r.Equal(6, pinger.aggregate())
+	select {
+	case <-changed:
+	default:
+		r.Fail("counts changed without notification")
+	}
+
+	// counts never go below zero
+	pinger.saveStreamedCounts("interceptor2", map[string]int{"host2": 5}, true)
+	pinger.saveStreamedCounts("interceptor2", map[string]int{"host2": 0}, false)
+	r.Equal(map[string]int{"host1": 4, "host2": 0}, pinger.counts())
+
+	// updates after a closed stream start over
+	pinger.removeStreamedCounts("interceptor1")
+	pinger.saveStreamedCounts("interceptor1", map[string]int{"host1": 1}, false)
+	r.Equal(map[string]int{"host1": 5, "host2": 0}, pinger.counts())
+}
+
+func TestStreamIsActiveOnStreamedCounts(t *testing.T) {
+	r := require.New(t)
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+	lggr := logr.Discard()
+	const host = "testhost"
+
+	table := routing.NewTable()
+	r.NoError(table.AddTarget(host, standardTarget()))
+	ticker, pinger, err := newFakeQueuePinger(ctx, lggr)
+	r.NoError(err)
+	defer ticker.Stop()
+
+	lis := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	defer grpcServer.Stop()
+	externalscaler.RegisterExternalScalerServer(
+		grpcServer,
+		newImpl(lggr, pinger, table, 123, 200, newScalerMetrics(prometheus.NewRegistry())),
+	)
+	externalscaler.RegisterQueueCountsServer(
+		grpcServer,
+		newQueueCountsServer(lggr, pinger),
+	)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	conn, err := grpc.DialContext(
+		ctx,
+		"bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	r.NoError(err)
+	defer conn.Close()
+
+	q := queue.NewMemory(time.Second, false, time.Minute, lggr)
+	q.Ensure(host)
+	go func() {
+		_ = queue.StreamCounts(
+			ctx,
+			lggr,
+			externalscaler.NewQueueCountsClient(conn),
+			"interceptor1",
+			q,
+		)
+	}()
+
+	streamClient, err := externalscaler.NewExternalScalerClient(conn).StreamIsActive(
+		ctx,
+		&externalscaler.ScaledObjectRef{
+			ScalerMetadata: map[string]string{"hosts": host},
+		},
+	)
+	r.NoError(err)
+
+	// wait for the full update to arrive, so that the
+	// next update is applied to the counts
+	r.Eventually(func() bool {
+		pinger.pingMut.RLock()
+		defer pinger.pingMut.RUnlock()
+		_, ok := pinger.streamedCounts["interceptor1"]
+		return ok
+	}, time.Second, 10*time.Millisecond)
+
+	// the stream sends the active status as soon as the first
+	// request comes in, well before its 2 second ticker
+	start := time.Now()
+	r.NoError(q.Resize(host, 1))
+	res, err := streamClient.Recv()
+	r.NoError(err)
+	r.True(res.Result)
+	r.Less(time.Since(start), time.Second)
+	r.Equal(1, pinger.counts()[host])
+}